@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// adapter converts the export of another chat platform to the Message
+// and User model of Slack exports, so that its activity is counted and
+// reported along the Slack workspaces.
+type adapter interface {
+	// load reads the export at path.
+	load(path string) (*importedExport, error)
+}
+
+// importedExport is an export converted by an adapter. Messages are
+// keyed by channelKey, with the team or server as the workspace.
+type importedExport struct {
+	path     string
+	users    map[string]*User
+	messages map[string][]Message
+}
+
+// adapters are the platforms -import accepts.
+var adapters = map[string]adapter{
+	"mattermost": mattermostAdapter{},
+	"discord":    discordAdapter{},
+	"teams":      teamsAdapter{},
+}
+
+// parseImport splits a -import value of the form PLATFORM:PATH.
+func parseImport(value string) (adapter, string, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, "", errors.New("-import must be PLATFORM:PATH, got " + value)
+	}
+	a, ok := adapters[parts[0]]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown platform %q, expected mattermost, discord or teams", parts[0])
+	}
+	return a, parts[1], nil
+}
+
+// slackTimestamp formats t as a Slack ts, so that messages of other
+// platforms sort and parse like those of Slack.
+func slackTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1000)
+}
+
+// processImport updates the stats with the messages of an imported
+// export, channel by channel.
+func processImport(export *importedExport, users map[string]*User, statsByChannel StatsByChannel, messagesByChannel map[string][]Message) error {
+	keys := make([]string, 0, len(export.messages))
+	for key := range export.messages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		messages := export.messages[key]
+		sort.SliceStable(messages, func(i, j int) bool {
+			return messages[i].Timestamp < messages[j].Timestamp
+		})
+		telemetry.add("slack_analytics.messages", int64(len(messages)))
+
+		if *coverage {
+			days := make(map[string]bool)
+			for _, message := range messages {
+				if t, err := parseTimestamp(message.Timestamp); err == nil {
+					days[t.Format("2006-01-02")] = true
+				}
+			}
+			for day := range days {
+				addDayFile(key, day)
+			}
+		}
+		attributed, skipped, err := updateStats(statsByChannel, key, messages, users, exportThreads)
+		if err != nil {
+			return err
+		}
+		if verification != nil {
+			verification.check(export.path+"#"+key, len(messages), len(messages), attributed, skipped)
+		}
+		if keepMessages() {
+			messagesByChannel[key] = append(messagesByChannel[key], messages...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ackTokens are single words posted to acknowledge, like a reaction
+// would. Trailing punctuation is ignored.
+var ackTokens = map[string]bool{
+	"+1": true, "ack": true, "done": true, "k": true, "kk": true,
+	"lgtm": true, "lol": true, "nice": true, "noted": true, "ok": true,
+	"okay": true, "thanks": true, "thx": true, "ty": true, "yep": true,
+	"yes": true, "了解": true, "了解です": true, "承知しました": true,
+	"ありがとう": true, "ありがとうございます": true, "草": true,
+}
+
+// AmbientPosts counts the posts of a channel that only acknowledge:
+// emoji-only posts and single acknowledging words.
+type AmbientPosts struct {
+	ChannelName string
+	Posts       int
+	EmojiOnly   int
+	Acks        int
+}
+
+// isEmojiOnly reports whether text holds nothing but emoji, as
+// :shortcode: or Unicode characters, and spaces.
+func isEmojiOnly(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return false
+	}
+	for len(text) > 0 {
+		if text[0] == ':' {
+			end := strings.IndexByte(text[1:], ':')
+			if end <= 0 || strings.IndexFunc(text[1:end+1], unicode.IsSpace) >= 0 {
+				return false
+			}
+			text = text[end+2:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text)
+		if !unicode.IsSpace(r) && !isEmojiRune(r) {
+			return false
+		}
+		text = text[size:]
+	}
+	return true
+}
+
+// isEmojiRune reports whether r is an emoji or one of the characters
+// joining and modifying emoji.
+func isEmojiRune(r rune) bool {
+	switch {
+	case r >= 0x1f000 && r <= 0x1faff, // pictographs, flags, skin tones
+		r >= 0x2600 && r <= 0x27bf,            // symbols and dingbats
+		r == 0x200d, r == 0xfe0f, r == 0x20e3: // joiner, variation selector, keycap
+		return true
+	}
+	return unicode.Is(unicode.So, r)
+}
+
+// isAck reports whether text is one acknowledging word.
+func isAck(text string) bool {
+	text = strings.TrimRightFunc(strings.TrimSpace(text), func(r rune) bool {
+		return r == '!' || r == '.' || r == '。' || r == '！'
+	})
+	return ackTokens[strings.ToLower(text)]
+}
+
+// countAmbientPosts counts the posts of each channel and those only
+// acknowledging. Bot posts are left out.
+func countAmbientPosts(messagesByChannel map[string][]Message) []*AmbientPosts {
+	var result []*AmbientPosts
+	for channelName, messages := range messagesByChannel {
+		a := &AmbientPosts{ChannelName: channelName}
+		for _, message := range messages {
+			if message.User == "" || message.BotID != "" {
+				continue
+			}
+			a.Posts++
+			if isEmojiOnly(message.Text) {
+				a.EmojiOnly++
+			} else if isAck(message.Text) {
+				a.Acks++
+			}
+		}
+		if a.Posts > 0 {
+			result = append(result, a)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ChannelName < result[j].ChannelName })
+	return result
+}
+
+func exportAmbientCSV(fileName string, ambient []*AmbientPosts) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"posts",
+		"emoji_only_posts",
+		"ack_posts",
+		"ambient_share",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range ambient {
+		workspace, channelName := splitChannelKey(a.ChannelName)
+		row := []string{
+			channelName,
+			strconv.Itoa(a.Posts),
+			strconv.Itoa(a.EmojiOnly),
+			strconv.Itoa(a.Acks),
+			formatRate(a.EmojiOnly+a.Acks, a.Posts),
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Announcement is the engagement with one root message of an
+// announcements channel.
+type Announcement struct {
+	ChannelName string
+	Timestamp   string
+	PostedAt    time.Time
+	UserID      string
+	Text        string
+	Reactions   int
+	Reactors    map[string]bool
+	Replies     int
+	Repliers    map[string]bool
+}
+
+// AnnouncementReach totals the announcements of one channel.
+type AnnouncementReach struct {
+	ChannelName   string
+	Members       map[string]bool
+	Announcements int
+	Reactions     int
+	Replies       int
+	Reached       map[string]bool
+	Reactors      map[string]bool
+}
+
+// findAnnouncements returns the root messages of the channels in the
+// announcements category with their reactions and thread replies. The
+// reply_count and reply_users of a root are used when replies are
+// missing from the export.
+func findAnnouncements(messagesByChannel map[string][]Message) []*Announcement {
+	var result []*Announcement
+	for channelName, messages := range messagesByChannel {
+		if channelCategories[channelName] != categoryAnnouncements {
+			continue
+		}
+
+		byTs := make(map[string]*Announcement)
+		replyCounts := make(map[string]int)
+		for _, message := range messages {
+			if isReply(message) || message.Timestamp == "" {
+				continue
+			}
+			postedAt, err := parseTimestamp(message.Timestamp)
+			if err != nil {
+				continue
+			}
+			a := &Announcement{
+				ChannelName: channelName,
+				Timestamp:   message.Timestamp,
+				PostedAt:    postedAt,
+				UserID:      message.User,
+				Text:        message.Text,
+				Reactors:    make(map[string]bool),
+				Repliers:    make(map[string]bool),
+			}
+			for _, u := range message.ReplyUsers {
+				a.Repliers[u] = true
+			}
+			for _, reaction := range message.GivenReactions {
+				a.Reactions += len(reaction.Users)
+				for _, u := range reaction.Users {
+					a.Reactors[u] = true
+				}
+			}
+			byTs[message.Timestamp] = a
+			replyCounts[message.Timestamp] = message.ReplyCount
+			result = append(result, a)
+		}
+
+		for _, message := range messages {
+			if !isReply(message) {
+				continue
+			}
+			if a, ok := byTs[message.ThreadTimestamp]; ok {
+				a.Replies++
+				if message.User != "" {
+					a.Repliers[message.User] = true
+				}
+			}
+		}
+
+		// Partial exports may lack replies counted on the root
+		for ts, a := range byTs {
+			if replyCounts[ts] > a.Replies {
+				a.Replies = replyCounts[ts]
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelName != result[j].ChannelName {
+			return result[i].ChannelName < result[j].ChannelName
+		}
+		return result[i].PostedAt.Before(result[j].PostedAt)
+	})
+	return result
+}
+
+// announcementReach totals the announcements per channel. Members are
+// taken from channels.json; users who engaged without being members
+// count as reached but not towards the reach rate.
+func announcementReach(announcements []*Announcement, channels map[string]*Channel) []*AnnouncementReach {
+	var result []*AnnouncementReach
+	var current *AnnouncementReach
+	for _, a := range announcements {
+		if current == nil || current.ChannelName != a.ChannelName {
+			current = &AnnouncementReach{
+				ChannelName: a.ChannelName,
+				Reached:     make(map[string]bool),
+				Reactors:    make(map[string]bool),
+			}
+			current.Members = make(map[string]bool)
+			if c := channels[a.ChannelName]; c != nil {
+				for _, u := range c.Members {
+					current.Members[u] = true
+				}
+			}
+			result = append(result, current)
+		}
+		current.Announcements++
+		current.Reactions += a.Reactions
+		current.Replies += a.Replies
+		for u := range a.Reactors {
+			current.Reactors[u] = true
+			current.Reached[u] = true
+		}
+		for u := range a.Repliers {
+			current.Reached[u] = true
+		}
+	}
+	return result
+}
+
+func exportAnnouncementsCSV(fileName string, announcements []*Announcement, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"ts",
+		"posted_at",
+		"display_name",
+		"name",
+		"reactions",
+		"distinct_reactors",
+		"replies",
+		"distinct_repliers",
+		"text",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range announcements {
+		var displayName, name string
+		if u := lookupUser(users, a.UserID); u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+		}
+		workspace, channelName := splitChannelKey(a.ChannelName)
+		row := []string{
+			channelName,
+			a.Timestamp,
+			a.PostedAt.Format(time.RFC3339),
+			displayName,
+			name,
+			strconv.Itoa(a.Reactions),
+			strconv.Itoa(len(a.Reactors)),
+			strconv.Itoa(a.Replies),
+			strconv.Itoa(len(a.Repliers)),
+			preview(a.Text, users),
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportAnnouncementReachCSV(fileName string, reach []*AnnouncementReach) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"members",
+		"announcements",
+		"reactions_per_announcement",
+		"distinct_reactors",
+		"replies_per_announcement",
+		"reached_users",
+		"reached_members",
+		"reach_rate",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reach {
+		reachedMembers := 0
+		for u := range r.Reached {
+			if r.Members[u] {
+				reachedMembers++
+			}
+		}
+		workspace, channelName := splitChannelKey(r.ChannelName)
+		row := []string{
+			channelName,
+			strconv.Itoa(len(r.Members)),
+			strconv.Itoa(r.Announcements),
+			formatRate(r.Reactions, r.Announcements),
+			strconv.Itoa(len(r.Reactors)),
+			formatRate(r.Replies, r.Announcements),
+			strconv.Itoa(len(r.Reached)),
+			strconv.Itoa(reachedMembers),
+			formatRate(reachedMembers, len(r.Members)),
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
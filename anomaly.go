@@ -0,0 +1,187 @@
+package main
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Anomaly is a channel day whose activity is far from its trailing mean.
+type Anomaly struct {
+	ChannelName string
+	Day         string
+	Metric      string
+	Value       int
+	Mean        float64
+	StdDev      float64
+	// Severity is the distance from the mean in standard deviations,
+	// positive for spikes and negative for drops.
+	Severity float64
+}
+
+var anomalyMetrics = []struct {
+	name  string
+	value func(*Stats) int
+}{
+	{"posts", func(s *Stats) int { return s.Posts }},
+	{"received_reactions", func(s *Stats) int { return s.GivenReactions }},
+}
+
+// detectAnomalies compares each channel day with the window days
+// before it. Days without activity count as zero so that drops are
+// detected too, and days without a full trailing window are skipped.
+// Holidays are neither flagged nor part of the trailing window.
+func detectAnomalies(statsByChannel StatsByChannel, window int, sigma float64) []Anomaly {
+	var result []Anomaly
+	for channelName, ud := range statsByChannel {
+		totals := dayTotals(ud)
+		first, last, ok := dayRange(totals)
+		if !ok {
+			continue
+		}
+
+		for _, metric := range anomalyMetrics {
+			values := make(map[string]int)
+			for day, s := range totals {
+				values[day] = metric.value(s)
+			}
+
+			for t := first.AddDate(0, 0, window); !t.After(last); t = t.AddDate(0, 0, 1) {
+				day := t.Format("2006-01-02")
+				if isHoliday(day) {
+					continue
+				}
+				mean, stdDev := trailingMeanStdDev(values, t, window)
+				if stdDev == 0 {
+					continue
+				}
+
+				severity := (float64(values[day]) - mean) / stdDev
+				if math.Abs(severity) < sigma {
+					continue
+				}
+				result = append(result, Anomaly{
+					ChannelName: channelName,
+					Day:         day,
+					Metric:      metric.name,
+					Value:       values[day],
+					Mean:        mean,
+					StdDev:      stdDev,
+					Severity:    severity,
+				})
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelName != result[j].ChannelName {
+			return result[i].ChannelName < result[j].ChannelName
+		}
+		if result[i].Day != result[j].Day {
+			return result[i].Day < result[j].Day
+		}
+		return result[i].Metric < result[j].Metric
+	})
+	return result
+}
+
+// dayRange returns the first and last day present in days.
+func dayRange(days map[string]*Stats) (time.Time, time.Time, bool) {
+	var first, last time.Time
+	for day := range days {
+		t, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			continue
+		}
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if last.IsZero() || t.After(last) {
+			last = t
+		}
+	}
+	return first, last, !first.IsZero()
+}
+
+func trailingMeanStdDev(values map[string]int, t time.Time, window int) (float64, float64) {
+	var trailing []float64
+	for i := 1; i <= window; i++ {
+		day := t.AddDate(0, 0, -i).Format("2006-01-02")
+		if isHoliday(day) {
+			continue
+		}
+		trailing = append(trailing, float64(values[day]))
+	}
+	if len(trailing) == 0 {
+		return 0, 0
+	}
+
+	sum := 0.0
+	for _, v := range trailing {
+		sum += v
+	}
+	mean := sum / float64(len(trailing))
+
+	variance := 0.0
+	for _, v := range trailing {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(trailing)))
+}
+
+func exportAnomaliesCSV(fileName string, anomalies []Anomaly) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"day",
+		"metric",
+		"value",
+		"trailing_mean",
+		"trailing_stddev",
+		"severity",
+		"direction",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range anomalies {
+		direction := "spike"
+		if a.Severity < 0 {
+			direction = "drop"
+		}
+		workspace, channelName := splitChannelKey(a.ChannelName)
+		row := []string{
+			channelName,
+			a.Day,
+			a.Metric,
+			strconv.Itoa(a.Value),
+			formatFloat(a.Mean),
+			formatFloat(a.StdDev),
+			formatFloat(math.Abs(a.Severity)),
+			direction,
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ArchiveCandidate is a channel scored for archiving by -archive-report.
+type ArchiveCandidate struct {
+	*channelUsage
+	Members int
+	// DaysIdle is the number of days since the last post, or -1 if the
+	// channel has none.
+	DaysIdle int
+	// RecentPosts and PriorPosts are the posts of the last window and
+	// of the window before.
+	RecentPosts int
+	PriorPosts  int
+	Score       float64
+}
+
+// archiveCandidates scores the channels that are not archived yet,
+// highest score first. The score, from 0 to 100, weighs staleness by
+// one half and smallness and declining activity by a quarter each:
+//
+//	staleness = min(days idle, window) / window, 1 without posts
+//	smallness = 10 / (10 + members)
+//	decline   = (1 - (recent - prior) / (recent + prior)) / 2, 1 without posts in both
+//
+// Members are those of channels.json, or the posters for channels
+// missing from it.
+func archiveCandidates(statsByChannel StatsByChannel, channels map[string]*Channel, window int) []*ArchiveCandidate {
+	end, err := time.Parse("2006-01-02", lastStatsDay(statsByChannel))
+	if err != nil {
+		return nil
+	}
+	recentStart := end.AddDate(0, 0, -window+1).Format("2006-01-02")
+	priorStart := end.AddDate(0, 0, -2*window+1).Format("2006-01-02")
+
+	var result []*ArchiveCandidate
+	for key, u := range channelUsages(statsByChannel, channels, window) {
+		c := &ArchiveCandidate{channelUsage: u, Members: u.Posters, DaysIdle: -1}
+		if channel := channels[key]; channel != nil {
+			if channel.IsArchived {
+				continue
+			}
+			c.Members = len(channel.Members)
+		}
+		if lastActive, err := time.Parse("2006-01-02", u.LastActive); err == nil {
+			c.DaysIdle = int(end.Sub(lastActive).Hours() / 24)
+		}
+		for day, us := range statsByChannel[key] {
+			if day < priorStart {
+				continue
+			}
+			for _, s := range us {
+				if day >= recentStart {
+					c.RecentPosts += s.Posts
+				} else {
+					c.PriorPosts += s.Posts
+				}
+			}
+		}
+
+		staleness := 1.0
+		if c.DaysIdle >= 0 {
+			staleness = math.Min(float64(c.DaysIdle), float64(window)) / float64(window)
+		}
+		smallness := 10 / (10 + float64(c.Members))
+		decline := 1.0
+		if total := c.RecentPosts + c.PriorPosts; total > 0 {
+			decline = (1 - float64(c.RecentPosts-c.PriorPosts)/float64(total)) / 2
+		}
+		c.Score = 100 * (staleness/2 + smallness/4 + decline/4)
+		result = append(result, c)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].ChannelName < result[j].ChannelName
+	})
+	return result
+}
+
+func exportArchiveCSV(fileName string, candidates []*ArchiveCandidate) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"rank",
+		"channel_name",
+		"archive_score",
+		"days_since_last_post",
+		"members",
+		"posts",
+		"recent_posts",
+		"prior_posts",
+		"last_active",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for i, c := range candidates {
+		workspace, channelName := splitChannelKey(c.ChannelName)
+		row := []string{
+			strconv.Itoa(i + 1),
+			channelName,
+			formatFloat(c.Score),
+			strconv.Itoa(c.DaysIdle),
+			strconv.Itoa(c.Members),
+			strconv.Itoa(c.Posts),
+			strconv.Itoa(c.RecentPosts),
+			strconv.Itoa(c.PriorPosts),
+			c.LastActive,
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
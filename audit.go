@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditEntry is an entry of the Slack Audit Logs API (Enterprise
+// Grid), saved as the API responses or as an array of entries.
+type AuditEntry struct {
+	ID         string `json:"id"`
+	DateCreate int64  `json:"date_create"`
+	Action     string `json:"action"`
+	Actor      struct {
+		Type string `json:"type"`
+		User struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"user"`
+	} `json:"actor"`
+	Entity struct {
+		Type string `json:"type"`
+	} `json:"entity"`
+	Context struct {
+		Location struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"location"`
+	} `json:"context"`
+}
+
+// Categories of admin actions in the audit report.
+const (
+	auditChannelCreation = "channel_creation"
+	auditUserInvite      = "user_invite"
+	auditAppInstall      = "app_install"
+	auditOther           = "other"
+)
+
+// auditCategories maps audit log actions to their category. Other
+// actions are reported under "other".
+var auditCategories = map[string]string{
+	"public_channel_created":    auditChannelCreation,
+	"private_channel_created":   auditChannelCreation,
+	"mpim_converted_to_private": auditChannelCreation,
+	"user_created":              auditUserInvite,
+	"guest_created":             auditUserInvite,
+	"invite_sent":               auditUserInvite,
+	"user_added_to_team":        auditUserInvite,
+	"app_installed":             auditAppInstall,
+	"app_approved":              auditAppInstall,
+	"app_restricted":            auditAppInstall,
+	"app_uninstalled":           auditAppInstall,
+	"app_scopes_expanded":       auditAppInstall,
+	"bot_token_upgraded":        auditAppInstall,
+}
+
+// AuditCount is the number of an action in a period.
+type AuditCount struct {
+	Period    string
+	Workspace string
+	Category  string
+	Action    string
+	Events    int
+	Actors    map[string]bool
+}
+
+// runAudit implements the audit subcommand, which reports the admin
+// actions of Slack audit logs over time.
+func runAudit(args []string) {
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	period := flags.String("period", "month", "period actions are counted over: day, week or month")
+	output := flags.String("o", "", "output file (default NAME_audit.csv)")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Println(tr("Error: No audit log specified. The correct usage is `go run . audit [FLAGS] FILE_OR_DIR...`."))
+		return
+	}
+	if *period != "day" && *period != "week" && *period != "month" {
+		fmt.Println(tr("Error: -period must be day, week or month."))
+		return
+	}
+
+	var entries []AuditEntry
+	for _, path := range flags.Args() {
+		loaded, err := loadAuditLogs(path)
+		if err != nil {
+			fmt.Println(tr("Error loading %s:", path), err)
+			return
+		}
+		entries = append(entries, loaded...)
+	}
+
+	fileName := *output
+	if fileName == "" {
+		fileName = outputFileName(strings.TrimSuffix(flags.Arg(0), ".json"))
+		fileName = strings.TrimSuffix(fileName, ".csv") + "_audit.csv"
+	}
+	err := exportAuditCSV(fileName, countAuditActions(entries, *period))
+	if err != nil {
+		fmt.Println(tr("Error exporting %s:", fileName), err)
+		return
+	}
+	fmt.Println(fileName, tr(" file created successfully."))
+}
+
+// loadAuditLogs loads the entries of an audit log file, or of every
+// JSON file of a directory. Entries repeated across files, as when
+// pages overlap, are kept once.
+func loadAuditLogs(path string) ([]AuditEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	var entries []AuditEntry
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Entries []AuditEntry `json:"entries"`
+		}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(data, &page.Entries)
+		} else {
+			err = json.Unmarshal(data, &page)
+		}
+		if err != nil {
+			return nil, jsonError(file, data, err)
+		}
+		for _, e := range page.Entries {
+			if e.ID != "" && seen[e.ID] {
+				continue
+			}
+			seen[e.ID] = true
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// countAuditActions counts the entries per period, workspace and
+// action.
+func countAuditActions(entries []AuditEntry, period string) []*AuditCount {
+	counts := make(map[string]*AuditCount)
+	for _, e := range entries {
+		if e.Action == "" || e.DateCreate == 0 {
+			continue
+		}
+		t := time.Unix(e.DateCreate, 0)
+		var p string
+		switch period {
+		case "day":
+			p = t.Format("2006-01-02")
+		case "week":
+			p = weekStart(t).Format("2006-01-02")
+		default:
+			p = t.Format("2006-01")
+		}
+		workspace := e.Context.Location.Name
+		if e.Context.Location.Type == "enterprise" {
+			workspace = ""
+		}
+
+		key := p + "/" + workspace + "/" + e.Action
+		c, ok := counts[key]
+		if !ok {
+			category, ok := auditCategories[e.Action]
+			if !ok {
+				category = auditOther
+			}
+			c = &AuditCount{
+				Period:    p,
+				Workspace: workspace,
+				Category:  category,
+				Action:    e.Action,
+				Actors:    make(map[string]bool),
+			}
+			counts[key] = c
+		}
+		c.Events++
+		if e.Actor.User.ID != "" {
+			c.Actors[e.Actor.User.ID] = true
+		}
+	}
+
+	result := make([]*AuditCount, 0, len(counts))
+	for _, c := range counts {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.Workspace != b.Workspace {
+			return a.Workspace < b.Workspace
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return a.Action < b.Action
+	})
+	return result
+}
+
+func exportAuditCSV(fileName string, counts []*AuditCount) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	err = writer.Write([]string{
+		"period",
+		"workspace",
+		"category",
+		"action",
+		"events",
+		"actors",
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range counts {
+		err := writer.Write([]string{
+			c.Period,
+			c.Workspace,
+			c.Category,
+			c.Action,
+			strconv.Itoa(c.Events),
+			strconv.Itoa(len(c.Actors)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// avroBlockSize is the number of rows per block of Avro files.
+const avroBlockSize = 1000
+
+// renderTable writes a table with write, one of the CSV writers of the
+// outputs, and returns its header and rows.
+func renderTable(write func(io.Writer) error) ([]string, [][]string, error) {
+	var buf bytes.Buffer
+	err := write(&buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, nil, err
+	}
+	return rows[0], rows[1:], nil
+}
+
+// exportAvro writes the table of write as an Avro object container
+// file, with its schema embedded. The record is named after the
+// output kind of fileName. With -schema-registry, the schema is also
+// registered under the file name.
+func exportAvro(fileName string, write func(io.Writer) error) error {
+	header, rows, err := renderTable(write)
+	if err != nil {
+		return err
+	}
+	fields := make([]string, len(header))
+	for i, column := range header {
+		fields[i] = avroName(column)
+	}
+	schema, err := avroSchema(outputKind(fileName), header, fields)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+
+	sync := make([]byte, 16)
+	rand.Read(sync)
+	var buf []byte
+	buf = append(buf, 'O', 'b', 'j', 1)
+	buf = appendLong(buf, 2)
+	buf = appendString(buf, "avro.schema")
+	buf = appendString(buf, schema)
+	buf = appendString(buf, "avro.codec")
+	buf = appendString(buf, "null")
+	buf = appendLong(buf, 0)
+	buf = append(buf, sync...)
+	_, err = w.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(rows); start += avroBlockSize {
+		end := start + avroBlockSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var block []byte
+		for n, row := range rows[start:end] {
+			for i, column := range header {
+				block, err = appendAvroValue(block, column, row[i])
+				if err != nil {
+					return fmt.Errorf("row %d: %v", start+n+1, err)
+				}
+			}
+		}
+		buf = appendLong(buf[:0], int64(end-start))
+		buf = appendLong(buf, int64(len(block)))
+		buf = append(buf, block...)
+		buf = append(buf, sync...)
+		_, err = w.Write(buf)
+		if err != nil {
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if *registryURL != "" {
+		subject := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+		id, err := registerSchema(*registryURL, subject, schema)
+		if err != nil {
+			return err
+		}
+		fmt.Printf(tr("Schema of %s registered as %s with ID %d.\n"), fileName, subject, id)
+	}
+	return nil
+}
+
+// appendAvroValue appends the value of column in the binary encoding
+// of its nullable field: the branch of the union, then the value.
+func appendAvroValue(buf []byte, column, value string) ([]byte, error) {
+	v := typedValue(column, value)
+	if v == nil {
+		return appendLong(buf, 0), nil
+	}
+	buf = appendLong(buf, 1)
+	switch v := v.(type) {
+	case int64:
+		return appendLong(buf, v), nil
+	case float64:
+		return appendUint(buf, math.Float64bits(v), 8), nil
+	case bool:
+		if v {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	}
+	if avroType(column) != "string" {
+		return nil, fmt.Errorf("%s is not a %s: %s", column, avroType(column), value)
+	}
+	return appendString(buf, value), nil
+}
+
+// appendLong appends n as a zigzag varint.
+func appendLong(buf []byte, n int64) []byte {
+	return appendUvarint(buf, uint64((n<<1)^(n>>63)))
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendLong(buf, int64(len(s)))
+	return append(buf, s...)
+}
+
+// registerSchema registers schema under subject in the Confluent
+// schema registry at url and returns its ID.
+func registerSchema(url, subject, schema string) (int, error) {
+	data, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, err
+	}
+	url = strings.TrimSuffix(url, "/") + "/subjects/" + subject + "/versions"
+	resp, err := http.Post(url, "application/vnd.schemaregistry.v1+json", bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("%s: %s %s", url, resp.Status, result.Message)
+	}
+	return result.ID, nil
+}
+
+// typedValue converts value to the type of its column, or nil if it
+// is empty. Values not of the type are kept as strings.
+func typedValue(column, value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	switch columnType(column) {
+	case typeInteger:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case typeNumber:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case typeBoolean:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// avroType returns the Avro type of a column.
+func avroType(column string) string {
+	switch columnType(column) {
+	case typeInteger:
+		return "long"
+	case typeNumber:
+		return "double"
+	case typeBoolean:
+		return "boolean"
+	}
+	return "string"
+}
+
+// avroSchema returns the Avro record schema of the rows of kind, with
+// every field nullable.
+func avroSchema(kind string, header, fields []string) (string, error) {
+	schemaFields := make([]map[string]interface{}, len(header))
+	for i, column := range header {
+		schemaFields[i] = map[string]interface{}{
+			"name":    fields[i],
+			"type":    []string{"null", avroType(column)},
+			"default": nil,
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"type":      "record",
+		"name":      avroName(kind),
+		"namespace": "slack_analytics",
+		"fields":    schemaFields,
+	})
+	return string(data), err
+}
+
+// avroName makes name a valid Avro name, replacing other characters
+// than letters, digits and "_" by "_".
+func avroName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
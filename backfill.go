@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// slackAPIURL is the base URL of the Slack Web API.
+var slackAPIURL = "https://slack.com/api/"
+
+// backfill counts the messages of the export at path, and returns the
+// ts of the latest message of each channel key. Replies missing from
+// the export are credited as in batch runs; its threads are tracked
+// while it is counted only.
+func (s *liveStore) backfill(path string) (map[string]string, error) {
+	workspaces, err := findWorkspaces(longPath(path))
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.threads = newThreadTracker()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.threads = nil
+		s.mu.Unlock()
+	}()
+	latest := make(map[string]string)
+	for _, ws := range workspaces {
+		files, err := channelFiles(ws)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			messages, err := readMessagesFromJSONFile(f.path)
+			if err != nil {
+				return nil, err
+			}
+			s.mu.Lock()
+			for _, message := range messages {
+				s.record(f.channelName, message)
+				if message.Timestamp > latest[f.channelName] {
+					latest[f.channelName] = message.Timestamp
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+
+	s.mu.Lock()
+	credited := s.threads.creditMissingReplies(s.stats, s.users)
+	s.mu.Unlock()
+	if credited > 0 {
+		fmt.Printf(tr("Replies missing from the export, counted from reply_count: %d\n"), credited)
+	}
+	return latest, nil
+}
+
+// catchUp counts the messages posted since the export with the Web API,
+// from the latest message of each channel in the export, or of the
+// whole export for channels without messages. Thread replies are
+// fetched for the threads started since then. Messages counted already,
+// by the export or by events received meanwhile, are skipped.
+func (s *liveStore) catchUp(token string, latest map[string]string) {
+	var boundary string
+	for _, ts := range latest {
+		if ts > boundary {
+			boundary = ts
+		}
+	}
+
+	s.mu.Lock()
+	channels := make(map[string]string, len(s.channels))
+	for id, key := range s.channels {
+		channels[id] = key
+	}
+	s.mu.Unlock()
+
+	count := 0
+	for id, key := range channels {
+		oldest, ok := latest[key]
+		if !ok {
+			oldest = boundary
+		}
+		messages, err := fetchMessages(token, "conversations.history", url.Values{"channel": {id}, "oldest": {oldest}})
+		if err != nil {
+			fmt.Println(tr("Error fetching messages of %s:", key), err)
+			continue
+		}
+		for _, message := range messages {
+			if message.ReplyCount == 0 {
+				continue
+			}
+			replies, err := fetchMessages(token, "conversations.replies", url.Values{"channel": {id}, "ts": {message.Timestamp}, "oldest": {oldest}})
+			if err != nil {
+				fmt.Println(tr("Error fetching replies of %s:", key), err)
+				continue
+			}
+			messages = append(messages, replies...)
+		}
+
+		s.mu.Lock()
+		for _, message := range messages {
+			if _, ok := s.posted[key+"/"+message.Timestamp]; message.Timestamp > oldest && !ok {
+				s.record(key, message)
+				count++
+			}
+		}
+		s.mu.Unlock()
+	}
+	fmt.Println(tr("Caught up %d messages posted since the export", count))
+}
+
+// fetchMessages calls a Web API method listing messages, following the
+// pages of results. Rate limited calls are retried after the delay
+// Slack asks for.
+func fetchMessages(token, method string, params url.Values) ([]Message, error) {
+	var messages []Message
+	params.Set("limit", "200")
+	client := &http.Client{Timeout: time.Minute}
+	for {
+		req, err := http.NewRequest("GET", slackAPIURL+method+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			delay, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+			if err != nil {
+				delay = 1
+			}
+			time.Sleep(time.Duration(delay) * time.Second)
+			continue
+		}
+
+		var result struct {
+			OK       bool      `json:"ok"`
+			Error    string    `json:"error"`
+			Messages []Message `json:"messages"`
+			Metadata struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"response_metadata"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if !result.OK {
+			return nil, errors.New(method + ": " + result.Error)
+		}
+		messages = append(messages, result.Messages...)
+		if result.Metadata.NextCursor == "" {
+			return messages, nil
+		}
+		params.Set("cursor", result.Metadata.NextCursor)
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bigQueryURL is the base URL of the BigQuery API.
+var bigQueryURL = "https://bigquery.googleapis.com"
+
+const bigQueryScope = "https://www.googleapis.com/auth/bigquery"
+
+// bigQueryTypes maps Table Schema column types to BigQuery types.
+var bigQueryTypes = map[string]string{
+	typeString:    "STRING",
+	typeInteger:   "INTEGER",
+	typeNumber:    "FLOAT",
+	typeBoolean:   "BOOLEAN",
+	typeDate:      "DATE",
+	typeDatetime:  "TIMESTAMP",
+	typeYearMonth: "STRING",
+}
+
+// bigQuerySQLTypes maps the types of the API to their names in SQL.
+var bigQuerySQLTypes = map[string]string{
+	"STRING":    "STRING",
+	"INTEGER":   "INT64",
+	"FLOAT":     "FLOAT64",
+	"BOOLEAN":   "BOOL",
+	"DATE":      "DATE",
+	"TIMESTAMP": "TIMESTAMP",
+}
+
+type bigQueryClient struct {
+	project string
+	token   string
+	client  *http.Client
+}
+
+// newBigQueryClient authenticates with googleToken. The project is the
+// one of dataset if given as PROJECT.DATASET, else that of the
+// credentials or $GOOGLE_CLOUD_PROJECT.
+func newBigQueryClient(dataset string) (*bigQueryClient, string, error) {
+	token, project, err := googleToken(bigQueryScope)
+	if err != nil {
+		return nil, "", err
+	}
+	if i := strings.Index(dataset, "."); i >= 0 {
+		project, dataset = dataset[:i], dataset[i+1:]
+	}
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if project == "" {
+		return nil, "", errors.New("no project; give -bq-dataset as PROJECT.DATASET")
+	}
+	return &bigQueryClient{project: project, token: token, client: &http.Client{Timeout: 5 * time.Minute}}, dataset, nil
+}
+
+// loadBigQuery loads the daily table of write into dataset.table,
+// creating it partitioned by day and clustered by channel. The rows are
+// loaded into a staging table first, then replace the rows of the same
+// days and channels of the table in one transaction, so that runs over
+// the same period replace their rows and a failed load changes nothing.
+func loadBigQuery(dataset, table string, write func(io.Writer) error) (int, error) {
+	header, rows, err := renderTable(write)
+	if err != nil {
+		return 0, err
+	}
+	day := -1
+	for i, column := range header {
+		if column == "day" {
+			day = i
+		}
+	}
+	if day < 0 {
+		return 0, errors.New("no day column to partition by")
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	c, dataset, err := newBigQueryClient(dataset)
+	if err != nil {
+		return 0, err
+	}
+	fields := bigQueryFields(header)
+	_, err = c.createTable(dataset, table, fields)
+	if err != nil {
+		return 0, err
+	}
+	// A unique name, so that concurrent runs do not load into each
+	// other's staging table
+	staging := fmt.Sprintf("%s_staging_%d", table, time.Now().UnixNano())
+	err = c.createStagingTable(dataset, staging, fields)
+	if err != nil {
+		return 0, err
+	}
+
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	for _, row := range rows {
+		record := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			if v := typedValue(column, row[i]); v != nil {
+				record[avroName(column)] = v
+			}
+		}
+		err := encoder.Encode(record)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = c.runJob(map[string]interface{}{
+		"load": map[string]interface{}{
+			"destinationTable": c.tableReference(dataset, staging),
+			"schema":           map[string]interface{}{"fields": fields},
+			"sourceFormat":     "NEWLINE_DELIMITED_JSON",
+			"writeDisposition": "WRITE_TRUNCATE",
+		},
+	}, data.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	first, last := rows[0][day], rows[0][day]
+	for _, row := range rows {
+		if row[day] < first {
+			first = row[day]
+		}
+		if row[day] > last {
+			last = row[day]
+		}
+	}
+	name := func(table string) string {
+		return fmt.Sprintf("`%s.%s.%s`", c.project, dataset, table)
+	}
+	err = c.runJob(map[string]interface{}{
+		"query": map[string]interface{}{
+			"query":        bigQueryScript(name(table), name(staging), header, first, last),
+			"useLegacySql": false,
+		},
+	}, nil)
+	return len(rows), err
+}
+
+// bigQueryScript returns the statements moving the rows of staging
+// into table. Columns of new flags are added to table, then the rows of
+// the days and channels of staging are replaced in a transaction.
+func bigQueryScript(table, staging string, header []string, first, last string) string {
+	var b strings.Builder
+	columns := make([]string, len(header))
+	additions := make([]string, len(header))
+	for i, column := range header {
+		columns[i] = avroName(column)
+		additions[i] = "ADD COLUMN IF NOT EXISTS " + columns[i] + " " + bigQuerySQLTypes[bigQueryTypes[columnType(column)]]
+	}
+	// A single statement, as BigQuery limits the updates of a table
+	fmt.Fprintf(&b, "ALTER TABLE %s\n  %s;\n", table, strings.Join(additions, ",\n  "))
+	keys := []string{"day", "channel_name"}
+	for _, column := range header {
+		if column == "workspace" {
+			keys = append(keys, column)
+		}
+	}
+	match := make([]string, len(keys))
+	for i, key := range keys {
+		match[i] = "s." + key + " = t." + key
+	}
+
+	fmt.Fprintf(&b, "BEGIN TRANSACTION;\n")
+	// The day range lets BigQuery prune the partitions to scan
+	fmt.Fprintf(&b, "DELETE FROM %s t WHERE t.day BETWEEN '%s' AND '%s'\n", table, first, last)
+	fmt.Fprintf(&b, "  AND EXISTS (SELECT 1 FROM %s s WHERE %s);\n", staging, strings.Join(match, " AND "))
+	fmt.Fprintf(&b, "INSERT INTO %s (%s)\n", table, strings.Join(columns, ", "))
+	fmt.Fprintf(&b, "  SELECT %s FROM %s;\n", strings.Join(columns, ", "), staging)
+	fmt.Fprintf(&b, "COMMIT TRANSACTION;\n")
+	fmt.Fprintf(&b, "DROP TABLE %s;\n", staging)
+	return b.String()
+}
+
+func (c *bigQueryClient) tableReference(dataset, table string) map[string]string {
+	return map[string]string{"projectId": c.project, "datasetId": dataset, "tableId": table}
+}
+
+// bigQueryFields returns the schema of a nullable field per column.
+func bigQueryFields(header []string) []map[string]string {
+	fields := make([]map[string]string, len(header))
+	for i, column := range header {
+		fields[i] = map[string]string{
+			"name": avroName(column),
+			"type": bigQueryTypes[columnType(column)],
+			"mode": "NULLABLE",
+		}
+	}
+	return fields
+}
+
+// createStagingTable creates the table with fields, expiring after a
+// day so that it is removed even if the load does not complete.
+func (c *bigQueryClient) createStagingTable(dataset, table string, fields []map[string]string) error {
+	resource := map[string]interface{}{
+		"tableReference": c.tableReference(dataset, table),
+		"schema":         map[string]interface{}{"fields": fields},
+		"expirationTime": strconv.FormatInt(time.Now().Add(24*time.Hour).UnixNano()/int64(time.Millisecond), 10),
+	}
+	_, err := c.call("POST", "/bigquery/v2/projects/"+c.project+"/datasets/"+dataset+"/tables", resource, nil)
+	return err
+}
+
+// createTable creates the table with fields, and reports false if it
+// already exists.
+func (c *bigQueryClient) createTable(dataset, table string, fields []map[string]string) (bool, error) {
+	resource := map[string]interface{}{
+		"tableReference":   c.tableReference(dataset, table),
+		"schema":           map[string]interface{}{"fields": fields},
+		"timePartitioning": map[string]string{"type": "DAY", "field": "day"},
+		"clustering":       map[string][]string{"fields": {"channel_name"}},
+	}
+	status, err := c.call("POST", "/bigquery/v2/projects/"+c.project+"/datasets/"+dataset+"/tables", resource, nil)
+	if status == http.StatusConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// runJob runs a job of configuration, uploading data for loads, and
+// waits until it is done.
+func (c *bigQueryClient) runJob(configuration map[string]interface{}, data []byte) error {
+	job := map[string]interface{}{"configuration": configuration}
+	var result struct {
+		JobReference struct {
+			JobID    string `json:"jobId"`
+			Location string `json:"location"`
+		} `json:"jobReference"`
+		Status struct {
+			State       string `json:"state"`
+			ErrorResult *struct {
+				Message string `json:"message"`
+			} `json:"errorResult"`
+		} `json:"status"`
+	}
+
+	var err error
+	if data == nil {
+		_, err = c.call("POST", "/bigquery/v2/projects/"+c.project+"/jobs", job, &result)
+	} else {
+		_, err = c.upload(job, data, &result)
+	}
+	for err == nil && result.Status.State != "DONE" {
+		time.Sleep(time.Second)
+		path := "/bigquery/v2/projects/" + c.project + "/jobs/" + result.JobReference.JobID +
+			"?location=" + url.QueryEscape(result.JobReference.Location)
+		_, err = c.call("GET", path, nil, &result)
+	}
+	if err != nil {
+		return err
+	}
+	if result.Status.ErrorResult != nil {
+		return errors.New(result.Status.ErrorResult.Message)
+	}
+	return nil
+}
+
+// upload inserts job with data in a multipart upload.
+func (c *bigQueryClient) upload(job interface{}, data []byte, result interface{}) (int, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json"}})
+	if err != nil {
+		return 0, err
+	}
+	err = json.NewEncoder(part).Encode(job)
+	if err != nil {
+		return 0, err
+	}
+	part, err = mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return 0, err
+	}
+	part.Write(data)
+	mw.Close()
+
+	path := "/upload/bigquery/v2/projects/" + c.project + "/jobs?uploadType=multipart"
+	return c.send("POST", path, "multipart/related; boundary="+mw.Boundary(), &body, result)
+}
+
+// call sends body as JSON and decodes the response into result.
+func (c *bigQueryClient) call(method, path string, body, result interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	return c.send(method, path, "application/json", reader, result)
+}
+
+func (c *bigQueryClient) send(method, path, contentType string, body io.Reader, result interface{}) (int, error) {
+	req, err := http.NewRequest(method, bigQueryURL+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return resp.StatusCode, fmt.Errorf("%s %s: %s %s", method, path, resp.Status, failure.Error.Message)
+	}
+	if result != nil {
+		return resp.StatusCode, json.NewDecoder(resp.Body).Decode(result)
+	}
+	return resp.StatusCode, nil
+}
+
+// googleCredentials holds a service account key or the user
+// credentials of `gcloud auth application-default login`.
+type googleCredentials struct {
+	Type           string `json:"type"`
+	ProjectID      string `json:"project_id"`
+	QuotaProjectID string `json:"quota_project_id"`
+	ClientEmail    string `json:"client_email"`
+	PrivateKey     string `json:"private_key"`
+	TokenURI       string `json:"token_uri"`
+	ClientID       string `json:"client_id"`
+	ClientSecret   string `json:"client_secret"`
+	RefreshToken   string `json:"refresh_token"`
+}
+
+// googleToken returns an access token for scope and the project of
+// the credentials, if known. The token is $GOOGLE_OAUTH_ACCESS_TOKEN if
+// set, else obtained with the credentials file of
+// $GOOGLE_APPLICATION_CREDENTIALS or of gcloud.
+func googleToken(scope string) (string, string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, "", nil
+	}
+	fileName := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if fileName == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+		fileName = filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return "", "", fmt.Errorf("no Google credentials: %v", err)
+	}
+	var creds googleCredentials
+	err = json.Unmarshal(data, &creds)
+	if err != nil {
+		return "", "", err
+	}
+
+	form := url.Values{}
+	tokenURI := creds.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+	switch creds.Type {
+	case "service_account":
+		assertion, err := signJWT(&creds, scope, tokenURI)
+		if err != nil {
+			return "", "", err
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", creds.ClientID)
+		form.Set("client_secret", creds.ClientSecret)
+		form.Set("refresh_token", creds.RefreshToken)
+	default:
+		return "", "", errors.New("unsupported credentials type " + creds.Type + " in " + fileName)
+	}
+
+	resp, err := http.PostForm(tokenURI, form)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ErrorDescription string `json:"error_description"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode/100 != 2 || result.AccessToken == "" {
+		return "", "", fmt.Errorf("%s: %s %s", tokenURI, resp.Status, result.ErrorDescription)
+	}
+	project := creds.ProjectID
+	if project == "" {
+		project = creds.QuotaProjectID
+	}
+	return result.AccessToken, project, nil
+}
+
+// signJWT returns the assertion exchanged for a token of a service
+// account, signed with its key.
+func signJWT(creds *googleCredentials, scope, audience string) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", errors.New("invalid private key of " + creds.ClientEmail)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("private key of " + creds.ClientEmail + " is not RSA")
+	}
+
+	now := time.Now().Unix()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": scope,
+		"aud":   audience,
+		"iat":   now,
+		"exp":   now + 3600,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
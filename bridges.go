@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bridge is the share of the interactions of a user that cross
+// department boundaries, in the -user-attrs departments.
+type Bridge struct {
+	UserID     string
+	Department string
+	// Interactions counts the interactions with users of a known
+	// department, both ways.
+	Interactions int
+	// CrossDepartment counts those with users of another department.
+	CrossDepartment int
+	// Departments are the other departments interacted with.
+	Departments map[string]bool
+}
+
+// Share is the share of the interactions crossing departments.
+func (b *Bridge) Share() float64 {
+	if b.Interactions == 0 {
+		return 0
+	}
+	return float64(b.CrossDepartment) / float64(b.Interactions)
+}
+
+// findBridges returns the users of a known department with
+// interactions crossing departments, most crossing interactions first.
+// Interactions with users without a department, and those of kinds
+// making no edges of the interaction graph, are left out.
+func findBridges(interactions []interaction, users map[string]*User) []*Bridge {
+	department := func(userID string) string {
+		if u := lookupUser(users, userID); u != nil && u.Attrs != nil {
+			return u.Attrs.Department
+		}
+		return ""
+	}
+
+	byUser := make(map[string]*Bridge)
+	count := func(userID, from, to string) {
+		b, ok := byUser[userID]
+		if !ok {
+			b = &Bridge{UserID: userID, Department: from, Departments: make(map[string]bool)}
+			byUser[userID] = b
+		}
+		b.Interactions++
+		if from != to {
+			b.CrossDepartment++
+			b.Departments[to] = true
+		}
+	}
+	for _, i := range interactions {
+		from, to := department(i.From), department(i.To)
+		if from == "" || to == "" || edgeWeights[i.Kind] == 0 {
+			continue
+		}
+		count(i.From, from, to)
+		count(i.To, to, from)
+	}
+
+	var result []*Bridge
+	for _, b := range byUser {
+		if b.CrossDepartment > 0 {
+			result = append(result, b)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CrossDepartment != result[j].CrossDepartment {
+			return result[i].CrossDepartment > result[j].CrossDepartment
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result
+}
+
+func exportBridgesCSV(fileName string, bridges []*Bridge, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"display_name",
+		"name",
+		"department",
+		"interactions",
+		"cross_department_interactions",
+		"cross_department_share",
+		"departments_reached",
+		"bridge",
+	}
+	if *includeEmail {
+		header = append(header, "email")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range bridges {
+		var displayName, name, email string
+		if u := lookupUser(users, b.UserID); u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+			email = u.Profile.Email
+		}
+		row := []string{
+			displayName,
+			name,
+			b.Department,
+			strconv.Itoa(b.Interactions),
+			strconv.Itoa(b.CrossDepartment),
+			formatFloat(b.Share()),
+			strconv.Itoa(len(b.Departments)),
+			strconv.FormatBool(b.Share() > 0.5),
+		}
+		if *includeEmail {
+			row = append(row, email)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// otherGroup is the team of the users of teams smaller than
+	// -min-group-size.
+	otherGroup = "other"
+	// quickReply is the longest delay of a reply counted as quick.
+	quickReply = 10 * time.Minute
+	// trendWindow is the recent period whose posts are compared with
+	// the earlier average for the volume trend.
+	trendWindow = 28
+)
+
+// burnoutVariables are the names usable in -burnout-expr.
+var burnoutVariables = []string{
+	"after_hours",
+	"weekend",
+	"quick_replies",
+	"reply_minutes",
+	"trend",
+	"posts",
+}
+
+// BurnoutSignals are the indicators of overwork of a user, or their
+// averages over a team.
+type BurnoutSignals struct {
+	Group string
+	Users int
+	// AfterHours and Weekend are the shares of posts outside work
+	// hours on working days and on the other days, in the user's time
+	// zone and work week.
+	AfterHours float64
+	Weekend    float64
+	// QuickReplies is the share of thread replies posted within
+	// quickReply of the previous message, and ReplyMinutes the median
+	// delay.
+	QuickReplies float64
+	ReplyMinutes float64
+	// Trend is the relative change of the posts of the last trendWindow
+	// days against the average of the same period before.
+	Trend float64
+	Posts float64
+	Score float64
+}
+
+func (b *BurnoutSignals) vars() map[string]float64 {
+	return map[string]float64{
+		"after_hours":   b.AfterHours,
+		"weekend":       b.Weekend,
+		"quick_replies": b.QuickReplies,
+		"reply_minutes": b.ReplyMinutes,
+		"trend":         b.Trend,
+		"posts":         b.Posts,
+	}
+}
+
+// parseWorkHours parses hours such as "9-18" into the first hour of
+// work and the first hour after it.
+func parseWorkHours(value string) (int, int, error) {
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) == 2 {
+		start, err1 := strconv.Atoi(parts[0])
+		end, err2 := strconv.Atoi(parts[1])
+		if err1 == nil && err2 == nil && start >= 0 && start < end && end <= 24 {
+			return start, end, nil
+		}
+	}
+	return 0, 0, errors.New("-work-hours must be START-END such as 9-18, got " + value)
+}
+
+// userLocation returns the time zone of the profile of u, or the local
+// time zone.
+func userLocation(u *User, cache map[string]*time.Location) *time.Location {
+	if u == nil || u.Tz == "" {
+		return time.Local
+	}
+	loc, ok := cache[u.Tz]
+	if !ok {
+		var err error
+		loc, err = time.LoadLocation(u.Tz)
+		if err != nil {
+			loc = time.Local
+		}
+		cache[u.Tz] = loc
+	}
+	return loc
+}
+
+// burnoutSignals computes the signals of every user who posted, and
+// their score by formula. Bots and users below -min-posts or
+// -min-activity are left out.
+func burnoutSignals(messagesByChannel map[string][]Message, users map[string]*User, startHour, endHour int, formula expr) map[string]*BurnoutSignals {
+	type post struct {
+		t    time.Time
+		user string
+	}
+	posts := make(map[string][]time.Time)
+	delays := make(map[string][]time.Duration)
+	var last time.Time
+	for _, messages := range messagesByChannel {
+		threads := make(map[string][]post)
+		for _, message := range messages {
+			if message.User == "" || message.BotID != "" || lookupUser(users, message.User) == nil {
+				continue
+			}
+			t, err := parseTimestamp(message.Timestamp)
+			if err != nil {
+				continue
+			}
+			posts[message.User] = append(posts[message.User], t)
+			if t.After(last) {
+				last = t
+			}
+			if message.ThreadTimestamp != "" {
+				threads[message.ThreadTimestamp] = append(threads[message.ThreadTimestamp], post{t, message.User})
+			}
+		}
+		for _, thread := range threads {
+			sort.Slice(thread, func(i, j int) bool { return thread[i].t.Before(thread[j].t) })
+			for i := 1; i < len(thread); i++ {
+				if thread[i].user != thread[i-1].user {
+					delays[thread[i].user] = append(delays[thread[i].user], thread[i].t.Sub(thread[i-1].t))
+				}
+			}
+		}
+	}
+
+	locations := make(map[string]*time.Location)
+	recentStart := last.AddDate(0, 0, -trendWindow)
+	result := make(map[string]*BurnoutSignals)
+	for userID, times := range posts {
+		if lowActivity[userID] {
+			continue
+		}
+		b := &BurnoutSignals{Group: userID, Users: 1, Posts: float64(len(times))}
+		loc := userLocation(users[userID], locations)
+		week := userWorkWeek(users[userID])
+		first := times[0]
+		recent := 0
+		for _, t := range times {
+			local := t.In(loc)
+			switch {
+			case !week[local.Weekday()]:
+				b.Weekend++
+			case local.Hour() < startHour || local.Hour() >= endHour:
+				b.AfterHours++
+			}
+			if t.After(recentStart) {
+				recent++
+			}
+			if t.Before(first) {
+				first = t
+			}
+		}
+		b.AfterHours /= b.Posts
+		b.Weekend /= b.Posts
+		if windows := recentStart.Sub(first).Hours() / 24 / trendWindow; windows >= 1 {
+			baseline := float64(len(times)-recent) / windows
+			if baseline > 0 {
+				b.Trend = float64(recent)/baseline - 1
+			}
+		}
+
+		if d := delays[userID]; len(d) > 0 {
+			sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+			quick := sort.Search(len(d), func(i int) bool { return d[i] > quickReply })
+			b.QuickReplies = float64(quick) / float64(len(d))
+			b.ReplyMinutes = d[len(d)/2].Minutes()
+		}
+		b.Score = formula.eval(b.vars())
+		result[userID] = b
+	}
+	return result
+}
+
+// burnoutByTeam averages the signals of the users of each department,
+// or of everyone without -user-attrs. Teams of fewer than minGroup
+// users are bucketed as other, then teams of fewer than minTeam users
+// are left out, since their averages would tell about too few people.
+func burnoutByTeam(signals map[string]*BurnoutSignals, users map[string]*User, minTeam, minGroup int) []*BurnoutSignals {
+	teams := make(map[string]*BurnoutSignals)
+	for userID, b := range signals {
+		team := "all"
+		if *userAttrsFile != "" {
+			team = ""
+			if u := users[userID]; u != nil && u.Attrs != nil {
+				team = u.Attrs.Department
+			}
+		}
+		t, ok := teams[team]
+		if !ok {
+			t = &BurnoutSignals{Group: team}
+			teams[team] = t
+		}
+		t.Users++
+		t.AfterHours += b.AfterHours
+		t.Weekend += b.Weekend
+		t.QuickReplies += b.QuickReplies
+		t.ReplyMinutes += b.ReplyMinutes
+		t.Trend += b.Trend
+		t.Posts += b.Posts
+		t.Score += b.Score
+	}
+
+	for team, t := range teams {
+		if t.Users >= minGroup || team == otherGroup {
+			continue
+		}
+		other, ok := teams[otherGroup]
+		if !ok {
+			other = &BurnoutSignals{Group: otherGroup}
+			teams[otherGroup] = other
+		}
+		other.Users += t.Users
+		other.AfterHours += t.AfterHours
+		other.Weekend += t.Weekend
+		other.QuickReplies += t.QuickReplies
+		other.ReplyMinutes += t.ReplyMinutes
+		other.Trend += t.Trend
+		other.Posts += t.Posts
+		other.Score += t.Score
+		delete(teams, team)
+	}
+
+	var result []*BurnoutSignals
+	for _, t := range teams {
+		if t.Users < minTeam || t.Users < minGroup {
+			continue
+		}
+		n := float64(t.Users)
+		t.AfterHours /= n
+		t.Weekend /= n
+		t.QuickReplies /= n
+		t.ReplyMinutes /= n
+		t.Trend /= n
+		t.Posts /= n
+		t.Score /= n
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Group < result[j].Group })
+	return result
+}
+
+// burnoutByUser returns the signals of each user, highest score first.
+func burnoutByUser(signals map[string]*BurnoutSignals) []*BurnoutSignals {
+	result := make([]*BurnoutSignals, 0, len(signals))
+	for _, b := range signals {
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Group < result[j].Group
+	})
+	return result
+}
+
+// exportBurnoutCSV writes the signals of teams, or of users with
+// perUser, whose group is then a user ID.
+func exportBurnoutCSV(fileName string, rows []*BurnoutSignals, perUser bool, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{"team", "users"}
+	if perUser {
+		header = []string{"display_name", "name"}
+	}
+	header = append(header,
+		"after_hours_share",
+		"weekend_share",
+		"quick_reply_share",
+		"median_reply_minutes",
+		"posts_trend",
+		"posts",
+		"burnout_score",
+	)
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range rows {
+		row := []string{b.Group, strconv.Itoa(b.Users)}
+		if perUser {
+			row = []string{"", b.Group}
+			if u := lookupUser(users, b.Group); u != nil {
+				row = []string{strings.ReplaceAll(u.Profile.DisplayName, ",", " "), u.Name}
+			}
+		}
+		row = append(row,
+			formatFloat(b.AfterHours),
+			formatFloat(b.Weekend),
+			formatFloat(b.QuickReplies),
+			formatFloat(b.ReplyMinutes),
+			formatFloat(b.Trend),
+			formatFloat(b.Posts),
+			formatFloat(b.Score),
+		)
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Channel categories assigned by -channel-categories.
+const (
+	categorySocial        = "social"
+	categoryProject       = "project"
+	categorySupport       = "support"
+	categoryAnnouncements = "announcements"
+	categoryOther         = "other"
+)
+
+// categoryRule assigns Category to channels whose name matches the
+// glob Name, or whose topic or purpose contains the word Keyword.
+type categoryRule struct {
+	Category string
+	Name     string
+	Keyword  string
+}
+
+// defaultCategoryRules follow common naming conventions. They apply
+// after the rules of -channel-rules.
+var defaultCategoryRules = []categoryRule{
+	{Category: categoryAnnouncements, Name: "announce*"},
+	{Category: categoryAnnouncements, Name: "*-announce*"},
+	{Category: categoryAnnouncements, Name: "news*"},
+	{Category: categorySupport, Name: "help*"},
+	{Category: categorySupport, Name: "*-help"},
+	{Category: categorySupport, Name: "support*"},
+	{Category: categorySupport, Name: "*-support"},
+	{Category: categoryProject, Name: "proj-*"},
+	{Category: categoryProject, Name: "project-*"},
+	{Category: categoryProject, Name: "prj-*"},
+	{Category: categorySocial, Name: "random"},
+	{Category: categorySocial, Name: "social*"},
+	{Category: categorySocial, Name: "fun-*"},
+	{Category: categorySocial, Name: "off-topic*"},
+	{Category: categoryAnnouncements, Keyword: "announcements"},
+	{Category: categoryAnnouncements, Keyword: "announcement"},
+	{Category: categorySupport, Keyword: "support"},
+	{Category: categorySupport, Keyword: "help"},
+	{Category: categoryProject, Keyword: "project"},
+	{Category: categorySocial, Keyword: "social"},
+	{Category: categorySocial, Keyword: "fun"},
+}
+
+// channelCategories holds the category of each channel key, or nil
+// without -channel-categories.
+var channelCategories map[string]string
+
+// loadCategoryRules reads rules, one per line as "CATEGORY name:GLOB"
+// or "CATEGORY keyword:WORD". Blank lines and lines starting with #
+// are ignored.
+func loadCategoryRules(fileName string) ([]categoryRule, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []categoryRule
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		invalid := errors.New("invalid rule on line " + strconv.Itoa(n) + ": " + line)
+		if len(fields) != 2 {
+			return nil, invalid
+		}
+		rule := categoryRule{Category: fields[0]}
+		switch {
+		case strings.HasPrefix(fields[1], "name:"):
+			rule.Name = strings.TrimPrefix(fields[1], "name:")
+			if _, err := path.Match(rule.Name, ""); err != nil {
+				return nil, invalid
+			}
+		case strings.HasPrefix(fields[1], "keyword:"):
+			rule.Keyword = strings.ToLower(strings.TrimPrefix(fields[1], "keyword:"))
+		default:
+			return nil, invalid
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// classifyChannel returns the category of the first rule matching the
+// channel: name rules are tried before keyword rules.
+func classifyChannel(channelName string, channel *Channel, rules []categoryRule) string {
+	for _, rule := range rules {
+		if rule.Name == "" {
+			continue
+		}
+		if ok, _ := path.Match(rule.Name, channelName); ok {
+			return rule.Category
+		}
+	}
+
+	if channel == nil {
+		return categoryOther
+	}
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(channel.Topic.Value+" "+channel.Purpose.Value), func(r rune) bool {
+		return !(r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 0x7f)
+	}) {
+		words[w] = true
+	}
+	for _, rule := range rules {
+		if rule.Keyword != "" && words[rule.Keyword] {
+			return rule.Category
+		}
+	}
+	return categoryOther
+}
+
+// classifyChannels returns the category of every channel of the stats.
+func classifyChannels(statsByChannel StatsByChannel, channels map[string]*Channel, rules []categoryRule) map[string]string {
+	categories := make(map[string]string)
+	for key := range statsByChannel {
+		_, channelName := splitChannelKey(key)
+		categories[key] = classifyChannel(channelName, channels[key], rules)
+	}
+	return categories
+}
+
+// CategoryRollup totals the channels of one category.
+type CategoryRollup struct {
+	Category  string
+	Channels  int
+	Posts     int
+	Reactions int
+	Replies   int
+	Posters   map[string]bool
+}
+
+func rollupCategories(statsByChannel StatsByChannel) []*CategoryRollup {
+	byCategory := make(map[string]*CategoryRollup)
+	for key, ud := range statsByChannel {
+		category := channelCategories[key]
+		r, ok := byCategory[category]
+		if !ok {
+			r = &CategoryRollup{Category: category, Posters: make(map[string]bool)}
+			byCategory[category] = r
+		}
+		r.Channels++
+		for _, us := range ud {
+			for userID, s := range us {
+				r.Posts += s.Posts
+				r.Reactions += s.GivenReactions
+				r.Replies += s.ReceivedReplies
+				if s.Posts > 0 {
+					r.Posters[userID] = true
+				}
+			}
+		}
+	}
+
+	result := make([]*CategoryRollup, 0, len(byCategory))
+	for _, r := range byCategory {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Category < result[j].Category })
+	return result
+}
+
+func exportCategoriesCSV(fileName string, rollups []*CategoryRollup) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	err = writer.Write([]string{
+		"channel_category",
+		"channels",
+		"posts",
+		"received_reactions",
+		"received_replies",
+		"posters",
+		"posts_per_channel",
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range rollups {
+		err := writer.Write([]string{
+			r.Category,
+			strconv.Itoa(r.Channels),
+			strconv.Itoa(r.Posts),
+			strconv.Itoa(r.Reactions),
+			strconv.Itoa(r.Replies),
+			strconv.Itoa(len(r.Posters)),
+			formatRate(r.Posts, r.Channels),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
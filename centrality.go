@@ -0,0 +1,84 @@
+package main
+
+import "strconv"
+
+// Centrality is the centrality of a user in the interaction graph.
+type Centrality struct {
+	// Degree is the number of users interacted with, as a share of
+	// the other users of the graph.
+	Degree float64
+	// Betweenness is the share of the shortest paths between other
+	// users going through the user.
+	Betweenness float64
+}
+
+// userCentrality holds the centrality of each user of the interaction
+// graph with -centrality, or nil.
+var userCentrality map[string]*Centrality
+
+// centralities returns the degree and betweenness centrality of every
+// node of g, by user ID. Edges count once whatever their weight, and
+// betweenness is computed with the algorithm of Brandes.
+func centralities(g *interactionGraph) map[string]*Centrality {
+	n := len(g.Nodes)
+	betweenness := make([]float64, n)
+	for s := range g.Nodes {
+		// Breadth-first search from s, counting the shortest paths
+		var stack []int
+		preds := make([][]int, n)
+		paths := make([]float64, n)
+		dist := make([]int, n)
+		for v := range dist {
+			dist[v] = -1
+		}
+		paths[s], dist[s] = 1, 0
+		queue := []int{s}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+			for w := range g.Edges[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					paths[w] += paths[v]
+					preds[w] = append(preds[w], v)
+				}
+			}
+		}
+
+		// Accumulate the dependencies in order of decreasing distance
+		delta := make([]float64, n)
+		for i := len(stack) - 1; i >= 0; i-- {
+			w := stack[i]
+			for _, v := range preds[w] {
+				delta[v] += paths[v] / paths[w] * (1 + delta[w])
+			}
+			if w != s {
+				betweenness[w] += delta[w]
+			}
+		}
+	}
+
+	result := make(map[string]*Centrality, n)
+	for v, id := range g.Nodes {
+		c := &Centrality{}
+		if n > 1 {
+			c.Degree = float64(len(g.Edges[v])) / float64(n-1)
+		}
+		if n > 2 {
+			// Every path was counted from both ends
+			c.Betweenness = betweenness[v] / float64((n-1)*(n-2))
+		}
+		result[id] = c
+	}
+	return result
+}
+
+// formatCentrality formats a centrality with four decimals, as the
+// betweenness of most users is well below 0.01.
+func formatCentrality(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+)
+
+type Channel struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Created    int64    `json:"created"`
+	IsArchived bool     `json:"is_archived"`
+	Members    []string `json:"members"`
+	Topic      Text     `json:"topic"`
+	Purpose    Text     `json:"purpose"`
+}
+
+type Text struct {
+	Value string `json:"value"`
+}
+
+// loadChannels reads channels.json and, if present, groups.json of
+// each workspace, keyed like the stats by channelKey.
+func loadChannels(workspaces []Workspace) (map[string]*Channel, error) {
+	channels := make(map[string]*Channel)
+	for _, ws := range workspaces {
+		for _, name := range []string{"channels.json", "groups.json"} {
+			path := filepath.Join(ws.Path, name)
+			if !fileExists(path) {
+				continue
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			var list []*Channel
+			err = json.Unmarshal(data, &list)
+			if err != nil {
+				return nil, jsonError(path, data, err)
+			}
+			for _, c := range list {
+				channels[channelKey(ws.Name, c.Name)] = c
+			}
+		}
+	}
+	return channels, nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	chartBlack = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	chartGray  = color.RGBA{0xcc, 0xcc, 0xcc, 0xff}
+
+	// calendarShades color the days of a calendar from no posts to
+	// the most.
+	calendarShades = []color.RGBA{
+		{0xeb, 0xed, 0xf0, 0xff},
+		{0x9b, 0xe9, 0xa8, 0xff},
+		{0x40, 0xc4, 0x63, 0xff},
+		{0x30, 0xa1, 0x4e, 0xff},
+		{0x21, 0x6e, 0x39, 0xff},
+	}
+
+	// chartPalette colors the series of a chart in turn.
+	chartPalette = []color.RGBA{
+		{0x1f, 0x77, 0xb4, 0xff},
+		{0xff, 0x7f, 0x0e, 0xff},
+		{0x2c, 0xa0, 0x2c, 0xff},
+		{0xd6, 0x27, 0x28, 0xff},
+		{0x94, 0x67, 0xbd, 0xff},
+		{0x8c, 0x56, 0x4b, 0xff},
+		{0xe3, 0x77, 0xc2, 0xff},
+		{0x7f, 0x7f, 0x7f, 0xff},
+	}
+)
+
+// runChart implements the chart subcommand, which draws a chart of the
+// daily file of an earlier run as an SVG or PNG image.
+func runChart(args []string) {
+	flags := flag.NewFlagSet("chart", flag.ExitOnError)
+	chartType := flags.String("type", "posts", "chart to draw: posts (posts per channel over time) or reactions-heatmap (received reactions per channel and week)")
+	output := flags.String("o", "", "output file ending in .svg or .png (default NAME_TYPE.svg)")
+	top := flags.Int("top", 8, "number of channels drawn, most posts first")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println(tr("Error: No daily CSV file specified. The correct usage is `go run . chart [FLAGS] NAME.csv`."))
+		return
+	}
+
+	fileName := *output
+	if fileName == "" {
+		fileName = strings.TrimSuffix(flags.Arg(0), ".csv") + "_" + *chartType + ".svg"
+	}
+
+	statsByChannel, _, err := loadDailyCSV(flags.Arg(0))
+	if err != nil {
+		fmt.Println(tr("Error loading %s:", flags.Arg(0)), err)
+		return
+	}
+
+	var draw func(func(int, int) canvas, StatsByChannel, int) canvas
+	switch *chartType {
+	case "posts":
+		draw = drawPostsChart
+	case "reactions-heatmap":
+		draw = drawReactionsHeatmap
+	default:
+		fmt.Println(tr("Error: -type must be posts or reactions-heatmap."))
+		return
+	}
+
+	var newCanvas func(int, int) canvas
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".svg":
+		newCanvas = func(w, h int) canvas { return newSVGCanvas(w, h) }
+	case ".png":
+		newCanvas = func(w, h int) canvas { return newPNGCanvas(w, h) }
+	default:
+		fmt.Println(tr("Error: -o must end in .svg or .png."))
+		return
+	}
+
+	err = writeChart(fileName, draw(newCanvas, statsByChannel, *top))
+	if err != nil {
+		fmt.Println(tr("Error exporting %s:", fileName), err)
+		return
+	}
+	fmt.Println(fileName, tr(" file created successfully."))
+}
+
+func writeChart(fileName string, c canvas) error {
+	if c == nil {
+		return errors.New("no activity to chart")
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return c.encode(file)
+}
+
+// topChannels returns the keys of the top channels by posts.
+func topChannels(statsByChannel StatsByChannel, top int) []string {
+	posts := make(map[string]int)
+	keys := make([]string, 0, len(statsByChannel))
+	for key, ud := range statsByChannel {
+		keys = append(keys, key)
+		for _, total := range dayTotals(ud) {
+			posts[key] += total.Posts
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if posts[keys[i]] != posts[keys[j]] {
+			return posts[keys[i]] > posts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > top {
+		keys = keys[:top]
+	}
+	return keys
+}
+
+// exportDays returns the first and last day of statsByChannel.
+func exportDays(statsByChannel StatsByChannel) (time.Time, time.Time, bool) {
+	all := make(map[string]*Stats)
+	for _, ud := range statsByChannel {
+		for day := range ud {
+			all[day] = nil
+		}
+	}
+	return dayRange(all)
+}
+
+// drawPostsChart draws the daily posts of the top channels as lines.
+func drawPostsChart(newCanvas func(int, int) canvas, statsByChannel StatsByChannel, top int) canvas {
+	first, last, ok := exportDays(statsByChannel)
+	if !ok {
+		return nil
+	}
+	channels := topChannels(statsByChannel, top)
+	days := int(last.Sub(first).Hours()/24+0.5) + 1
+
+	series := make([][]int, len(channels))
+	max := 1
+	for i, key := range channels {
+		series[i] = make([]int, days)
+		for day, total := range dayTotals(statsByChannel[key]) {
+			t, err := time.Parse("2006-01-02", day)
+			if err != nil {
+				continue
+			}
+			series[i][int(t.Sub(first).Hours()/24+0.5)] = total.Posts
+		}
+		for _, v := range series[i] {
+			if v > max {
+				max = v
+			}
+		}
+	}
+
+	const width, height = 900, 480
+	const left, top0, right, bottom = 50, 30, 720, 440
+	c := newCanvas(width, height)
+	c.text(left, 8, "Posts per day", chartBlack)
+	c.line(left, bottom, right, bottom, chartBlack)
+	c.line(left, top0, left, bottom, chartBlack)
+	for _, v := range []int{0, max / 2, max} {
+		y := bottom - (bottom-top0)*v/max
+		c.line(left, y, right, y, chartGray)
+		label := strconv.Itoa(v)
+		c.text(left-6-len(label)*fontWidth, y-fontHeight/2, label, chartBlack)
+	}
+
+	x := func(i int) int {
+		if days == 1 {
+			return left
+		}
+		return left + (right-left)*i/(days-1)
+	}
+	for _, i := range []int{0, days / 2, days - 1} {
+		c.text(x(i)-30, bottom+6, first.AddDate(0, 0, i).Format("2006-01-02"), chartBlack)
+	}
+
+	for i, key := range channels {
+		color := chartPalette[i%len(chartPalette)]
+		for d := 1; d < days; d++ {
+			c.line(x(d-1), bottom-(bottom-top0)*series[i][d-1]/max, x(d), bottom-(bottom-top0)*series[i][d]/max, color)
+		}
+		c.rect(right+20, top0+i*16, 10, 10, color)
+		c.text(right+36, top0+i*16+1, "#"+key, chartBlack)
+	}
+	return c
+}
+
+// drawReactionsHeatmap draws the received reactions of the top
+// channels per week, darker for more reactions.
+func drawReactionsHeatmap(newCanvas func(int, int) canvas, statsByChannel StatsByChannel, top int) canvas {
+	first, last, ok := exportDays(statsByChannel)
+	if !ok {
+		return nil
+	}
+	channels := topChannels(statsByChannel, top)
+	start := weekStart(first)
+	weeks := int(last.Sub(start).Hours()/24+0.5)/7 + 1
+
+	cells := make([][]int, len(channels))
+	max := 1
+	for i, key := range channels {
+		cells[i] = make([]int, weeks)
+		for day, total := range dayTotals(statsByChannel[key]) {
+			t, err := time.Parse("2006-01-02", day)
+			if err != nil {
+				continue
+			}
+			cells[i][int(t.Sub(start).Hours()/24+0.5)/7] += total.GivenReactions
+		}
+		for _, v := range cells[i] {
+			if v > max {
+				max = v
+			}
+		}
+	}
+
+	const left, top0, cellHeight = 160, 40, 20
+	cellWidth := 720 / weeks
+	if cellWidth > 40 {
+		cellWidth = 40
+	}
+	c := newCanvas(left+cellWidth*weeks+20, top0+cellHeight*len(channels)+30)
+	c.text(10, 8, "Received reactions per week", chartBlack)
+	every := 1 + weeks*fontWidth*11/(cellWidth*weeks)
+	for w := 0; w < weeks; w += every {
+		c.text(left+w*cellWidth, top0-14, start.AddDate(0, 0, 7*w).Format("2006-01-02"), chartBlack)
+	}
+	for i, key := range channels {
+		y := top0 + i*cellHeight
+		label := "#" + key
+		if len(label) > 24 {
+			label = label[:24]
+		}
+		c.text(10, y+(cellHeight-fontHeight)/2+1, label, chartBlack)
+		for w, v := range cells[i] {
+			shade := uint8(0xff - 0xe0*v/max)
+			c.rect(left+w*cellWidth, y, cellWidth-1, cellHeight-1, color.RGBA{shade, shade, 0xff, 0xff})
+		}
+	}
+	c.text(left, top0+cellHeight*len(channels)+8, "max "+strconv.Itoa(max)+" reactions per week", chartBlack)
+	return c
+}
+
+// drawCalendar draws the posts of each day from first to last as a
+// calendar with a column per week, darker for more posts, like the
+// contribution calendar of GitHub.
+func drawCalendar(newCanvas func(int, int) canvas, daily map[string]int, first, last time.Time) canvas {
+	if last.Before(first) {
+		return nil
+	}
+	start := weekStart(first)
+	weeks := int(last.Sub(start).Hours()/24+0.5)/7 + 1
+	max := 1
+	for _, v := range daily {
+		if v > max {
+			max = v
+		}
+	}
+
+	const left, top0, cell = 30, 14, 12
+	c := newCanvas(left+cell*weeks+4, top0+cell*7+4)
+	for row, label := range []string{"Mon", "Wed", "Fri"} {
+		c.text(2, top0+row*2*cell+(cell-fontHeight)/2, label, chartBlack)
+	}
+	month := ""
+	for t := first; !t.After(last); t = t.AddDate(0, 0, 1) {
+		d := int(t.Sub(start).Hours()/24 + 0.5)
+		if m := t.Format("Jan"); m != month {
+			month = m
+			c.text(left+d/7*cell, 2, month, chartBlack)
+		}
+		level := 0
+		if v := daily[t.Format("2006-01-02")]; v > 0 {
+			level = 1 + (4*v-1)/max
+		}
+		c.rect(left+d/7*cell, top0+d%7*cell, cell-2, cell-2, calendarShades[level])
+	}
+	return c
+}
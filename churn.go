@@ -0,0 +1,192 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChurnFeatures are per-user inputs for churn models. Halves split
+// the export period in two equal parts.
+type ChurnFeatures struct {
+	UserID     string
+	Posts      int
+	ActiveDays int
+	Channels   int
+	// PostsSlope is the least-squares slope of weekly posts, in posts
+	// per week per week. A trailing partial week is left out.
+	PostsSlope        float64
+	FirstHalf         churnHalf
+	SecondHalf        churnHalf
+	DaysSinceLastPost int
+}
+
+type churnHalf struct {
+	Posts             int
+	ReceivedReactions int
+	Channels          map[string]bool
+}
+
+func (h churnHalf) reactionRatio() float64 {
+	if h.Posts == 0 {
+		return 0
+	}
+	return float64(h.ReceivedReactions) / float64(h.Posts)
+}
+
+func churnFeatures(statsByChannel StatsByChannel) []*ChurnFeatures {
+	type dayStats struct {
+		t       time.Time
+		channel string
+		s       *Stats
+	}
+	byUser := make(map[string][]dayStats)
+	var first, last time.Time
+	for channelName, ud := range statsByChannel {
+		for day, us := range ud {
+			t, err := time.Parse("2006-01-02", day)
+			if err != nil {
+				continue
+			}
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+			if t.After(last) {
+				last = t
+			}
+			for userID, s := range us {
+				byUser[userID] = append(byUser[userID], dayStats{t, channelName, s})
+			}
+		}
+	}
+	if first.IsZero() {
+		return nil
+	}
+
+	days := int(last.Sub(first).Hours()/24) + 1
+	weeks := days / 7
+	middle := first.AddDate(0, 0, days/2)
+
+	var result []*ChurnFeatures
+	for userID, entries := range byUser {
+		f := &ChurnFeatures{
+			UserID:     userID,
+			FirstHalf:  churnHalf{Channels: make(map[string]bool)},
+			SecondHalf: churnHalf{Channels: make(map[string]bool)},
+		}
+		weekly := make([]float64, weeks)
+		activeDays := make(map[time.Time]bool)
+		channels := make(map[string]bool)
+		var lastPost time.Time
+		for _, e := range entries {
+			f.Posts += e.s.Posts
+			if week := int(e.t.Sub(first).Hours()/24) / 7; week < weeks {
+				weekly[week] += float64(e.s.Posts)
+			}
+			activeDays[e.t] = true
+			channels[e.channel] = true
+			if e.s.Posts > 0 && e.t.After(lastPost) {
+				lastPost = e.t
+			}
+
+			half := &f.FirstHalf
+			if !e.t.Before(middle) {
+				half = &f.SecondHalf
+			}
+			half.Posts += e.s.Posts
+			half.ReceivedReactions += e.s.GivenReactions
+			half.Channels[e.channel] = true
+		}
+		f.ActiveDays = len(activeDays)
+		f.Channels = len(channels)
+		f.PostsSlope = slope(weekly)
+		f.DaysSinceLastPost = -1
+		if !lastPost.IsZero() {
+			f.DaysSinceLastPost = int(last.Sub(lastPost).Hours() / 24)
+		}
+		result = append(result, f)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result
+}
+
+// slope returns the least-squares slope of values over their indexes.
+func slope(values []float64) float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	return (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+}
+
+func exportFeaturesCSV(fileName string, features []*ChurnFeatures, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"user_id",
+		"display_name",
+		"name",
+		"posts",
+		"active_days",
+		"channels",
+		"posts_per_week_slope",
+		"reaction_ratio_first_half",
+		"reaction_ratio_second_half",
+		"reaction_ratio_change",
+		"channels_first_half",
+		"channels_second_half",
+		"channels_change",
+		"days_since_last_post",
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range features {
+		var displayName, name string
+		if u := lookupUser(users, f.UserID); u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+		}
+		row := []string{
+			f.UserID,
+			displayName,
+			name,
+			strconv.Itoa(f.Posts),
+			strconv.Itoa(f.ActiveDays),
+			strconv.Itoa(f.Channels),
+			formatFloat(f.PostsSlope),
+			formatFloat(f.FirstHalf.reactionRatio()),
+			formatFloat(f.SecondHalf.reactionRatio()),
+			formatFloat(f.SecondHalf.reactionRatio() - f.FirstHalf.reactionRatio()),
+			strconv.Itoa(len(f.FirstHalf.Channels)),
+			strconv.Itoa(len(f.SecondHalf.Channels)),
+			strconv.Itoa(len(f.SecondHalf.Channels) - len(f.FirstHalf.Channels)),
+			strconv.Itoa(f.DaysSinceLastPost),
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
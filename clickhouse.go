@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clickHouseBlockSize is the number of rows per block sent to
+// ClickHouse.
+const clickHouseBlockSize = 65536
+
+// clickHouseTypes maps Table Schema column types to ClickHouse types.
+var clickHouseTypes = map[string]string{
+	typeString:    "String",
+	typeInteger:   "Int64",
+	typeNumber:    "Float64",
+	typeBoolean:   "Bool",
+	typeDate:      "Date",
+	typeDatetime:  "DateTime('UTC')",
+	typeYearMonth: "String",
+}
+
+// insertClickHouse inserts the table of write into table through the
+// HTTP interface of the ClickHouse server at serverURL, in the Native
+// format of its protocol. The table is created if missing, as a
+// ReplacingMergeTree keyed by channel and ts so that rows loaded again
+// are deduplicated, and columns of new flags are added to it.
+func insertClickHouse(serverURL, table string, write func(io.Writer) error) (int, error) {
+	header, rows, err := renderTable(write)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := make([]string, len(header))
+	var key []string
+	for i, column := range header {
+		columns[i] = avroName(column) + " " + clickHouseTypes[columnType(column)]
+		switch column {
+		case "workspace", "channel_name", "ts":
+			key = append(key, avroName(column))
+		}
+	}
+	partition := ""
+	for _, column := range header {
+		if column == "posted_at" {
+			partition = " PARTITION BY toYYYYMM(posted_at)"
+		}
+	}
+	if key == nil {
+		key = []string{"tuple()"}
+	}
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = ReplacingMergeTree%s ORDER BY (%s)",
+		table, strings.Join(columns, ", "), partition, strings.Join(key, ", "))
+	err = clickHouseQuery(serverURL, create, nil)
+	if err != nil {
+		return 0, err
+	}
+	alter := make([]string, len(columns))
+	for i, column := range columns {
+		alter[i] = "ADD COLUMN IF NOT EXISTS " + column
+	}
+	err = clickHouseQuery(serverURL, "ALTER TABLE "+table+" "+strings.Join(alter, ", "), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var body bytes.Buffer
+	for start := 0; start < len(rows); start += clickHouseBlockSize {
+		end := start + clickHouseBlockSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		err := appendNativeBlock(&body, header, rows[start:end])
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = clickHouseQuery(serverURL, "INSERT INTO "+table+" FORMAT Native", &body)
+	return len(rows), err
+}
+
+// clickHouseQuery runs query, sending data after it if not nil.
+func clickHouseQuery(serverURL, query string, data io.Reader) error {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return err
+	}
+	params := u.Query()
+	params.Set("query", query)
+	u.RawQuery = params.Encode()
+	if data == nil {
+		data = strings.NewReader("")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Minute}
+	resp, err := client.Post(u.String(), "application/octet-stream", data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.New(strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// appendNativeBlock appends rows as a block of the Native format: the
+// numbers of columns and rows, then the name, type and values of each
+// column. Empty values are the defaults of their type, and others not
+// of the type of their column are an error.
+func appendNativeBlock(w *bytes.Buffer, header []string, rows [][]string) error {
+	var buf []byte
+	buf = appendUvarint(buf, uint64(len(header)))
+	buf = appendUvarint(buf, uint64(len(rows)))
+	for i, column := range header {
+		t := columnType(column)
+		buf = appendNativeString(buf, avroName(column))
+		buf = appendNativeString(buf, clickHouseTypes[t])
+		for _, row := range rows {
+			value := row[i]
+			switch t {
+			case typeInteger:
+				var n int64
+				if value != "" {
+					var err error
+					n, err = strconv.ParseInt(value, 10, 64)
+					if err != nil {
+						return fmt.Errorf("%s: %v", column, err)
+					}
+				}
+				buf = appendUint(buf, uint64(n), 8)
+			case typeNumber:
+				var f float64
+				if value != "" {
+					var err error
+					f, err = strconv.ParseFloat(value, 64)
+					if err != nil {
+						return fmt.Errorf("%s: %v", column, err)
+					}
+				}
+				buf = appendUint(buf, math.Float64bits(f), 8)
+			case typeBoolean:
+				var b bool
+				if value != "" {
+					var err error
+					b, err = strconv.ParseBool(value)
+					if err != nil {
+						return fmt.Errorf("%s: %v", column, err)
+					}
+				}
+				if b {
+					buf = append(buf, 1)
+				} else {
+					buf = append(buf, 0)
+				}
+			case typeDate:
+				var days int64
+				if value != "" {
+					d, err := time.Parse("2006-01-02", value)
+					if err != nil {
+						return fmt.Errorf("%s: %v", column, err)
+					}
+					days = d.Unix() / 86400
+				}
+				buf = appendUint(buf, uint64(days), 2)
+			case typeDatetime:
+				var seconds int64
+				if value != "" {
+					d, err := time.Parse(time.RFC3339, value)
+					if err != nil {
+						return fmt.Errorf("%s: %v", column, err)
+					}
+					seconds = d.Unix()
+				}
+				buf = appendUint(buf, uint64(seconds), 4)
+			default:
+				buf = appendNativeString(buf, value)
+			}
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func appendUvarint(buf []byte, u uint64) []byte {
+	for u >= 0x80 {
+		buf = append(buf, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(buf, byte(u))
+}
+
+// appendUint appends the n low bytes of u, little-endian.
+func appendUint(buf []byte, u uint64, n int) []byte {
+	for i := 0; i < n; i++ {
+		buf = append(buf, byte(u>>(8*i)))
+	}
+	return buf
+}
+
+func appendNativeString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
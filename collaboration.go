@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ssossan/slack_analytics/stats"
+)
+
+// Collaboration is the breadth of the interactions of a user during a
+// month: the distinct users they mentioned, replied to and reacted to.
+type Collaboration struct {
+	UserID    string
+	Month     string
+	Mentioned stats.UserSet
+	RepliedTo stats.UserSet
+	ReactedTo stats.UserSet
+	// People are the users of any of the three.
+	People stats.UserSet
+}
+
+// collaborationBreadth returns the collaboration breadth of every user
+// with interactions per month, by month and decreasing breadth.
+func collaborationBreadth(interactions []interaction) []*Collaboration {
+	byUserMonth := make(map[string]*Collaboration)
+	for _, i := range interactions {
+		if i.Kind == interactionThread {
+			continue
+		}
+		month := i.At.Format("2006-01")
+		key := i.From + "/" + month
+		c, ok := byUserMonth[key]
+		if !ok {
+			c = &Collaboration{UserID: i.From, Month: month}
+			byUserMonth[key] = c
+		}
+		to := stats.UserSymbols.Intern(i.To)
+		switch i.Kind {
+		case interactionMention:
+			c.Mentioned.Add(to)
+		case interactionReply:
+			c.RepliedTo.Add(to)
+		case interactionReaction:
+			c.ReactedTo.Add(to)
+		}
+		c.People.Add(to)
+	}
+
+	result := make([]*Collaboration, 0, len(byUserMonth))
+	for _, c := range byUserMonth {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Month != result[j].Month {
+			return result[i].Month < result[j].Month
+		}
+		if result[i].People.Len() != result[j].People.Len() {
+			return result[i].People.Len() > result[j].People.Len()
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result
+}
+
+func exportCollaborationCSV(fileName string, collaborations []*Collaboration, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"month",
+		"display_name",
+		"name",
+		"mentioned_users",
+		"replied_users",
+		"reacted_users",
+		"collaboration_breadth",
+	}
+	if *includeEmail {
+		header = append(header, "email")
+	}
+	if *userAttrsFile != "" {
+		header = append(header, userAttrsHeader...)
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range collaborations {
+		var displayName, name, email string
+		var attrs *UserAttrs
+		if u := lookupUser(users, c.UserID); u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+			email = u.Profile.Email
+			attrs = u.Attrs
+		}
+		row := []string{
+			c.Month,
+			displayName,
+			name,
+			strconv.Itoa(c.Mentioned.Len()),
+			strconv.Itoa(c.RepliedTo.Len()),
+			strconv.Itoa(c.ReactedTo.Len()),
+			strconv.Itoa(c.People.Len()),
+		}
+		if *includeEmail {
+			row = append(row, email)
+		}
+		if *userAttrsFile != "" {
+			row = append(row, attrsColumns(attrs)...)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
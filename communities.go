@@ -0,0 +1,248 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Community is a cluster of users found in the interaction graph.
+type Community struct {
+	// Members are the user IDs, sorted.
+	Members []string
+	// Links holds the weight of the edges to other communities by
+	// their index.
+	Links map[int]float64
+}
+
+// detectCommunities clusters the nodes of g with louvain and returns
+// the communities, largest first.
+func detectCommunities(g *interactionGraph) []*Community {
+	byIndex := make(map[int]*Community)
+	assigned := louvain(g.Edges)
+	for n, c := range assigned {
+		if byIndex[c] == nil {
+			byIndex[c] = &Community{Links: make(map[int]float64)}
+		}
+		byIndex[c].Members = append(byIndex[c].Members, g.Nodes[n])
+	}
+	result := make([]*Community, 0, len(byIndex))
+	for _, c := range byIndex {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].Members) != len(result[j].Members) {
+			return len(result[i].Members) > len(result[j].Members)
+		}
+		return result[i].Members[0] < result[j].Members[0]
+	})
+
+	position := make(map[*Community]int, len(result))
+	for i, c := range result {
+		position[c] = i
+	}
+	for n, neighbors := range g.Edges {
+		from := position[byIndex[assigned[n]]]
+		for m, w := range neighbors {
+			// Each edge is held in both directions
+			if to := position[byIndex[assigned[m]]]; to != from && n < m {
+				result[from].Links[to] += w
+				result[to].Links[from] += w
+			}
+		}
+	}
+	return result
+}
+
+// louvain returns the community of every node of the graph of edges,
+// found with the Louvain method: nodes join the community of a
+// neighbor while that increases the modularity, then the communities
+// become the nodes of a smaller graph and the same is done again,
+// until no node moves.
+func louvain(edges []map[int]float64) []int {
+	community := make([]int, len(edges))
+	for n := range community {
+		community[n] = n
+	}
+	for {
+		moved, level := moveNodes(edges)
+		if !moved {
+			return community
+		}
+
+		renumbered := make(map[int]int)
+		for n, c := range level {
+			if _, ok := renumbered[c]; !ok {
+				renumbered[c] = len(renumbered)
+			}
+			level[n] = renumbered[c]
+		}
+		for n := range community {
+			community[n] = level[community[n]]
+		}
+		aggregated := make([]map[int]float64, len(renumbered))
+		for c := range aggregated {
+			aggregated[c] = make(map[int]float64)
+		}
+		for n, neighbors := range edges {
+			for m, w := range neighbors {
+				aggregated[level[n]][level[m]] += w
+			}
+		}
+		edges = aggregated
+	}
+}
+
+// moveNodes runs the local moving phase of louvain, visiting the nodes
+// in order until none moves, and returns the community of each node
+// and whether any moved. Neighbor communities are tried in order so
+// that ties break the same way on every run.
+func moveNodes(edges []map[int]float64) (bool, []int) {
+	community := make([]int, len(edges))
+	degree := make([]float64, len(edges))
+	total := make([]float64, len(edges))
+	twiceWeight := 0.0
+	for n, neighbors := range edges {
+		community[n] = n
+		for _, w := range neighbors {
+			degree[n] += w
+		}
+		total[n] = degree[n]
+		twiceWeight += degree[n]
+	}
+	if twiceWeight == 0 {
+		return false, community
+	}
+
+	moved := false
+	for improved := true; improved; {
+		improved = false
+		for n, neighbors := range edges {
+			c := community[n]
+			total[c] -= degree[n]
+			links := make(map[int]float64)
+			for m, w := range neighbors {
+				if m != n {
+					links[community[m]] += w
+				}
+			}
+			candidates := make([]int, 0, len(links))
+			for d := range links {
+				candidates = append(candidates, d)
+			}
+			sort.Ints(candidates)
+
+			best, bestGain := c, links[c]-total[c]*degree[n]/twiceWeight
+			for _, d := range candidates {
+				// A small margin keeps rounding from moving nodes back and forth
+				if gain := links[d] - total[d]*degree[n]/twiceWeight; gain > bestGain+1e-9 {
+					best, bestGain = d, gain
+				}
+			}
+			total[best] += degree[n]
+			if best != c {
+				community[n] = best
+				improved = true
+				moved = true
+			}
+		}
+	}
+	return moved, community
+}
+
+func exportCommunitiesCSV(fileName string, communities []*Community, g *interactionGraph, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"community",
+		"community_size",
+		"display_name",
+		"name",
+		"interactions",
+	}
+	if *includeEmail {
+		header = append(header, "email")
+	}
+	if *userAttrsFile != "" {
+		header = append(header, userAttrsHeader...)
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(g.Nodes))
+	for n, id := range g.Nodes {
+		index[id] = n
+	}
+	for i, c := range communities {
+		for _, userID := range c.Members {
+			var displayName, name, email string
+			var attrs *UserAttrs
+			if u := lookupUser(users, userID); u != nil {
+				displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+				name = u.Name
+				email = u.Profile.Email
+				attrs = u.Attrs
+			}
+			row := []string{
+				strconv.Itoa(i + 1),
+				strconv.Itoa(len(c.Members)),
+				displayName,
+				name,
+				strconv.FormatFloat(g.degree(index[userID]), 'f', -1, 64),
+			}
+			if *includeEmail {
+				row = append(row, email)
+			}
+			if *userAttrsFile != "" {
+				row = append(row, attrsColumns(attrs)...)
+			}
+			err := writer.Write(row)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func exportCommunityEdgesCSV(fileName string, communities []*Community) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	err = writer.Write([]string{"community", "other_community", "weight"})
+	if err != nil {
+		return err
+	}
+	for i, c := range communities {
+		others := make([]int, 0, len(c.Links))
+		for j := range c.Links {
+			if j > i {
+				others = append(others, j)
+			}
+		}
+		sort.Ints(others)
+		for _, j := range others {
+			err := writer.Write([]string{strconv.Itoa(i + 1), strconv.Itoa(j + 1), strconv.FormatFloat(c.Links[j], 'f', -1, 64)})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,110 +1,1367 @@
 package main
 
 import (
-	"encoding/csv"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"ssossan/slack_analytics/metric"
+	"ssossan/slack_analytics/stats"
+)
+
+type Message struct {
+	User            string       `json:"user"`
+	Text            string       `json:"text"`
+	GivenReactions  []Reaction   `json:"reactions,omitempty"`
+	Timestamp       string       `json:"ts"`
+	ThreadTimestamp string       `json:"thread_ts,omitempty"`
+	ParentUserID    string       `json:"parent_user_id,omitempty"`
+	Subtype         string       `json:"subtype,omitempty"`
+	BotID           string       `json:"bot_id,omitempty"`
+	ReplyCount      int          `json:"reply_count,omitempty"`
+	ReplyUsers      []string     `json:"reply_users,omitempty"`
+	Attachments     []Attachment `json:"attachments,omitempty"`
+}
+
+type Reaction struct {
+	Name  string   `json:"name"`
+	Users []string `json:"users"`
+	Count int      `json:"count"`
+}
+
+type User struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Profile      Profile
+	IsRestricted bool       `json:"is_restricted"`
+	Deleted      bool       `json:"deleted"`
+	Tz           string     `json:"tz"`
+	Attrs        *UserAttrs `json:"-"`
+}
+
+type Profile struct {
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+	RealName    string `json:"real_name"`
+	Title       string `json:"title"`
+	StatusText  string `json:"status_text"`
+	StatusEmoji string `json:"status_emoji"`
+}
+
+// The Stats types live in package stats, so that other programs can
+// combine the results of runs.
+type (
+	Stats          = stats.Stats
+	StatsByUser    = stats.ByUser
+	StatsByDay     = stats.ByDay
+	StatsByChannel = stats.ByChannel
+)
+
+// Row types classify the Stats of a user.
+const (
+	rowPoster        = "poster"
+	rowReactorOnly   = "reactor_only"
+	rowRecipientOnly = "recipient_only"
 )
 
-type Message struct {
-	User           string     `json:"user"`
-	Text           string     `json:"text"`
-	GivenReactions []Reaction `json:"reactions,omitempty"`
-	Timestamp      string     `json:"ts"`
-}
+// rowType tells posters from users who only reacted and users who
+// only received replies or reactions.
+func rowType(s *Stats) string {
+	switch {
+	case s.Posts > 0:
+		return rowPoster
+	case s.ReceivedReactions > 0:
+		return rowReactorOnly
+	default:
+		return rowRecipientOnly
+	}
+}
+
+// rowTypes holds the row types selected by -row-types, or nil for all.
+var rowTypes map[string]bool
+
+// lowActivity holds the users below -min-posts or -min-activity.
+var lowActivity map[string]bool
+
+func includeRow(s *Stats) bool {
+	return (rowTypes == nil || rowTypes[rowType(s)]) && !lowActivity[s.UserID] &&
+		(splitMembers == nil || splitMembers[s.UserID])
+}
+
+// findLowActivity returns the users who posted fewer than minPosts
+// messages, or whose posts and given reactions total less than
+// minActivity, over all channels and days.
+func findLowActivity(statsByChannel StatsByChannel, minPosts, minActivity int) map[string]bool {
+	low := make(map[string]bool)
+	for userID, t := range userTotals(statsByChannel) {
+		if t.Posts < minPosts || t.Posts+t.GivenReactions < minActivity {
+			low[userID] = true
+		}
+	}
+	return low
+}
+
+var (
+	rolling       = flag.Bool("rolling", false, "add 7-day and 28-day rolling averages to the daily output")
+	anomalies     = flag.Bool("anomalies", false, "write a report of days with unusual channel activity")
+	anomalySigma  = flag.Float64("anomaly-sigma", 3, "standard deviations from the trailing mean that flag an anomaly")
+	anomalyWindow = flag.Int("anomaly-window", 28, "number of trailing days used for anomaly detection")
+	holidaysFile  = flag.String("holidays", "", "holiday calendar (.ics or one YYYY-MM-DD per line)")
+	sessions      = flag.Bool("sessions", false, "write a report of conversation sessions per channel")
+	sessionGap    = flag.Duration("session-gap", 5*time.Minute, "longest pause between messages of one session")
+	handoffs      = flag.Bool("handoffs", false, "write a report of owner handoffs in long threads per channel")
+	handoffMin    = flag.Int("handoff-min-replies", 10, "fewest replies of a thread checked for handoffs")
+	wordCounts    = flag.Bool("words", false, "add the words posted to the daily and summary files")
+	approxDist    = flag.Bool("approx-distinct", false, "estimate distinct users with HyperLogLog sketches once their sets grow, bounding memory on large workspaces")
+	hllPrecision  = flag.Int("hll-precision", 14, "precision P of the sketches of -approx-distinct, 4 to 16: 2^P bytes each, with a standard error of 1.04/sqrt(2^P)")
+	monthly       = flag.Bool("monthly", false, "write the totals of every user per channel and month, with distinct reacting users over the month")
+	quarterly     = flag.Bool("quarterly", false, "write the totals of every user per channel and fiscal quarter, with distinct reacting users over the quarter")
+	fiscalStart   = flag.String("fiscal-year-start", "January", "month fiscal years start in, such as April, for the quarters of -quarterly")
+	periodsFile   = flag.String("periods", "", "CSV file of named periods with name, start and end dates whose totals of every user per channel are written")
+	shares        = flag.Bool("shares", false, "add the shared and quoted messages of others posted, and the shares of one's messages received, to the daily and summary files")
+	zScores       = flag.Bool("zscores", false, "add z-scores of posts and reactions among the users of each channel to the summary")
+	channelShare  = flag.Bool("channel-share", false, "add each user's percentage of the posts and reactions of the channel to the daily and summary files")
+	crossposts    = flag.Bool("crossposts", false, "write a report of messages cross-posted to several channels")
+	crosspostGap  = flag.Duration("crosspost-window", time.Hour, "longest delay between copies of a cross-posted message")
+	crosspostSim  = flag.Float64("crosspost-similarity", 0.9, "word overlap (0-1) above which two messages are considered copies")
+	threadsTop    = flag.Int("top-threads", 0, "write the N threads with the most replies of each channel, with a preview of their root message")
+	previewLen    = flag.Int("preview-length", 80, "characters of message text quoted by reports, 0 to leave it out")
+	redactExpr    = flag.String("redact", "", "regular expression of text replaced by [redacted] in quoted messages, besides email addresses and long numbers")
+	emojiBoard    = flag.Bool("emoji-leaderboard", false, "write the top reaction emoji per channel and month")
+	emojiTop      = flag.Int("emoji-top", 10, "number of emoji listed per channel and month by -emoji-leaderboard")
+	burnout       = flag.Bool("burnout", false, "write burnout risk signals per team (department with -user-attrs)")
+	burnoutExpr   = flag.String("burnout-expr", "after_hours + weekend + quick_replies + trend", "burnout score over after_hours, weekend, quick_replies, reply_minutes, trend and posts")
+	burnoutUsers  = flag.Bool("burnout-per-user", false, "write the burnout signals of every user instead of teams")
+	burnoutMin    = flag.Int("burnout-min-team", 5, "fewest users of a team listed by -burnout")
+	workHours     = flag.String("work-hours", "9-18", "work hours in the time zone of each user, as START-END")
+	workWeekDays  = flag.String("work-week", "mon-fri", "working days of -burnout, as FIRST-LAST")
+	locWorkWeeks  = flag.String("location-work-weeks", "", "comma-separated location=FIRST-LAST working days of users by their location of -user-attrs, such as Dubai=sun-thu")
+	policyFile    = flag.String("policy", "", "YAML privacy policy restricting the outputs and columns written")
+	splitBy       = flag.String("split-by", "", "also write the daily and summary files of each department, location or manager of -user-attrs")
+	emailTo       = flag.String("email-to", "", "comma-separated addresses to mail the report to")
+	emailFrom     = flag.String("email-from", "", "sender of the mail (default -smtp-user)")
+	emailSubject  = flag.String("email-subject", "", "subject of the mail (default the report title)")
+	emailAttach   = flag.String("email-attach", "summary,report", "comma-separated outputs attached to the mail, such as daily, summary or report")
+	smtpAddr      = flag.String("smtp-addr", os.Getenv("SMTP_ADDR"), "SMTP server as HOST:PORT (default $SMTP_ADDR)")
+	smtpUser      = flag.String("smtp-user", os.Getenv("SMTP_USER"), "SMTP user, with the password in $SMTP_PASSWORD (default $SMTP_USER)")
+	kafkaURL      = flag.String("kafka-rest-url", "", "Kafka REST Proxy publishing the records to -kafka-topic")
+	kafkaTopic    = flag.String("kafka-topic", "", "Kafka topic of the records")
+	kafkaRecords  = flag.String("kafka-records", "daily", "comma-separated records published to Kafka: messages, daily or summary")
+	kafkaFormat   = flag.String("kafka-format", "json", "serialization of the Kafka records: json or avro")
+	bqDataset     = flag.String("bq-dataset", "", "BigQuery dataset, as DATASET or PROJECT.DATASET, the daily rows are loaded into")
+	bqTable       = flag.String("bq-table", "", "BigQuery table of -bq-dataset, created partitioned by day and clustered by channel")
+	sfTable       = flag.String("snowflake-table", "", "Snowflake table, as [DATABASE.][SCHEMA.]TABLE, the daily rows are loaded into")
+	sfConnection  = flag.String("snowflake-connection", "", "connection of the Snowflake client configuration used by -snowflake-table")
+	sfCommand     = flag.String("snowflake-cmd", "snowsql -o exit_on_error=true", "Snowflake client running the statements of -snowflake-table, given -f FILE")
+	chURL         = flag.String("clickhouse-url", "", "HTTP interface of the ClickHouse server the messages are inserted into")
+	chTable       = flag.String("clickhouse-table", "", "ClickHouse table of -clickhouse-url, as [DATABASE.]TABLE")
+	iCal          = flag.Bool("ical", false, "write a calendar (.ics) per user marking their high activity days")
+	iCalFactor    = flag.Float64("ical-factor", 2, "posts of a high activity day, as a multiple of the user's average posts per active day")
+	language      = flag.String("lang", "", "language of messages and the Markdown report: en or ja (default from LANG)")
+	successURL    = flag.String("on-success-url", "", "URL receiving a POST with the JSON manifest of the run when it succeeds")
+	failureURL    = flag.String("on-failure-url", "", "URL receiving a POST with the JSON manifest of the run when it fails")
+	minGroupSize  = flag.Int("min-group-size", 0, "fewest users a team or manager rollup row may describe; smaller groups are bucketed or suppressed")
+	ambientPosts  = flag.Bool("emoji-only", false, "write the share of emoji-only and acknowledging posts per channel")
+	recognition   = flag.Bool("recognition", false, "write a monthly report of the most appreciated users and messages")
+	emojiWeights  = flag.String("emoji-weights", "", "comma-separated emoji=weight pairs used by -recognition, e.g. raised_hands=2")
+	scoreExpr     = flag.String("score-expr", "", "formula of a score column, e.g. \"posts + received_reactions*2 + received_replies*3\"")
+	format        = flag.String("format", "csv", "report written besides the CSV files: csv (none), markdown, html, pdf, avro (the daily file and summary as Avro), dbt (the files as seeds of a dbt project) or dot (the interaction graph for Graphviz)")
+	pdfFont       = flag.String("pdf-font", "", "TrueType font embedded in the PDF report for text outside Latin-1, e.g. Japanese names")
+	registryURL   = flag.String("schema-registry", "", "Confluent schema registry the Avro schemas of -format avro are registered in")
+	reportTop     = flag.Int("report-top", 10, "number of channels and users listed in the report")
+	categories    = flag.Bool("channel-categories", false, "classify channels as social, project, support or announcements and add a channel_category column")
+	categoryRules = flag.String("channel-rules", "", "file of channel classification rules applied before the defaults")
+	reach         = flag.Bool("announcement-reach", false, "write reach reports of announcement channels (needs -channel-categories)")
+	coverage      = flag.Bool("coverage", false, "write a report of gaps without day files in each channel")
+	coverageGap   = flag.Int("coverage-gap", 7, "fewest consecutive days without day files reported by -coverage")
+	slackMembers  = flag.String("slack-members", "", "member analytics CSV exported from Slack, compared with the computed activity")
+	slackChannels = flag.String("slack-channels", "", "channel analytics CSV exported from Slack, compared with the computed activity")
+	userAttrsFile = flag.String("user-attrs", "", "CSV of HR attributes per user_id or email joined onto every row")
+	inactive      = flag.Bool("inactive-users", false, "write a report of channel members without activity")
+	inactiveDays  = flag.Int("inactive-window", 90, "days before the end of the export checked for activity by -inactive-users")
+	namingPrefix  = flag.String("naming-prefixes", "", "comma-separated prefixes channel names must start with, such as proj-,team-,tmp-; writes a report of the channels breaking the convention")
+	namingExempt  = flag.String("naming-exempt", "general,random", "comma-separated channel names exempt from -naming-prefixes")
+	archiveReport = flag.Bool("archive-report", false, "write channels ranked by an archive recommendation score of staleness, member count and trend")
+	archiveWindow = flag.Int("archive-window", 90, "days of the periods whose posts -archive-report compares for the trend, also capping the staleness")
+	duplicates    = flag.Bool("duplicate-channels", false, "write pairs of channels with similar topics and members suggested for consolidation")
+	duplicateSim  = flag.Float64("duplicate-similarity", 0.3, "topic similarity (0-1) from which -duplicate-channels reports a pair")
+	duplicateOver = flag.Float64("duplicate-overlap", 0.5, "member overlap (0-1) from which -duplicate-channels reports a pair")
+	collaboration = flag.Bool("collaboration", false, "write the number of distinct users each user mentioned, replied to and reacted to per month")
+	communities   = flag.Bool("communities", false, "write the communities of users found by the Louvain method in the graph of mentions, replies and reactions")
+	centrality    = flag.Bool("centrality", false, "add the degree and betweenness centrality of users in the graph of mentions, replies and reactions to the summary")
+	bridges       = flag.Bool("bridges", false, "write the users whose mentions, replies and reactions cross departments (needs -user-attrs)")
+	graphSlices   = flag.String("graph-snapshots", "", "write the interaction graph of every week, month or number of days as a Graphviz file")
+	graphEdges    = flag.String("graph-edges", "mention=1,reply=1,reaction=1", "comma-separated kind=weight interactions making the edges of the interaction graph, among mention, reply, reaction and thread (posting in the same thread)")
+	coParticip    = flag.Bool("co-participation", false, "write the number of threads each pair of users both posted in")
+	features      = flag.Bool("features", false, "write per-user behavioral features for churn models")
+	dropUnknown   = flag.Bool("drop-unknown-users", false, "skip messages of users missing from users.json instead of counting them as unknown:<ID>")
+	threadAttrib  = flag.String("thread-attribution", "reply-date", "day thread replies are counted on: reply-date or root-date")
+	excludeSelf   = flag.Bool("exclude-self-reactions", false, "leave reactions of users to their own messages out of the reaction counts, counting them in self_reactions")
+	shareCredit   = flag.String("share-credit", "author", "user credited with the reactions to a shared message: author (of the original message) or sharer")
+	mergeOutput   = flag.Bool("merge", false, "merge into an existing daily file, replacing its rows of the same channel, day and user")
+	manifest      = flag.Bool("manifest", false, "write the size and SHA-256 of every export file")
+	checkManifest = flag.String("verify-manifest", "", "manifest of an earlier run to check the export files against")
+	parallel      = flag.Int("parallel", 1, "number of goroutines processing channels")
+	verify        = flag.Bool("verify", false, "check that every message of the export is either counted or skipped")
+	dataPackage   = flag.Bool("datapackage", false, "write a Frictionless Data Package describing the column types of the CSV files")
+	schema        = flag.String("schema", schemaV1, "output schema version: v1 or v2")
+	metricList    = flag.String("metrics", "", "comma-separated metric families to compute: posts, reactions, threads, mentions (default all)")
+	rowTypeList   = flag.String("row-types", "", "comma-separated row types to output: poster, reactor_only, recipient_only (default all)")
+	minPosts      = flag.Int("min-posts", 0, "leave out users with fewer posts from the daily, summary and leaderboard outputs")
+	minActivity   = flag.Int("min-activity", 0, "leave out users with fewer posts and given reactions from the daily, summary and leaderboard outputs")
+	logSkipped    = flag.Bool("log-skipped", false, "log every skipped record with its reason to stderr")
+	managerRollup = flag.Bool("manager-rollup", false, "write a report of activity per manager (needs -user-attrs)")
+	includeEmail  = flag.Bool("include-email", false, "add user email addresses to the outputs")
+	withProfile   = flag.Bool("include-profile", false, "add the title and status of user profiles to the summary")
+	recoverName   = flag.Bool("recover-names", false, "add a recovered_name column naming deleted users without a display name")
+	priorExports  = flag.String("prior-exports", "", "comma-separated earlier exports to recover the names of deleted users from (needs -recover-names)")
+	execCommand   = flag.String("exec-per-message", "", "command receiving each message as NDJSON and answering with numeric annotations")
+	showVersion   = flag.Bool("version", false, "print the version and exit")
+	otlpEndpoint  = flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP endpoint receiving traces and metrics of the run")
+	plugins       stringList
+	imports       stringList
+)
+
+// scoreFormula is the parsed -score-expr, or nil.
+var scoreFormula expr
+
+// exitStatus is the exit status of the run, set when it fails after
+// deferred cleanups were registered.
+var exitStatus int
+
+// runError is the error the run failed with, reported to the failure
+// webhook.
+var runError string
+
+// fail prints message and err, if not nil, and records them as the
+// error of the run, which then exits with status 1.
+func fail(message string, err error) {
+	if err != nil {
+		message += " " + err.Error()
+	}
+	fmt.Println(message)
+	exitStatus = 1
+	runError = message
+}
+
+func main() {
+	defer func() {
+		if exitStatus != 0 {
+			os.Exit(exitStatus)
+		}
+	}()
+
+	// Unknown languages of the environment are English
+	setLanguage(envLanguage())
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "chart":
+			runChart(os.Args[2:])
+			return
+		case "report":
+			runReport(os.Args[2:])
+			return
+		case "query":
+			runQuery(os.Args[2:])
+			return
+		case "audit":
+			runAudit(os.Args[2:])
+			return
+		case "search":
+			runSearch(os.Args[2:])
+			return
+		case "listen":
+			runListen(os.Args[2:])
+			return
+		}
+	}
+
+	flag.Var(&plugins, "plugin", "Go plugin (.so) providing an extra metric column; may be repeated")
+	flag.Var(&imports, "import", "export of another platform counted along, as mattermost:FILE.jsonl, discord:PATH or teams:DIR; may be repeated")
+	flag.Parse()
+	if *language != "" {
+		err := setLanguage(strings.ToLower(*language))
+		if err != nil {
+			fail(tr("Error: -lang must be en or ja."), nil)
+			return
+		}
+	}
+	if *showVersion {
+		fmt.Println("slack-analytics " + buildVersion())
+		return
+	}
+
+	completed := false
+	if *successURL != "" || *failureURL != "" {
+		start := time.Now()
+		defer func() {
+			r := recover()
+			notifyRun(start, completed, r)
+			if r != nil {
+				panic(r)
+			}
+		}()
+	}
+	if flag.NArg() == 0 {
+		fail(tr("Error: No directory path specified."), nil)
+		return
+	} else if flag.NArg() > 1 {
+		fail(tr("Error: Too many arguments. The correct usage is `go run . [FLAGS] PATH`."), nil)
+		return
+	}
+
+	if *format != "csv" && *format != "markdown" && *format != "html" && *format != "pdf" && *format != "avro" && *format != "dbt" && *format != "dot" {
+		fail(tr("Error: -format must be csv, markdown, html, pdf, avro, dbt or dot."), nil)
+		return
+	}
+	if *format == "pdf" && messageLanguage != "en" && *pdfFont == "" {
+		fail(tr("Error: the PDF report in this language needs a TrueType font covering it, given with -pdf-font."), nil)
+		return
+	}
+
+	if *registryURL != "" && *format != "avro" {
+		fail(tr("Error: -schema-registry needs -format avro."), nil)
+		return
+	}
+
+	if *schema != schemaV1 && *schema != schemaV2 {
+		fail(tr("Error: -schema must be v1 or v2."), nil)
+		return
+	}
+
+	if *threadAttrib != "reply-date" && *threadAttrib != "root-date" {
+		fail(tr("Error: -thread-attribution must be reply-date or root-date."), nil)
+		return
+	}
+
+	if *redactExpr != "" {
+		err := addRedaction(*redactExpr)
+		if err != nil {
+			fail(tr("Error:"), err)
+			return
+		}
+	}
+
+	if *hllPrecision < 4 || *hllPrecision > 16 {
+		fail(tr("Error: -hll-precision must be between 4 and 16."), nil)
+		return
+	}
+	if *approxDist {
+		stats.SketchPrecision = uint8(*hllPrecision)
+	}
+
+	if *shareCredit != "author" && *shareCredit != "sharer" {
+		fail(tr("Error: -share-credit must be author or sharer."), nil)
+		return
+	}
+
+	if *parallel < 1 {
+		fail(tr("Error: -parallel must be at least 1."), nil)
+		return
+	}
+	// A single day has no standard deviation to compare against
+	if *anomalyWindow < 2 {
+		fail(tr("Error: -anomaly-window must be at least 2."), nil)
+		return
+	}
+	if *anomalySigma <= 0 {
+		fail(tr("Error: -anomaly-sigma must be greater than 0."), nil)
+		return
+	}
+	if *archiveWindow < 1 {
+		fail(tr("Error: -archive-window must be at least 1."), nil)
+		return
+	}
+	if *emojiTop < 1 {
+		fail(tr("Error: -emoji-top must be at least 1."), nil)
+		return
+	}
+	if *reportTop < 1 {
+		fail(tr("Error: -report-top must be at least 1."), nil)
+		return
+	}
+	if *parallel > 1 && (*execCommand != "" || len(plugins) > 0) {
+		fail(tr("Error: -parallel cannot be used with -exec-per-message or -plugin."), nil)
+		return
+	}
+
+	if *metricList != "" {
+		enabledFamilies = make(map[string]bool)
+		for _, f := range strings.Split(*metricList, ",") {
+			f = strings.TrimSpace(f)
+			known := false
+			for _, family := range metricFamilies {
+				known = known || f == family
+			}
+			if !known {
+				fail(tr("Error: Unknown metric family %s.", f), nil)
+				return
+			}
+			enabledFamilies[f] = true
+		}
+	}
+
+	if *rowTypeList != "" {
+		rowTypes = make(map[string]bool)
+		for _, t := range strings.Split(*rowTypeList, ",") {
+			t = strings.TrimSpace(t)
+			if t != rowPoster && t != rowReactorOnly && t != rowRecipientOnly {
+				fail(tr("Error: Unknown row type %s.", t), nil)
+				return
+			}
+			rowTypes[t] = true
+		}
+	}
+
+	if *policyFile != "" {
+		var err error
+		policy, err = loadPolicy(*policyFile)
+		if err != nil {
+			fail(tr("Error loading privacy policy:"), err)
+			return
+		}
+	}
+	// The mail body is the report, so a policy denying the report denies
+	// the mail
+	if *emailTo != "" && !policy.allowsOutput("report") {
+		fail(tr("Error: -email-to mails the report, which the privacy policy does not allow."), nil)
+		return
+	}
+
+	var startHour, endHour int
+	var burnoutFormula expr
+	if *burnout {
+		var err error
+		startHour, endHour, err = parseWorkHours(*workHours)
+		if err != nil {
+			fail(tr("Error:"), err)
+			return
+		}
+		defaultWorkWeek, err = parseWorkWeek(*workWeekDays)
+		if err != nil {
+			fail(tr("Error:"), err)
+			return
+		}
+		locationWorkWeeks, err = parseLocationWorkWeeks(*locWorkWeeks)
+		if err != nil {
+			fail(tr("Error:"), err)
+			return
+		}
+		burnoutFormula, err = parseExprOf(*burnoutExpr, burnoutVariables)
+		if err != nil {
+			fail(tr("Error parsing -burnout-expr:"), err)
+			return
+		}
+	}
+
+	if *scoreExpr != "" {
+		formula, err := parseExpr(*scoreExpr)
+		if err != nil {
+			fail(tr("Error parsing -score-expr:"), err)
+			return
+		}
+		scoreFormula = formula
+	}
+
+	weights, err := parseEmojiWeights(*emojiWeights)
+	if err != nil {
+		fail(tr("Error parsing -emoji-weights:"), err)
+		return
+	}
+
+	if (*chURL == "") != (*chTable == "") {
+		fail(tr("Error: -clickhouse-url and -clickhouse-table go together."), nil)
+		return
+	}
+
+	if (*bqDataset == "") != (*bqTable == "") {
+		fail(tr("Error: -bq-dataset and -bq-table go together."), nil)
+		return
+	}
+
+	var kafka *kafkaSink
+	var kafkaKindList []string
+	if *kafkaURL != "" {
+		if *kafkaTopic == "" {
+			fail(tr("Error: -kafka-rest-url needs -kafka-topic."), nil)
+			return
+		}
+		if *kafkaFormat != "json" && *kafkaFormat != "avro" {
+			fail(tr("Error: -kafka-format must be json or avro."), nil)
+			return
+		}
+		kafkaKindList, err = parseKafkaKinds(*kafkaRecords)
+		if err != nil {
+			fail(tr("Error:"), err)
+			return
+		}
+		kafka = newKafkaSink(*kafkaURL, *kafkaTopic, *kafkaFormat)
+	}
+
+	if *reach && !*categories {
+		fail(tr("Error: -announcement-reach needs -channel-categories."), nil)
+		return
+	}
+
+	if *managerRollup && *userAttrsFile == "" {
+		fail(tr("Error: -manager-rollup needs -user-attrs."), nil)
+		return
+	}
+
+	edgeWeights, err = parseEdgeWeights(*graphEdges)
+	if err != nil {
+		fail(tr("Error parsing -graph-edges:"), err)
+		return
+	}
+	fiscalMonth, err := parseMonth(*fiscalStart)
+	if err != nil {
+		fail(tr("Error parsing -fiscal-year-start:"), err)
+		return
+	}
+	var periods []namedPeriod
+	if *periodsFile != "" {
+		periods, err = loadPeriods(*periodsFile)
+		if err != nil {
+			fail(tr("Error loading periods:"), err)
+			return
+		}
+	}
+	var snapshots snapshotWindow
+	if *graphSlices != "" {
+		snapshots, err = parseSnapshotWindow(*graphSlices)
+		if err != nil {
+			fail(tr("Error:"), err)
+			return
+		}
+	}
+
+	if *bridges && *userAttrsFile == "" {
+		fail(tr("Error: -bridges needs -user-attrs."), nil)
+		return
+	}
+
+	var recipients []string
+	if *emailTo != "" {
+		if *smtpAddr == "" {
+			fail(tr("Error: -email-to needs -smtp-addr or SMTP_ADDR."), nil)
+			return
+		}
+		recipients, err = parseRecipients(*emailTo)
+		if err != nil {
+			fail(tr("Error:"), err)
+			return
+		}
+	}
+
+	if *splitBy != "" {
+		if *userAttrsFile == "" {
+			fail(tr("Error: -split-by needs -user-attrs."), nil)
+			return
+		}
+		valid := false
+		for _, name := range splitAttrs {
+			valid = valid || *splitBy == name
+		}
+		if !valid {
+			fail(tr("Error: -split-by must be department, location or manager."), nil)
+			return
+		}
+	}
+
+	if *minGroupSize > 1 && *burnoutUsers {
+		fail(tr("Error: -burnout-per-user cannot be used with -min-group-size."), nil)
+		return
+	}
+
+	if *locWorkWeeks != "" && *userAttrsFile == "" {
+		fail(tr("Error: -location-work-weeks needs -user-attrs."), nil)
+		return
+	}
+
+	if *priorExports != "" && !*recoverName {
+		fail(tr("Error: -prior-exports needs -recover-names."), nil)
+		return
+	}
+
+	basePath := flag.Arg(0)
+	if *verify {
+		verification = &reconciliation{}
+	}
+	statsByChannel := make(StatsByChannel)
+	messagesByChannel := make(map[string][]Message)
+
+	if *otlpEndpoint != "" {
+		telemetry = newTelemetry(*otlpEndpoint)
+		rootSpan = startSpan("convert", nil)
+		rootSpan.setAttribute("path", basePath)
+		defer func() {
+			rootSpan.end(nil)
+			if err := telemetry.flush(); err != nil {
+				fmt.Println(tr("Error exporting telemetry:"), err)
+			}
+		}()
+	}
+
+	var entries []*ManifestEntry
+	if *manifest || *checkManifest != "" {
+		entries, err = buildManifest(longPath(basePath))
+		if err != nil {
+			fail(tr("Error hashing export files:"), err)
+			return
+		}
+	}
+
+	if *checkManifest != "" {
+		recorded, err := loadManifest(*checkManifest)
+		if err != nil {
+			fail(tr("Error loading manifest:"), err)
+			return
+		}
+		changes := compareManifest(recorded, entries)
+		for _, change := range changes {
+			fmt.Println("  " + change)
+		}
+		if len(changes) > 0 {
+			fmt.Printf(tr("Error: %d export files differ from %s.\n"), len(changes), *checkManifest)
+		} else {
+			fmt.Println(tr("All export files match %s.", *checkManifest))
+		}
+	}
+
+	workspaces, err := findWorkspaces(longPath(basePath))
+	if err != nil {
+		fail(tr("Error finding workspaces:"), err)
+		return
+	}
+	if len(imports) > 0 {
+		// Tell the Slack channels from those of the imports
+		multiWorkspace = true
+		for i := range workspaces {
+			if workspaces[i].Name == "" {
+				workspaces[i].Name = "slack"
+			}
+		}
+	}
+
+	// Load names
+	users := make(map[string]*User)
+	loaded := make(map[string]bool)
+	for _, ws := range workspaces {
+		if loaded[ws.UsersFile] {
+			continue
+		}
+		loaded[ws.UsersFile] = true
+
+		span := startSpan("load_users", rootSpan)
+		wsUsers, err := loadUsers(ws.UsersFile)
+		span.end(err)
+		if err != nil {
+			fail(tr("Error loading users:"), err)
+			return
+		}
+		for id, u := range wsUsers {
+			users[id] = u
+		}
+	}
+
+	var imported []*importedExport
+	for _, value := range imports {
+		a, path, err := parseImport(value)
+		if err != nil {
+			fail(tr("Error:"), err)
+			return
+		}
+		export, err := a.load(path)
+		if err != nil {
+			fail(tr("Error importing %s:", path), err)
+			return
+		}
+		for id, u := range export.users {
+			if _, ok := users[id]; !ok {
+				users[id] = u
+			}
+		}
+		imported = append(imported, export)
+	}
+
+	channels, err := loadChannels(workspaces)
+	if err != nil {
+		fail(tr("Error loading channels:"), err)
+		return
+	}
+
+	if *userAttrsFile != "" {
+		byID, byEmail, err := loadUserAttrs(*userAttrsFile)
+		if err != nil {
+			fail(tr("Error loading user attributes:"), err)
+			return
+		}
+		for id, u := range users {
+			u.Attrs = byID[id]
+			if u.Attrs == nil && u.Profile.Email != "" {
+				u.Attrs = byEmail[strings.ToLower(u.Profile.Email)]
+			}
+		}
+	}
+
+	var slackMemberTable, slackChannelTable *slackTable
+	if *slackMembers != "" {
+		slackMemberTable, err = loadSlackCSV(*slackMembers)
+		if err != nil {
+			fail(tr("Error loading Slack member analytics:"), err)
+			return
+		}
+	}
+	if *slackChannels != "" {
+		slackChannelTable, err = loadSlackCSV(*slackChannels)
+		if err != nil {
+			fail(tr("Error loading Slack channel analytics:"), err)
+			return
+		}
+	}
+
+	for _, path := range plugins {
+		m, err := loadMetric(path)
+		if err != nil {
+			fail(tr("Error loading plugin:"), err)
+			return
+		}
+		metrics = append(metrics, m)
+	}
+
+	if *execCommand != "" {
+		hook, err = startHook(*execCommand)
+		if err != nil {
+			fail(tr("Error starting message hook:"), err)
+			return
+		}
+	}
+
+	if *holidaysFile != "" {
+		holidays, err = loadHolidays(*holidaysFile)
+		if err != nil {
+			fail(tr("Error loading holidays:"), err)
+			return
+		}
+	}
+
+	var priorNames map[string]string
+	if *priorExports != "" {
+		priorNames, err = loadPriorNames(strings.Split(*priorExports, ","))
+		if err != nil {
+			fail(tr("Error loading prior exports:"), err)
+			return
+		}
+	}
+
+	parseSpan := startSpan("parse", rootSpan)
+	for _, ws := range workspaces {
+		err = processChannels(ws, parseSpan, users, statsByChannel, messagesByChannel)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		for _, export := range imported {
+			err = processImport(export, users, statsByChannel, messagesByChannel)
+			if err != nil {
+				break
+			}
+		}
+	}
+	parseSpan.end(err)
+
+	if err != nil {
+		fail(tr("Error processing files:"), err)
+		return
+	}
+	printSkipped()
+	verification.print()
+	if *recoverName {
+		recoveredNames = resolveNames(users, priorNames)
+	}
+	if *minPosts > 0 || *minActivity > 0 {
+		lowActivity = findLowActivity(statsByChannel, *minPosts, *minActivity)
+	}
+	if credited := exportThreads.creditMissingReplies(statsByChannel, users); credited > 0 {
+		fmt.Printf(tr("Replies missing from the export, counted from reply_count: %d\n"), credited)
+	}
+	if *centrality {
+		userCentrality = centralities(buildGraph(interactions(messagesByChannel, users)))
+	}
+
+	if *categories {
+		rules := defaultCategoryRules
+		if *categoryRules != "" {
+			fileRules, err := loadCategoryRules(*categoryRules)
+			if err != nil {
+				fail(tr("Error loading channel rules:"), err)
+				return
+			}
+			rules = append(fileRules, rules...)
+		}
+		channelCategories = classifyChannels(statsByChannel, channels, rules)
+	}
+
+	if hook != nil {
+		err = hook.close()
+		if err != nil {
+			fail(tr("Error stopping message hook:"), err)
+			return
+		}
+	}
+
+	outputName := outputFileName(basePath)
+	if *format == "dbt" {
+		outputName = dbtSeedName(basePath)
+		err := os.MkdirAll(dbtSeedDir, 0755)
+		if err != nil {
+			fail(tr("Error creating seed directory:"), err)
+			return
+		}
+	}
+	outputBase = strings.TrimSuffix(outputName, ".csv")
+	if !writeOutput(outputName, func(name string) error {
+		if !*mergeOutput {
+			return exportCSV(name, statsByChannel)
+		}
+		// Write next to the existing file so it is kept if merging fails
+		previous, err := readPreviousCSV(name)
+		if err != nil {
+			return err
+		}
+		err = exportCSV(name+".tmp", statsByChannel)
+		if err == nil {
+			err = mergePreviousCSV(name+".tmp", previous)
+		}
+		if err != nil {
+			os.Remove(name + ".tmp")
+			return err
+		}
+		return os.Rename(name+".tmp", name)
+	}) {
+		return
+	}
+
+	summaryByChannel := summarize(statsByChannel)
+	if *mergeOutput {
+		// The summary cannot be merged, as the daily file lacks the
+		// distinct user sets, so it would only cover the new run
+		fmt.Println(outputBase+"_summary.csv", tr(" not written, as -merge cannot merge the summary."))
+	} else if !writeOutput(outputBase+"_summary.csv", func(name string) error {
+		return exportSummaryCSV(name, summaryByChannel)
+	}) {
+		return
+	}
+
+	if *splitBy != "" {
+		groups := splitGroups(users, *splitBy)
+		for _, group := range sortedGroups(groups) {
+			splitMembers = groups[group]
+			groupBase := outputBase + "_" + *splitBy + "_" + group
+			if !writeOutput(groupBase+".csv", func(name string) error {
+				return exportCSV(name, statsByChannel)
+			}) || !writeOutput(groupBase+"_summary.csv", func(name string) error {
+				return exportSummaryCSV(name, summaryByChannel)
+			}) {
+				return
+			}
+		}
+		splitMembers = nil
+	}
+
+	if *monthly && !writeOutput(outputBase+"_monthly.csv", func(name string) error {
+		return exportPeriodCSV(name, "month", monthlyStats(statsByChannel), nil)
+	}) {
+		return
+	}
+
+	if *quarterly && !writeOutput(outputBase+"_quarterly.csv", func(name string) error {
+		return exportPeriodCSV(name, "quarter", periodStats(statsByChannel, func(day string) string {
+			return fiscalQuarter(day, fiscalMonth)
+		}), nil)
+	}) {
+		return
+	}
+
+	if *periodsFile != "" && !writeOutput(outputBase+"_periods.csv", func(name string) error {
+		order := make(map[string]int, len(periods))
+		for i, p := range periods {
+			order[p.Name] = i
+		}
+		return exportPeriodCSV(name, "period", periodStats(statsByChannel, func(day string) string {
+			return periodOf(periods, day)
+		}), func(a, b string) bool { return order[a] < order[b] })
+	}) {
+		return
+	}
+
+	if *categories && !writeOutput(outputBase+"_categories.csv", func(name string) error {
+		return exportCategoriesCSV(name, rollupCategories(statsByChannel))
+	}) {
+		return
+	}
+
+	if *reach {
+		announcements := findAnnouncements(messagesByChannel)
+		if !writeOutput(outputBase+"_announcements.csv", func(name string) error {
+			return exportAnnouncementsCSV(name, announcements, users)
+		}) {
+			return
+		}
+		if !writeOutput(outputBase+"_announcement_reach.csv", func(name string) error {
+			return exportAnnouncementReachCSV(name, announcementReach(announcements, channels))
+		}) {
+			return
+		}
+	}
+
+	if *coverage && !writeOutput(outputBase+"_coverage.csv", func(name string) error {
+		return exportCoverageCSV(name, findCoverageGaps(channels, *coverageGap))
+	}) {
+		return
+	}
+
+	if *manifest && !writeOutput(outputBase+"_manifest.csv", func(name string) error {
+		return exportManifestCSV(name, entries)
+	}) {
+		return
+	}
+
+	if *format == "markdown" && !writeOutput(outputBase+"_report.md", func(name string) error {
+		report := buildReport(tr("Slack activity of %s", filepath.Base(filepath.Clean(basePath))), statsByChannel, *reportTop)
+		if *emojiBoard {
+			report.Emoji = topEmoji(messagesByChannel, *reportTop)
+		}
+		return exportMarkdown(name, report, users)
+	}) {
+		return
+	}
+
+	if *format == "html" && !writeOutput(outputBase+"_report.html", func(name string) error {
+		report := buildReport(tr("Slack activity of %s", filepath.Base(filepath.Clean(basePath))), statsByChannel, *reportTop)
+		if *emojiBoard {
+			report.Emoji = topEmoji(messagesByChannel, *reportTop)
+		}
+		return exportHTML(name, report, users)
+	}) {
+		return
+	}
+
+	if *format == "avro" && !writeOutput(outputBase+".avro", func(name string) error {
+		return exportAvro(name, func(w io.Writer) error { return writeCSV(w, statsByChannel) })
+	}) {
+		return
+	}
+
+	if *format == "avro" && !writeOutput(outputBase+"_summary.avro", func(name string) error {
+		return exportAvro(name, func(w io.Writer) error { return writeSummaryCSV(w, summaryByChannel) })
+	}) {
+		return
+	}
+
+	if *format == "pdf" && !writeOutput(outputBase+"_report.pdf", func(name string) error {
+		return exportPDF(name, buildReport(tr("Slack activity of %s", filepath.Base(filepath.Clean(basePath))), statsByChannel, *reportTop), users)
+	}) {
+		return
+	}
+
+	if *format == "dot" && !writeOutput(outputBase+"_graph.dot", func(name string) error {
+		posts := make(map[string]int)
+		for userID, t := range userTotals(statsByChannel) {
+			posts[userID] = t.Posts
+		}
+		return exportDOT(name, buildGraph(interactions(messagesByChannel, users)), posts, users)
+	}) {
+		return
+	}
+
+	if *graphSlices != "" {
+		for _, snapshot := range graphSnapshots(interactions(messagesByChannel, users), statsByChannel, snapshots) {
+			if !writeOutput(outputBase+"_graph_"+snapshot.Period+".dot", func(name string) error {
+				return exportDOT(name, snapshot.Graph, snapshot.Posts, users)
+			}) {
+				return
+			}
+		}
+	}
+
+	if *anomalies && !writeOutput(outputBase+"_anomalies.csv", func(name string) error {
+		return exportAnomaliesCSV(name, detectAnomalies(statsByChannel, *anomalyWindow, *anomalySigma))
+	}) {
+		return
+	}
+
+	if *sessions && !writeOutput(outputBase+"_sessions.csv", func(name string) error {
+		return exportSessionsCSV(name, detectSessions(messagesByChannel, *sessionGap))
+	}) {
+		return
+	}
+
+	if *handoffs && !writeOutput(outputBase+"_handoffs.csv", func(name string) error {
+		return exportHandoffsCSV(name, detectHandoffs(messagesByChannel, *handoffMin))
+	}) {
+		return
+	}
 
-type Reaction struct {
-	Name  string   `json:"name"`
-	Users []string `json:"users"`
-	Count int      `json:"count"`
-}
+	if *crossposts && !writeOutput(outputBase+"_crossposts.csv", func(name string) error {
+		return exportCrosspostsCSV(name, detectCrossposts(messagesByChannel, *crosspostGap, *crosspostSim), users)
+	}) {
+		return
+	}
 
-type User struct {
-	ID           string `json:"id"`
-	Name         string `json:"name"`
-	Profile      Profile
-	IsRestricted bool `json:"is_restricted"`
-	Deleted      bool `json:"deleted"`
-}
+	if *threadsTop > 0 && !writeOutput(outputBase+"_top_threads.csv", func(name string) error {
+		return exportTopThreadsCSV(name, topThreads(messagesByChannel, *threadsTop), users)
+	}) {
+		return
+	}
 
-type Profile struct {
-	DisplayName string `json:"display_name"`
-}
+	if *emojiBoard && !writeOutput(outputBase+"_emoji.csv", func(name string) error {
+		return exportEmojiCSV(name, emojiLeaderboard(messagesByChannel, *emojiTop))
+	}) {
+		return
+	}
 
-type Stats struct {
-	UserID                string
-	Name                  string
-	DisplayName           string
-	Posts                 int
-	GivenReactions        int
-	GivenReactionUser     map[string]bool
-	ReceivedReactions     int
-	ReceivedReactionUsers map[string]bool
-	IsRestricted          bool
-	Deleted               bool
-}
+	if *burnout && !writeOutput(outputBase+"_burnout.csv", func(name string) error {
+		signals := burnoutSignals(messagesByChannel, users, startHour, endHour, burnoutFormula)
+		if *burnoutUsers {
+			return exportBurnoutCSV(name, burnoutByUser(signals), true, users)
+		}
+		return exportBurnoutCSV(name, burnoutByTeam(signals, users, *burnoutMin, *minGroupSize), false, users)
+	}) {
+		return
+	}
 
-type StatsByUser map[string]*Stats
-type StatsByDay map[string]StatsByUser
-type StatsByChannel map[string]StatsByDay
+	if *ambientPosts && !writeOutput(outputBase+"_emoji_only.csv", func(name string) error {
+		return exportAmbientCSV(name, countAmbientPosts(messagesByChannel))
+	}) {
+		return
+	}
 
-func main() {
-	if len(os.Args) == 1 {
-		fmt.Println("Error: No directory path specified.")
+	if *recognition && !writeOutput(outputBase+"_recognition.csv", func(name string) error {
+		return exportRecognitionCSV(name, recognize(messagesByChannel, weights, users), users)
+	}) {
+		return
+	}
+
+	if *inactive && !writeOutput(outputBase+"_inactive_users.csv", func(name string) error {
+		return exportInactiveCSV(name, findInactiveMembers(statsByChannel, channels, *inactiveDays), users)
+	}) {
 		return
-	} else if len(os.Args) > 2 {
-		fmt.Println("Error: Too many arguments. The correct usage is `go run converter.go PATH`.")
+	}
+
+	if *namingPrefix != "" && !writeOutput(outputBase+"_naming.csv", func(name string) error {
+		usages := channelUsages(statsByChannel, channels, *inactiveDays)
+		return exportNamingCSV(name, namingViolations(usages, parseNamingConvention(*namingPrefix, *namingExempt)))
+	}) {
 		return
 	}
 
-	basePath := os.Args[1]
-	statsByChannel := make(StatsByChannel)
+	if *archiveReport && !writeOutput(outputBase+"_archive.csv", func(name string) error {
+		return exportArchiveCSV(name, archiveCandidates(statsByChannel, channels, *archiveWindow))
+	}) {
+		return
+	}
 
-	// Load names
-	users, err := loadUsers(basePath + "/users.json")
-	if err != nil {
-		fmt.Println("Error loading users:", err)
+	if *duplicates && !writeOutput(outputBase+"_duplicate_channels.csv", func(name string) error {
+		return exportDuplicateChannelsCSV(name, findDuplicateChannels(statsByChannel, channels, messagesByChannel, *duplicateSim, *duplicateOver))
+	}) {
+		return
+	}
+
+	if *collaboration && !writeOutput(outputBase+"_collaboration.csv", func(name string) error {
+		return exportCollaborationCSV(name, collaborationBreadth(interactions(messagesByChannel, users)), users)
+	}) {
+		return
+	}
+
+	if *communities {
+		g := buildGraph(interactions(messagesByChannel, users))
+		found := detectCommunities(g)
+		if !writeOutput(outputBase+"_communities.csv", func(name string) error {
+			return exportCommunitiesCSV(name, found, g, users)
+		}) || !writeOutput(outputBase+"_community_edges.csv", func(name string) error {
+			return exportCommunityEdgesCSV(name, found)
+		}) {
+			return
+		}
+	}
+
+	if *bridges && !writeOutput(outputBase+"_bridges.csv", func(name string) error {
+		return exportBridgesCSV(name, findBridges(interactions(messagesByChannel, users), users), users)
+	}) {
+		return
+	}
+
+	if *coParticip && !writeOutput(outputBase+"_co_participation.csv", func(name string) error {
+		pairs, threads := coParticipation(messagesByChannel, users)
+		return exportCoParticipationCSV(name, pairs, threads, users)
+	}) {
+		return
+	}
+
+	if *features && !writeOutput(outputBase+"_features.csv", func(name string) error {
+		return exportFeaturesCSV(name, churnFeatures(statsByChannel), users)
+	}) {
+		return
+	}
+
+	if *managerRollup && !writeOutput(outputBase+"_managers.csv", func(name string) error {
+		return exportManagersCSV(name, rollupManagers(statsByChannel, users), users)
+	}) {
+		return
+	}
+
+	if slackMemberTable != nil && !writeOutput(outputBase+"_slack_members.csv", func(name string) error {
+		return exportSlackMembersCSV(name, slackMemberTable, userTotals(statsByChannel), users)
+	}) {
+		return
+	}
+
+	if slackChannelTable != nil && !writeOutput(outputBase+"_slack_channels.csv", func(name string) error {
+		return exportSlackChannelsCSV(name, slackChannelTable, channelTotals(statsByChannel))
+	}) {
+		return
+	}
+
+	if *dataPackage && !writeOutput(outputBase+"_datapackage.json", func(name string) error {
+		return exportDataPackage(name, writtenCSVs)
+	}) {
+		return
+	}
+
+	if *iCal && !writeOutput(outputBase+"_ical", func(name string) error {
+		return exportICal(name, highActivityDays(statsByChannel, *iCalFactor), users)
+	}) {
+		return
+	}
+
+	if *format == "dbt" && !writeOutput(outputBase+"_schema.yml", func(name string) error {
+		return exportDbtProperties(name, writtenCSVs)
+	}) {
 		return
 	}
 
-	err = filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+	if kafka != nil && !policy.allowsOutput("kafka") {
+		fmt.Println(tr("Kafka records not published, as the privacy policy does not allow it."))
+	} else if kafka != nil {
+		for _, kind := range kafkaKindList {
+			var write func(io.Writer) error
+			switch kind {
+			case "messages":
+				write = func(w io.Writer) error { return messageRecords(w, messagesByChannel) }
+			case "daily":
+				write = func(w io.Writer) error { return writeCSV(w, statsByChannel) }
+			case "summary":
+				write = func(w io.Writer) error { return writeSummaryCSV(w, summaryByChannel) }
+			}
+			n, err := kafka.publish(kind, write)
+			if err != nil {
+				fail(fmt.Sprintf(tr("Error publishing %s records to Kafka after %d:"), kind, n), err)
+				return
+			}
+			fmt.Printf(tr("%d %s records published to %s.\n"), n, kind, *kafkaTopic)
+		}
+	}
+
+	if *bqDataset != "" && !policy.allowsOutput("bigquery") {
+		fmt.Println(tr("BigQuery rows not loaded, as the privacy policy does not allow it."))
+	} else if *bqDataset != "" {
+		n, err := loadBigQuery(*bqDataset, *bqTable, func(w io.Writer) error { return writeCSV(w, statsByChannel) })
+		if err != nil {
+			fail(tr("Error loading into BigQuery:"), err)
+			return
+		}
+		fmt.Printf(tr("%d rows loaded into %s.%s.\n"), n, *bqDataset, *bqTable)
+	}
+
+	if *sfTable != "" && !policy.allowsOutput("snowflake") {
+		fmt.Println(tr("Snowflake rows not loaded, as the privacy policy does not allow it."))
+	} else if *sfTable != "" {
+		n, err := loadSnowflake(*sfTable, func(w io.Writer) error { return writeCSV(w, statsByChannel) })
+		if err != nil {
+			fail(tr("Error loading into Snowflake:"), err)
+			return
+		}
+		fmt.Printf(tr("%d rows loaded into %s.\n"), n, *sfTable)
+	}
+
+	if *chURL != "" && !policy.allowsOutput("clickhouse") {
+		fmt.Println(tr("ClickHouse rows not inserted, as the privacy policy does not allow it."))
+	} else if *chURL != "" {
+		n, err := insertClickHouse(*chURL, *chTable, func(w io.Writer) error { return messageRecords(w, messagesByChannel) })
+		if err != nil {
+			fail(tr("Error inserting into ClickHouse:"), err)
+			return
+		}
+		fmt.Printf(tr("%d messages inserted into %s.\n"), n, *chTable)
+	}
+
+	if *emailTo != "" {
+		report := buildReport(tr("Slack activity of %s", filepath.Base(filepath.Clean(basePath))), statsByChannel, *reportTop)
+		if *emojiBoard {
+			report.Emoji = topEmoji(messagesByChannel, *reportTop)
+		}
+		var body strings.Builder
+		err := writeMarkdown(&body, report, users)
+		if err != nil {
+			fail(tr("Error writing mail:"), err)
+			return
+		}
+		e := &reportEmail{
+			From:        *emailFrom,
+			To:          recipients,
+			Subject:     *emailSubject,
+			Body:        body.String(),
+			Attachments: attachments(*emailAttach),
+		}
+		if e.From == "" {
+			e.From = *smtpUser
+		}
+		if e.Subject == "" {
+			e.Subject = report.Title
+			if report.From != "" {
+				e.Subject += " (" + tr("%s to %s", report.From, report.To) + ")"
+			}
+		}
+		err = e.send(*smtpAddr, *smtpUser)
+		if err != nil {
+			fail(tr("Error sending mail:"), err)
+			return
+		}
+		fmt.Println(tr("Mail sent to %s", strings.Join(recipients, ", ")))
+	}
+	completed = true
+}
+
+// writeOutput creates fileName with export and reports the outcome.
+func writeOutput(fileName string, export func(string) error) bool {
+	if !policy.allowsOutput(outputKind(fileName)) {
+		fmt.Println(fileName, tr(" not written, as the privacy policy does not allow it."))
+		return true
+	}
+	span := startSpan("export", rootSpan)
+	span.setAttribute("file", fileName)
+	err := export(fileName)
+	span.end(err)
+	if err != nil {
+		fail(tr("Error exporting %s:", fileName), err)
+		return false
+	}
+
+	if filepath.Ext(fileName) == ".csv" {
+		writtenCSVs = append(writtenCSVs, fileName)
+	}
+	writtenFiles = append(writtenFiles, fileName)
+	fmt.Println(fileName, tr(" file created successfully."))
+	return true
+}
+
+// keepMessages reports whether a message-level report needs the
+// messages of each channel after their stats are updated.
+func keepMessages() bool {
+	if *chURL != "" || (*kafkaURL != "" && strings.Contains(*kafkaRecords, "messages")) {
+		return true
+	}
+	return *threadsTop > 0 || *format == "dot" || *graphSlices != "" || *duplicates || *collaboration || *communities || *centrality || *bridges || *coParticip || *sessions || *handoffs || *crossposts || *emojiBoard || *ambientPosts || *burnout || *recognition || *reach
+}
+
+// processChannels updates the stats with the messages of every
+// channel of ws. With -parallel, the channels are shared among workers
+// with their own partial stats, see processParallel.
+func processChannels(ws Workspace, parent *span, users map[string]*User, statsByChannel StatsByChannel, messagesByChannel map[string][]Message) error {
+	files, err := channelFiles(ws)
+	if err != nil {
+		return err
+	}
+	if *parallel > 1 {
+		return processParallel(files, parent, users, statsByChannel, messagesByChannel, *parallel)
+	}
+
+	for _, f := range files {
+		err := processFile(f, parent, users, statsByChannel, messagesByChannel)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// channelFile is a day file of a channel.
+type channelFile struct {
+	channelName string
+	path        string
+}
+
+// channelFiles returns the day files of every channel of ws, ordered
+// by channel and day.
+func channelFiles(ws Workspace) ([]channelFile, error) {
+	var files []channelFile
+	err := filepath.Walk(ws.Path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		if info.IsDir() && path == ws.Skip {
+			return filepath.SkipDir
+		}
+
 		if !info.IsDir() && filepath.Ext(path) == ".json" {
 			dir := filepath.Dir(path)
-			if filepath.Base(dir) == filepath.Base(basePath) {
+			if filepath.Base(dir) == filepath.Base(ws.Path) {
 				// Skip JSON files that are not
 				// in a channel folder
 				return nil
 			}
-
-			messages, err := readMessagesFromJSONFile(path)
-			if err != nil {
-				return err
-			}
-
-			updateStats(statsByChannel, filepath.Base(dir), messages, users)
+			files = append(files, channelFile{channelKey(ws.Name, filepath.Base(dir)), path})
 		}
 
 		return nil
 	})
+	return files, err
+}
 
+// processFile updates the stats with the messages of one day file.
+func processFile(f channelFile, parent *span, users map[string]*User, statsByChannel StatsByChannel, messagesByChannel map[string][]Message) error {
+	span := startSpan("parse_file", parent)
+	span.setAttribute("file", f.path)
+	messages, err := readMessagesFromJSONFile(f.path)
+	span.end(err)
 	if err != nil {
-		fmt.Println("Error processing files:", err)
-		return
+		telemetry.add("slack_analytics.errors", 1)
+		return err
 	}
+	telemetry.add("slack_analytics.files", 1)
+	telemetry.add("slack_analytics.messages", int64(len(messages)))
 
-	outputName := "./"+strings.Replace(strings.Replace(basePath, ".", "", -1), "/", "", -1)+".csv"
-	exportCSV(outputName, statsByChannel)
-	fmt.Println(outputName, " file created successfully.")
+	if *coverage {
+		addDayFile(f.channelName, strings.TrimSuffix(filepath.Base(f.path), ".json"))
+	}
+	attributed, skipped, err := updateStats(statsByChannel, f.channelName, messages, users, exportThreads)
+	if err != nil {
+		return err
+	}
+	if verification != nil {
+		inFile, err := countJSONMessages(f.path)
+		if err != nil {
+			return err
+		}
+		verification.check(f.path, inFile, len(messages), attributed, skipped)
+	}
+	if keepMessages() {
+		messagesByChannel[f.channelName] = append(messagesByChannel[f.channelName], messages...)
+	}
+	return nil
 }
 
 func loadUsers(usersFile string) (map[string]*User, error) {
@@ -116,16 +1373,18 @@ func loadUsers(usersFile string) (map[string]*User, error) {
 	var users []User
 	err = json.Unmarshal(data, &users)
 	if err != nil {
-		return nil, err
+		return nil, jsonError(usersFile, data, err)
 	}
 
 	userMap := make(map[string]*User)
 	for _, user := range users {
 		userMap[user.ID] = &User{
 			ID:           user.ID,
+			Name:         user.Name,
 			Profile:      user.Profile,
 			IsRestricted: user.IsRestricted,
 			Deleted:      user.Deleted,
+			Tz:           user.Tz,
 		}
 	}
 
@@ -133,13 +1392,11 @@ func loadUsers(usersFile string) (map[string]*User, error) {
 }
 
 func readMessagesFromJSONFile(filePath string) ([]Message, error) {
-	data, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		return nil, err
-	}
-
 	var messages []Message
-	err = json.Unmarshal(data, &messages)
+	err := readFile(filePath, func(data []byte) error {
+		messages = make([]Message, 0, messageCountHint(data))
+		return jsonError(filePath, data, json.Unmarshal(data, &messages))
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +1404,11 @@ func readMessagesFromJSONFile(filePath string) ([]Message, error) {
 	return messages, nil
 }
 
-func updateStats(statsByChannel StatsByChannel, channelName string, messages []Message, users map[string]*User) {
+// updateStats adds messages to the stats of channelName. It returns
+// the number of messages attributed to a user and skipped. Threads are
+// tracked in threads unless it is nil. An error of the message hook
+// stops it, leaving the stats partly updated.
+func updateStats(statsByChannel StatsByChannel, channelName string, messages []Message, users map[string]*User, threads *threadTracker) (attributed, skipped int, err error) {
 
 	ud, ok := statsByChannel[channelName]
 	if !ok {
@@ -155,6 +1416,7 @@ func updateStats(statsByChannel StatsByChannel, channelName string, messages []M
 		statsByChannel[channelName] = ud
 	}
 
+	var days dayFormatter
 	for _, message := range messages {
 		// Format time.Time value as "YYYY/MM/DD"
 		if users == nil {
@@ -162,71 +1424,213 @@ func updateStats(statsByChannel StatsByChannel, channelName string, messages []M
 		}
 
 		if len(message.Timestamp) == 0 {
+			skip(skipMissingTimestamp, channelName, message, message.User)
+			skipped++
 			continue
 		}
 
-		floatTs, err := strconv.ParseFloat(message.Timestamp, 64)
+		postedAt, err := parseTimestamp(message.Timestamp)
 		if err != nil {
-			fmt.Println("Error parsing timestamp:", err)
-			return
+			skip(skipInvalidTimestamp, channelName, message, message.User)
+			skipped++
+			continue
 		}
-		formattedTime := time.Unix(int64(floatTs), 0).Format("2006-01-02")
+		formattedTime := days.format(postedAt)
+		if *recoverName {
+			noteMentionNames(message, users)
+		}
+		if *threadAttrib == "root-date" && isReply(message) {
+			// Bucket the reply on the day its thread started
+			if rootAt, err := parseTimestamp(message.ThreadTimestamp); err == nil {
+				formattedTime = days.format(rootAt)
+			}
+		}
+		for _, m := range metrics {
+			m.Observe(metric.Message{
+				Channel:   channelName,
+				Day:       formattedTime,
+				User:      message.User,
+				Text:      message.Text,
+				Timestamp: message.Timestamp,
+			})
+		}
+
 		statsByUser, ok := ud[formattedTime]
 		if !ok {
 			statsByUser = make(StatsByUser)
 			ud[formattedTime] = statsByUser
 		}
 
-		stats, ok := statsByUser[message.User]
-		if !ok {
-			u := users[message.User]
-			if u == nil {
-				continue
-			}
-			stats = &Stats{
-				UserID: u.ID,
-				Name:         u.Name,
-				DisplayName:  strings.ReplaceAll(u.Profile.DisplayName, ",", " "),
-				IsRestricted: u.IsRestricted,
-				Deleted:      u.Deleted,
+		s := statsFor(statsByUser, users, message.User)
+		if s == nil {
+			if message.BotID != "" || message.Subtype == "bot_message" {
+				skip(skipBotMessage, channelName, message, message.User)
+			} else {
+				skip(skipUnknownUser, channelName, message, message.User)
 			}
-			statsByUser[message.User] = stats
-		}
-
-		stats.Posts++
-
-		for _, reaction := range message.GivenReactions {
-			for _, reactingUser := range reaction.Users {
-				reactingStats, ok := statsByUser[reactingUser]
-				if !ok {
-					u := users[reactingUser]
-					if u == nil {
-						continue
-					}
-					reactingStats = &Stats{
-						UserID:       u.ID,
-						Name:         u.Name,
-						DisplayName:  strings.ReplaceAll(u.Profile.DisplayName, ",", " "),
-						IsRestricted: u.IsRestricted,
-						Deleted:      u.Deleted,
-					}
-					statsByUser[reactingUser] = reactingStats
+			skipped++
+			continue
+		}
+
+		s.Posts++
+		s.Seen(postedAt)
+		if *wordCounts {
+			s.Words += countWords(message.Text)
+		}
+		if *shares {
+			if author := quotedAuthor(message); author != "" && author != message.User {
+				s.SharedPosts++
+				if authorStats := statsFor(statsByUser, users, author); authorStats != nil {
+					authorStats.ReceivedShares++
 				}
+			}
+		}
+		attributed++
 
-				reactingStats.ReceivedReactions++
-				if reactingStats.ReceivedReactionUsers == nil {
-					reactingStats.ReceivedReactionUsers = make(map[string]bool)
+		if hook != nil {
+			annotations, err := hook.annotate(channelName, message)
+			if err != nil {
+				return attributed, skipped, fmt.Errorf("%s: message hook: %v", channelName, err)
+			}
+			for key, value := range annotations {
+				if s.Annotations == nil {
+					s.Annotations = make(map[string]float64)
 				}
-				reactingStats.ReceivedReactionUsers[message.User] = true
+				s.Annotations[key] += value
+			}
+		}
 
-				stats.GivenReactions++
-				if stats.GivenReactionUser == nil {
-					stats.GivenReactionUser = make(map[string]bool)
+		if familyEnabled(familyMentions) {
+			for _, mentioned := range mentionedUsers(message.Text) {
+				if mentionedStats := statsFor(statsByUser, users, mentioned); mentionedStats != nil {
+					stats.CountMention(s, mentionedStats)
+				} else {
+					// Unknown users still count as mentions, not as mentioned users
+					s.Mentions++
 				}
-				stats.GivenReactionUser[reactingUser] = true
 			}
 		}
+
+		if threads != nil && familyEnabled(familyThreads) {
+			threads.track(channelName, formattedTime, message)
+		}
+
+		if familyEnabled(familyThreads) && isReply(message) {
+			// Thread replies are credited to the author of the root message
+			if parentStats := statsFor(statsByUser, users, message.ParentUserID); parentStats != nil {
+				parentStats.ReceivedReplies++
+			} else {
+				skip(skipUnknownParent, channelName, message, message.ParentUserID)
+			}
+		}
+
+		if !familyEnabled(familyReactions) {
+			continue
+		}
+
+		author := s
+		if userID := creditedUser(message, users); userID != message.User {
+			// A shared message, whose reactions go to its author
+			author = statsFor(statsByUser, users, userID)
+		}
+		countReactions(statsByUser, users, channelName, message, author)
+	}
+	return attributed, skipped, nil
+}
+
+// countReactions counts the reactions to message in the stats of its
+// day, crediting them to author. Reactions of the author to their own
+// message are counted apart with -exclude-self-reactions.
+func countReactions(statsByUser StatsByUser, users map[string]*User, channelName string, message Message, author *Stats) {
+	for _, reaction := range message.GivenReactions {
+		for _, reactingUser := range reaction.Users {
+			reactingStats := statsFor(statsByUser, users, reactingUser)
+			if reactingStats == nil {
+				skip(skipUnknownReactor, channelName, message, reactingUser)
+				continue
+			}
+			if *excludeSelf && reactingStats == author {
+				author.SelfReactions++
+				continue
+			}
+
+			stats.CountReaction(author, reactingStats)
+		}
+	}
+}
+
+// isReply reports whether message is a reply in a thread rather than
+// a root message.
+func isReply(message Message) bool {
+	return message.ThreadTimestamp != "" && message.ThreadTimestamp != message.Timestamp
+}
+
+// parseTimestamp converts a Slack ts such as "1672650000.000100".
+// The fraction is parsed as an integer so that messages within the
+// same second keep their exact order.
+func parseTimestamp(ts string) (time.Time, error) {
+	secPart, fracPart := ts, ""
+	if i := strings.IndexByte(ts, '.'); i >= 0 {
+		secPart, fracPart = ts[:i], ts[i+1:]
+	}
+	sec, err := strconv.ParseInt(secPart, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var nsec uint64
+	if fracPart != "" {
+		if len(fracPart) > 9 {
+			fracPart = fracPart[:9]
+		}
+		nsec, err = strconv.ParseUint(fracPart+strings.Repeat("0", 9-len(fracPart)), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(sec, int64(nsec)), nil
+}
+
+// lookupUser returns the user with userID. Users missing from
+// users.json get a placeholder named "unknown:<ID>" so that totals
+// reconcile, unless -drop-unknown-users is set.
+func lookupUser(users map[string]*User, userID string) *User {
+	if u := users[userID]; u != nil {
+		return u
+	}
+	if userID == "" || *dropUnknown {
+		return nil
+	}
+	return &User{ID: userID, Name: "unknown:" + userID}
+}
+
+// statsFor returns the Stats of userID in statsByUser, creating it
+// on first use. It returns nil for users that cannot be attributed.
+func statsFor(statsByUser StatsByUser, users map[string]*User, userID string) *Stats {
+	stats, ok := statsByUser[userID]
+	if ok {
+		return stats
+	}
+
+	u := lookupUser(users, userID)
+	if u == nil {
+		return nil
 	}
+	stats = newStats()
+	*stats = Stats{
+		UserID:       u.ID,
+		Name:         u.Name,
+		DisplayName:  strings.ReplaceAll(u.Profile.DisplayName, ",", " "),
+		Email:        u.Profile.Email,
+		Title:        u.Profile.Title,
+		StatusText:   u.Profile.StatusText,
+		StatusEmoji:  u.Profile.StatusEmoji,
+		IsRestricted: u.IsRestricted,
+		Deleted:      u.Deleted,
+		Attrs:        u.Attrs,
+	}
+	statsByUser[userID] = stats
+	return stats
 }
 
 func exportCSV(fileName string, statsByChannel StatsByChannel) error {
@@ -237,8 +1641,13 @@ func exportCSV(fileName string, statsByChannel StatsByChannel) error {
 	}
 	defer file.Close()
 
+	return writeCSV(file, statsByChannel)
+}
+
+// writeCSV writes the daily rows of statsByChannel to w.
+func writeCSV(w io.Writer, statsByChannel StatsByChannel) error {
 	// Create a CSV writer
-	writer := csv.NewWriter(file)
+	writer := newSchemaWriter(w)
 	defer writer.Flush()
 
 	// Write header to CSV
@@ -254,16 +1663,88 @@ func exportCSV(fileName string, statsByChannel StatsByChannel) error {
 		"given_reactions",
 		"given_reation_users",
 		"channel_name",
+		"received_replies",
+		"received_reactions_per_post",
+		"replies_per_post",
+		"distinct_reactors_per_post",
+		"mentions",
+		"received_mentions",
+		"mentioned_users",
+		"received_mention_users",
+		"row_type",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
 	}
-	err = writer.Write(header)
+	if *mergeOutput {
+		// The key of the rows when merging, as names can change
+		header = append(header, "user_id")
+	}
+	if channelCategories != nil {
+		header = append(header, "channel_category")
+	}
+	if *includeEmail {
+		header = append(header, "email")
+	}
+	if *recoverName {
+		header = append(header, "recovered_name")
+	}
+	if *wordCounts {
+		header = append(header, "words", "words_per_post")
+	}
+	if *shares {
+		header = append(header, "shared_posts", "original_posts", "received_shares")
+	}
+	if *excludeSelf {
+		header = append(header, "self_reactions")
+	}
+	if *channelShare {
+		header = append(header, "share_of_channel_posts", "share_of_channel_reactions")
+	}
+	if *userAttrsFile != "" {
+		header = append(header, userAttrsHeader...)
+	}
+	for _, m := range metrics {
+		header = append(header, m.Name())
+	}
+	keys := sortedAnnotationKeys()
+	header = append(header, keys...)
+	if scoreFormula != nil {
+		header = append(header, "score")
+	}
+	if *holidaysFile != "" {
+		header = append(header, "is_holiday")
+	}
+	if *rolling {
+		for _, window := range rollingWindows {
+			w := strconv.Itoa(window)
+			header = append(header,
+				"posts_"+w+"d_avg",
+				"received_reactions_"+w+"d_avg",
+				"channel_posts_"+w+"d_avg",
+				"channel_received_reactions_"+w+"d_avg",
+			)
+		}
+	}
+	keep := enabledColumns(header)
+	err := writer.Write(selectColumns(header, keep))
 	if err != nil {
 		return err
 	}
 
 	// Write data to CSV
-	for channelName, ud := range statsByChannel {
+	for key, ud := range statsByChannel {
+		workspace, channelName := splitChannelKey(key)
+		var totals map[string]*Stats
+		if *rolling || *channelShare {
+			totals = dayTotals(ud)
+		}
+
 		for day, us := range ud {
-			for _, s := range us {
+			for userID, s := range us {
+				if !includeRow(s) {
+					continue
+				}
 				row := []string{
 					s.DisplayName,
 					s.Name,
@@ -272,12 +1753,69 @@ func exportCSV(fileName string, statsByChannel StatsByChannel) error {
 					day,
 					strconv.Itoa(s.Posts),
 					strconv.Itoa(s.GivenReactions),
-					strconv.Itoa(len(s.GivenReactionUser)),
+					strconv.Itoa(s.GivenReactionUser.Len()),
 					strconv.Itoa(s.ReceivedReactions),
-					strconv.Itoa(len(s.ReceivedReactionUsers)),
+					strconv.Itoa(s.ReceivedReactionUsers.Len()),
 					channelName,
+					strconv.Itoa(s.ReceivedReplies),
+					formatRate(s.GivenReactions, s.Posts),
+					formatRate(s.ReceivedReplies, s.Posts),
+					formatRate(s.GivenReactionUser.Len(), s.Posts),
+					strconv.Itoa(s.Mentions),
+					strconv.Itoa(s.ReceivedMentions),
+					strconv.Itoa(s.MentionedUsers.Len()),
+					strconv.Itoa(s.ReceivedMentionUsers.Len()),
+					rowType(s),
+				}
+				if multiWorkspace {
+					row = append(row, workspace)
+				}
+				if *mergeOutput {
+					row = append(row, userID)
+				}
+				if channelCategories != nil {
+					row = append(row, channelCategories[key])
+				}
+				if *includeEmail {
+					row = append(row, s.Email)
+				}
+				if *recoverName {
+					row = append(row, recoveredNames[s.UserID])
+				}
+				if *wordCounts {
+					row = append(row, strconv.Itoa(s.Words), formatRate(s.Words, s.Posts))
+				}
+				if *shares {
+					row = append(row, strconv.Itoa(s.SharedPosts), strconv.Itoa(s.Posts-s.SharedPosts), strconv.Itoa(s.ReceivedShares))
+				}
+				if *excludeSelf {
+					row = append(row, strconv.Itoa(s.SelfReactions))
+				}
+				if *channelShare {
+					row = append(row,
+						formatShare(s.Posts, totals[day].Posts),
+						formatShare(s.GivenReactions, totals[day].GivenReactions),
+					)
+				}
+				if *userAttrsFile != "" {
+					row = append(row, attrsColumns(s.Attrs)...)
+				}
+				for _, m := range metrics {
+					row = append(row, m.Value(key, day, userID))
+				}
+				for _, key := range keys {
+					row = append(row, formatFloat(s.Annotations[key]))
+				}
+				if scoreFormula != nil {
+					row = append(row, formatFloat(scoreFormula.eval(scoreVars(s, 1))))
+				}
+				if *holidaysFile != "" {
+					row = append(row, strconv.FormatBool(isHoliday(day)))
+				}
+				if *rolling {
+					row = append(row, rollingColumns(ud, totals, day, userID)...)
 				}
-				err := writer.Write(row)
+				err := writer.Write(selectColumns(row, keep))
 				if err != nil {
 					return err
 				}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CoParticipation is the number of threads two users both posted in.
+type CoParticipation struct {
+	UserID  string
+	OtherID string
+	Threads int
+}
+
+// coParticipation returns the pairs of users who posted in at least
+// one same thread, ordered by user IDs, with the number of threads each
+// user posted in.
+func coParticipation(messagesByChannel map[string][]Message, users map[string]*User) ([]*CoParticipation, map[string]int) {
+	byPair := make(map[[2]string]*CoParticipation)
+	threads := make(map[string]int)
+	for _, messages := range messagesByChannel {
+		for _, participants := range threadParticipants(messages) {
+			var attributed []string
+			for _, userID := range participants {
+				if lookupUser(users, userID) != nil {
+					attributed = append(attributed, userID)
+					threads[userID]++
+				}
+			}
+			for i, a := range attributed {
+				for _, b := range attributed[i+1:] {
+					p, ok := byPair[[2]string{a, b}]
+					if !ok {
+						p = &CoParticipation{UserID: a, OtherID: b}
+						byPair[[2]string{a, b}] = p
+					}
+					p.Threads++
+				}
+			}
+		}
+	}
+
+	result := make([]*CoParticipation, 0, len(byPair))
+	for _, p := range byPair {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UserID != result[j].UserID {
+			return result[i].UserID < result[j].UserID
+		}
+		return result[i].OtherID < result[j].OtherID
+	})
+	return result, threads
+}
+
+func exportCoParticipationCSV(fileName string, pairs []*CoParticipation, threads map[string]int, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	err = writer.Write([]string{
+		"user_id",
+		"display_name",
+		"other_user_id",
+		"other_display_name",
+		"shared_threads",
+		"threads",
+		"other_threads",
+	})
+	if err != nil {
+		return err
+	}
+
+	name := func(userID string) string {
+		return strings.ReplaceAll(displayName(users, userID), ",", " ")
+	}
+	for _, p := range pairs {
+		err := writer.Write([]string{
+			p.UserID,
+			name(p.UserID),
+			p.OtherID,
+			name(p.OtherID),
+			strconv.Itoa(p.Threads),
+			strconv.Itoa(threads[p.UserID]),
+			strconv.Itoa(threads[p.OtherID]),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
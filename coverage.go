@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Reasons of coverage gaps.
+const (
+	gapBeforeFirstFile = "before_first_file"
+	gapBetweenFiles    = "between_files"
+	gapAfterLastFile   = "after_last_file"
+)
+
+var (
+	// dayFiles holds the days of the day files of each channel,
+	// collected for -coverage.
+	dayFiles = make(map[string][]string)
+	// dayFilesMu guards dayFiles with -parallel.
+	dayFilesMu sync.Mutex
+)
+
+// addDayFile records the day file of day in a channel.
+func addDayFile(channelName, day string) {
+	dayFilesMu.Lock()
+	dayFiles[channelName] = append(dayFiles[channelName], day)
+	dayFilesMu.Unlock()
+}
+
+// CoverageGap is a run of days without day files in a channel.
+type CoverageGap struct {
+	ChannelName string
+	Start       time.Time
+	End         time.Time
+	Reason      string
+}
+
+// findCoverageGaps returns the runs of at least minDays days without
+// day files: between the creation of a channel (or the start of the
+// export) and its first file, between two files, and between its last
+// file and the end of the export unless it is archived.
+func findCoverageGaps(channels map[string]*Channel, minDays int) []*CoverageGap {
+	var exportStart, exportEnd time.Time
+	parsed := make(map[string][]time.Time)
+	for key, days := range dayFiles {
+		for _, day := range days {
+			t, err := time.ParseInLocation("2006-01-02", day, time.Local)
+			if err != nil {
+				continue
+			}
+			parsed[key] = append(parsed[key], t)
+			if exportStart.IsZero() || t.Before(exportStart) {
+				exportStart = t
+			}
+			if t.After(exportEnd) {
+				exportEnd = t
+			}
+		}
+	}
+
+	var gaps []*CoverageGap
+	add := func(key string, from, to time.Time, reason string) {
+		// from and to are the days with files around the gap
+		if int(to.Sub(from).Hours()/24+0.5)-1 >= minDays {
+			gaps = append(gaps, &CoverageGap{key, from.AddDate(0, 0, 1), to.AddDate(0, 0, -1), reason})
+		}
+	}
+	for key, days := range parsed {
+		sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+		start := exportStart
+		channel := channels[key]
+		if channel != nil && channel.Created > 0 {
+			created := time.Unix(channel.Created, 0)
+			created = time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.Local)
+			if created.After(start) {
+				start = created
+			}
+		}
+		add(key, start.AddDate(0, 0, -1), days[0], gapBeforeFirstFile)
+		for i := 1; i < len(days); i++ {
+			add(key, days[i-1], days[i], gapBetweenFiles)
+		}
+		if channel == nil || !channel.IsArchived {
+			add(key, days[len(days)-1], exportEnd.AddDate(0, 0, 1), gapAfterLastFile)
+		}
+	}
+
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].ChannelName != gaps[j].ChannelName {
+			return gaps[i].ChannelName < gaps[j].ChannelName
+		}
+		return gaps[i].Start.Before(gaps[j].Start)
+	})
+	return gaps
+}
+
+func exportCoverageCSV(fileName string, gaps []*CoverageGap) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"gap_start",
+		"gap_end",
+		"days",
+		"reason",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range gaps {
+		workspace, channelName := splitChannelKey(g.ChannelName)
+		row := []string{
+			channelName,
+			g.Start.Format("2006-01-02"),
+			g.End.Format("2006-01-02"),
+			strconv.Itoa(int(g.End.Sub(g.Start).Hours()/24+0.5) + 1),
+			g.Reason,
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Messages shorter than this after normalization, such as "thanks"
+// or ":+1:", are too generic to count as cross-posts.
+const minCrosspostLength = 10
+
+// Crosspost is a message a user posted to several channels.
+type Crosspost struct {
+	UserID       string
+	Start        time.Time
+	ChannelNames []string
+}
+
+func detectCrossposts(messagesByChannel map[string][]Message, window time.Duration, similarity float64) []*Crosspost {
+	type post struct {
+		channelName string
+		t           time.Time
+		words       map[string]bool
+	}
+	postsByUser := make(map[string][]post)
+	for channelName, messages := range messagesByChannel {
+		for _, message := range messages {
+			if message.User == "" {
+				continue
+			}
+			text := normalizeText(message.Text)
+			if utf8.RuneCountInString(text) < minCrosspostLength {
+				continue
+			}
+			t, err := parseTimestamp(message.Timestamp)
+			if err != nil {
+				continue
+			}
+			words := make(map[string]bool)
+			for _, w := range splitWords(text) {
+				words[w] = true
+			}
+			postsByUser[message.User] = append(postsByUser[message.User], post{channelName, t, words})
+		}
+	}
+
+	var result []*Crosspost
+	for userID, posts := range postsByUser {
+		sort.Slice(posts, func(i, j int) bool {
+			if !posts[i].t.Equal(posts[j].t) {
+				return posts[i].t.Before(posts[j].t)
+			}
+			return posts[i].channelName < posts[j].channelName
+		})
+
+		grouped := make([]bool, len(posts))
+		for i, p := range posts {
+			if grouped[i] {
+				continue
+			}
+			channels := map[string]bool{p.channelName: true}
+			c := &Crosspost{UserID: userID, Start: p.t, ChannelNames: []string{p.channelName}}
+			for j := i + 1; j < len(posts) && posts[j].t.Sub(p.t) <= window; j++ {
+				q := posts[j]
+				if grouped[j] || channels[q.channelName] || jaccard(p.words, q.words) < similarity {
+					continue
+				}
+				grouped[j] = true
+				channels[q.channelName] = true
+				c.ChannelNames = append(c.ChannelNames, q.channelName)
+			}
+			if len(c.ChannelNames) > 1 {
+				result = append(result, c)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UserID != result[j].UserID {
+			return result[i].UserID < result[j].UserID
+		}
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
+
+// normalizeText lowercases text and replaces punctuation with spaces
+// so that copies differing only in formatting compare equal.
+func normalizeText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// jaccard returns the size of the intersection of a and b divided by
+// the size of their union.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+func exportCrosspostsCSV(fileName string, crossposts []*Crosspost, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"display_name",
+		"name",
+		"day",
+		"first_posted",
+		"channels",
+		"channel_names",
+	}
+	if *includeEmail {
+		header = append(header, "email")
+	}
+	if *userAttrsFile != "" {
+		header = append(header, userAttrsHeader...)
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range crossposts {
+		var displayName, name, email string
+		var attrs *UserAttrs
+		if u := lookupUser(users, c.UserID); u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+			email = u.Profile.Email
+			attrs = u.Attrs
+		}
+		row := []string{
+			displayName,
+			name,
+			c.Start.Format("2006-01-02"),
+			c.Start.Format(time.RFC3339),
+			strconv.Itoa(len(c.ChannelNames)),
+			strings.Join(c.ChannelNames, ";"),
+		}
+		if *includeEmail {
+			row = append(row, email)
+		}
+		if *userAttrsFile != "" {
+			row = append(row, attrsColumns(attrs)...)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
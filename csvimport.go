@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"ssossan/slack_analytics/stats"
+)
+
+// loadDailyCSV reads a daily file written by an earlier run back into
+// Stats and the users found in it, as stats.ReadDaily does.
+func loadDailyCSV(fileName string) (StatsByChannel, map[string]*User, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	statsByChannel, err := stats.ReadDaily(file)
+	if err != nil {
+		return nil, nil, csvError(fileName, err)
+	}
+
+	users := make(map[string]*User)
+	for key, ud := range statsByChannel {
+		multiWorkspace = multiWorkspace || strings.Contains(key, "/")
+		for _, us := range ud {
+			for userID, s := range us {
+				users[userID] = &User{
+					ID:           userID,
+					Name:         s.Name,
+					Profile:      Profile{DisplayName: s.DisplayName, Email: s.Email},
+					IsRestricted: s.IsRestricted,
+					Deleted:      s.Deleted,
+				}
+			}
+		}
+	}
+	return statsByChannel, users, nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writtenCSVs lists the CSV files written by the run, described by
+// -datapackage.
+var writtenCSVs []string
+
+// Column types of a Frictionless Table Schema.
+const (
+	typeString    = "string"
+	typeInteger   = "integer"
+	typeNumber    = "number"
+	typeBoolean   = "boolean"
+	typeDate      = "date"
+	typeDatetime  = "datetime"
+	typeYearMonth = "yearmonth"
+)
+
+// columnTypes gives the type of the columns that are not numbers.
+// Columns missing from it, including annotations, scores, plugin
+// metrics and rolling averages, are numbers.
+var columnTypes = map[string]string{
+	"display_name":        typeString,
+	"name":                typeString,
+	"user_id":             typeString,
+	"email":               typeString,
+	"workspace":           typeString,
+	"channel_name":        typeString,
+	"channel_names":       typeString,
+	"channel_category":    typeString,
+	"manager":             typeString,
+	"participant_ids":     typeString,
+	"row_type":            typeString,
+	"emoji":               typeString,
+	"metric":              typeString,
+	"direction":           typeString,
+	"reason":              typeString,
+	"text":                typeString,
+	"path":                typeString,
+	"sha256":              typeString,
+	"top_message_channel": typeString,
+	"top_message_text":    typeString,
+	"slack_name":          typeString,
+	"slack_account_type":  typeString,
+	"slack_channel_type":  typeString,
+	"slack_last_active":   typeString,
+	"title":               typeString,
+	"status_text":         typeString,
+	"status_emoji":        typeString,
+	"recovered_name":      typeString,
+	"team":                typeString,
+	// Slack timestamps are IDs; as numbers they would lose digits.
+	"ts":             typeString,
+	"start_ts":       typeString,
+	"end_ts":         typeString,
+	"top_message_ts": typeString,
+	"thread_ts":      typeString,
+
+	"is_restricted": typeBoolean,
+	"deleted":       typeBoolean,
+	"is_holiday":    typeBoolean,
+
+	"day":         typeDate,
+	"gap_start":   typeDate,
+	"gap_end":     typeDate,
+	"last_active": typeDate,
+
+	"start":              typeDatetime,
+	"end":                typeDatetime,
+	"first_seen":         typeDatetime,
+	"last_seen":          typeDatetime,
+	"first_seen_overall": typeDatetime,
+	"last_seen_overall":  typeDatetime,
+	"first_posted":       typeDatetime,
+	"posted_at":          typeDatetime,
+	"last_reply_at":      typeDatetime,
+
+	"month": typeYearMonth,
+
+	"quarter": typeString,
+	"period":  typeString,
+
+	"schema_version":           typeInteger,
+	"posts":                    typeInteger,
+	"given_reactions":          typeInteger,
+	"given_reaction_users":     typeInteger,
+	"given_reation_users":      typeInteger,
+	"received_reactions":       typeInteger,
+	"received_reations":        typeInteger,
+	"received_reaction_users":  typeInteger,
+	"received_replies":         typeInteger,
+	"replies_received":         typeInteger,
+	"mentions":                 typeInteger,
+	"received_mentions":        typeInteger,
+	"received_mention_users":   typeInteger,
+	"shared_posts":             typeInteger,
+	"original_posts":           typeInteger,
+	"received_shares":          typeInteger,
+	"self_reactions":           typeInteger,
+	"days_active":              typeInteger,
+	"active_days":              typeInteger,
+	"holiday_days_active":      typeInteger,
+	"holiday_posts":            typeInteger,
+	"days_since_last_post":     typeInteger,
+	"channels":                 typeInteger,
+	"channels_first_half":      typeInteger,
+	"channels_second_half":     typeInteger,
+	"channels_change":          typeInteger,
+	"rank":                     typeInteger,
+	"reactions":                typeInteger,
+	"replies":                  typeInteger,
+	"messages":                 typeInteger,
+	"participants":             typeInteger,
+	"duration_seconds":         typeInteger,
+	"distinct_reactors":        typeInteger,
+	"distinct_repliers":        typeInteger,
+	"posters":                  typeInteger,
+	"announcements":            typeInteger,
+	"members":                  typeInteger,
+	"reached_members":          typeInteger,
+	"reached_users":            typeInteger,
+	"days":                     typeInteger,
+	"value":                    typeInteger,
+	"bytes":                    typeInteger,
+	"slack_days_active":        typeInteger,
+	"slack_messages_posted":    typeInteger,
+	"slack_reactions_added":    typeInteger,
+	"slack_members_who_posted": typeInteger,
+	"slack_members_who_viewed": typeInteger,
+	"slack_total_membership":   typeInteger,
+	"words":                    typeInteger,
+	"users":                    typeInteger,
+	"interactions":             typeInteger,
+	"long_threads":             typeInteger,
+	"handoffs":                 typeInteger,
+	"threads_handed_off":       typeInteger,
+
+	"received_reaction_users_overall": typeInteger,
+	"given_reaction_users_overall":    typeInteger,
+	"mentioned_users_overall":         typeInteger,
+	"received_mention_users_overall":  typeInteger,
+
+	"activity":     typeString,
+	"recent_posts": typeInteger,
+	"prior_posts":  typeInteger,
+
+	"other_channel_name": typeString,
+	"other_posts":        typeInteger,
+
+	"mentioned_users":       typeInteger,
+	"replied_users":         typeInteger,
+	"reacted_users":         typeInteger,
+	"collaboration_breadth": typeInteger,
+	"community":             typeInteger,
+	"community_size":        typeInteger,
+	"other_community":       typeInteger,
+
+	"cross_department_interactions": typeInteger,
+	"departments_reached":           typeInteger,
+	"bridge":                        typeBoolean,
+
+	"other_user_id":      typeString,
+	"other_display_name": typeString,
+	"shared_threads":     typeInteger,
+	"threads":            typeInteger,
+	"other_threads":      typeInteger,
+}
+
+// outputColumnTypes overrides columnTypes for the columns of an output
+// kind, as named by outputKind, whose values differ from those of
+// columns of the same name elsewhere.
+var outputColumnTypes = map[string]map[string]string{
+	// Posts per day, averaged over the users of a team
+	"burnout": {"posts": typeNumber},
+}
+
+// outputColumnType returns the Table Schema type of a column of the
+// output file fileName.
+func outputColumnType(fileName, name string) string {
+	if t, ok := outputColumnTypes[outputKind(fileName)][name]; ok {
+		return t
+	}
+	return columnType(name)
+}
+
+// columnType returns the Table Schema type of a column.
+func columnType(name string) string {
+	if t, ok := columnTypes[name]; ok {
+		return t
+	}
+	if strings.HasPrefix(name, "direct_") || strings.HasPrefix(name, "org_") {
+		return typeInteger
+	}
+	for _, attr := range userAttrsHeader {
+		if name == attr {
+			return typeString
+		}
+	}
+	return typeNumber
+}
+
+// DataPackage is a Frictionless Data Package descriptor of the CSV
+// files of a run, so that loaders get the column types without
+// inferring them.
+type DataPackage struct {
+	Profile   string             `json:"profile"`
+	Name      string             `json:"name"`
+	Resources []DataPackageTable `json:"resources"`
+}
+
+type DataPackageTable struct {
+	Profile string          `json:"profile"`
+	Name    string          `json:"name"`
+	Path    string          `json:"path"`
+	Format  string          `json:"format"`
+	Schema  DataTableSchema `json:"schema"`
+}
+
+type DataTableSchema struct {
+	Fields []DataTableField `json:"fields"`
+}
+
+type DataTableField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// exportDataPackage writes the descriptor of files to fileName. The
+// columns are read back from the header of each file.
+func exportDataPackage(fileName string, files []string) error {
+	dir := filepath.Dir(fileName)
+	pkg := DataPackage{
+		Profile: "tabular-data-package",
+		Name:    resourceName(strings.TrimSuffix(filepath.Base(fileName), "_datapackage.json")),
+	}
+	for _, path := range files {
+		header, err := readCSVHeader(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		table := DataPackageTable{
+			Profile: "tabular-data-resource",
+			Name:    resourceName(strings.TrimSuffix(filepath.Base(path), ".csv")),
+			Path:    filepath.ToSlash(rel),
+			Format:  "csv",
+		}
+		for _, column := range header {
+			table.Schema.Fields = append(table.Schema.Fields, DataTableField{Name: column, Type: outputColumnType(path, column)})
+		}
+		pkg.Resources = append(pkg.Resources, table)
+	}
+
+	data, err := json.MarshalIndent(pkg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append(data, '\n'), 0644)
+}
+
+// resourceName makes name a valid Data Package name: lowercase
+// letters, digits, "-", "_" and ".".
+func resourceName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '_'
+	}, name)
+}
+
+func readCSVHeader(fileName string) ([]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return csv.NewReader(file).Read()
+}
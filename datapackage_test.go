@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDataPackageTypes runs the converter with flags adding most
+// columns and checks every value of the CSV files against the type the
+// descriptor declares for its column, so that new columns cannot be
+// declared with a wrong type unnoticed. The converter runs in a child
+// process, since its flags and state are global.
+func TestDataPackageTypes(t *testing.T) {
+	if args := os.Getenv("DATAPACKAGE_TEST_ARGS"); args != "" {
+		os.Args = append([]string{os.Args[0]}, strings.Split(args, "\n")...)
+		main()
+		return
+	}
+
+	dir, users := writeTestExport(t, 3, 14, 6)
+	var userList []User
+	var attrs strings.Builder
+	attrs.WriteString("user_id,department,manager\n")
+	for i := 0; i < len(users); i++ {
+		u := *users[fmt.Sprintf("U%d", i)]
+		u.Profile.Title = "Engineer"
+		u.Profile.StatusEmoji = ":palm_tree:"
+		userList = append(userList, u)
+		fmt.Fprintf(&attrs, "U%d,team%d,U%d\n", i, i%2, i/2)
+	}
+	writeJSON(t, filepath.Join(dir, "users.json"), userList)
+	attrsFile := filepath.Join(t.TempDir(), "attrs.csv")
+	holidaysFile := filepath.Join(t.TempDir(), "holidays.txt")
+	for fileName, data := range map[string]string{attrsFile: attrs.String(), holidaysFile: "2023-01-03\n"} {
+		err := ioutil.WriteFile(fileName, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{
+		"-datapackage", "-user-attrs", attrsFile, "-holidays", holidaysFile,
+		"-include-profile", "-include-email", "-recover-names", "-words", "-shares",
+		"-exclude-self-reactions", "-channel-share", "-rolling", "-score-expr", "posts * 2",
+		"-monthly", "-quarterly", "-zscores", "-anomalies", "-sessions",
+		"-handoffs", "-handoff-min-replies", "1", "-top-threads", "3", "-crossposts",
+		"-emoji-leaderboard", "-burnout", "-burnout-min-team", "1", "-recognition",
+		"-channel-categories", "-announcement-reach", "-coverage", "-inactive-users", "-archive-report",
+		"-duplicate-channels", "-collaboration", "-communities", "-centrality", "-bridges",
+		"-co-participation", "-features", "-manager-rollup",
+		dir,
+	}
+	out := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDataPackageTypes$")
+	cmd.Dir = out
+	cmd.Env = append(os.Environ(), "DATAPACKAGE_TEST_ARGS="+strings.Join(args, "\n"))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+
+	descriptors, err := filepath.Glob(filepath.Join(out, "*_datapackage.json"))
+	if err != nil || len(descriptors) != 1 {
+		t.Fatalf("no descriptor written: %v\n%s", err, output)
+	}
+	data, err := ioutil.ReadFile(descriptors[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkg DataPackage
+	err = json.Unmarshal(data, &pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkg.Resources) < 20 {
+		t.Errorf("%d files described, want the outputs of all flags", len(pkg.Resources))
+	}
+
+	for _, table := range pkg.Resources {
+		file, err := os.Open(filepath.Join(out, table.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(file).ReadAll()
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, field := range table.Schema.Fields {
+			for _, record := range records[1:] {
+				if value := record[i]; value != "" && !validValue(field.Type, value) {
+					t.Errorf("%s: %s declared %s, has %q", table.Path, field.Name, field.Type, value)
+					break
+				}
+			}
+		}
+	}
+}
+
+// validValue reports whether value is of the Table Schema type typ.
+func validValue(typ, value string) bool {
+	var err error
+	switch typ {
+	case typeInteger:
+		_, err = strconv.Atoi(value)
+	case typeNumber:
+		_, err = strconv.ParseFloat(value, 64)
+	case typeBoolean:
+		return value == "true" || value == "false"
+	case typeDate:
+		_, err = time.Parse("2006-01-02", value)
+	case typeDatetime:
+		_, err = time.Parse(time.RFC3339, value)
+	case typeYearMonth:
+		_, err = time.Parse("2006-01", value)
+	}
+	return err == nil
+}
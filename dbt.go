@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// dbtSeedDir is the directory of dbt seeds, relative to the root of a
+// dbt project, where -format dbt writes the outputs.
+const dbtSeedDir = "seeds"
+
+// dbtColumnTypes maps Table Schema column types to the types of seed
+// columns, using the cross-database macros of dbt so that the seeds
+// load into any warehouse.
+var dbtColumnTypes = map[string]string{
+	typeString:    "{{ dbt.type_string() }}",
+	typeInteger:   "{{ dbt.type_bigint() }}",
+	typeNumber:    "{{ dbt.type_float() }}",
+	typeBoolean:   "{{ dbt.type_boolean() }}",
+	typeDate:      "date",
+	typeDatetime:  "{{ dbt.type_timestamp() }}",
+	typeYearMonth: "{{ dbt.type_string() }}",
+}
+
+// dbtSeedName returns the name of the daily file of -format dbt in the
+// seed directory: that of outputFileName, lowercased with other
+// characters than letters, digits and "_" replaced by "_", since seed
+// names are table names.
+func dbtSeedName(basePath string) string {
+	name := strings.TrimSuffix(filepath.Base(outputFileName(basePath)), ".csv")
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '_'
+	}, name)
+	return "./" + dbtSeedDir + "/" + name + ".csv"
+}
+
+// exportDbtProperties writes the properties of the seeds files to
+// fileName: their description, and the type of each column so that
+// dbt does not infer them (Slack timestamps would lose digits as
+// numbers).
+func exportDbtProperties(fileName string, files []string) error {
+	var b strings.Builder
+	b.WriteString("version: 2\n\nseeds:\n")
+	for _, path := range files {
+		header, err := readCSVHeader(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "  - name: %s\n", strings.TrimSuffix(filepath.Base(path), ".csv"))
+		fmt.Fprintf(&b, "    description: The %s output of slack-analytics.\n", outputKind(path))
+		b.WriteString("    config:\n      column_types:\n")
+		for _, column := range header {
+			fmt.Fprintf(&b, "        %s: %q\n", column, dbtColumnTypes[columnType(column)])
+		}
+		b.WriteString("    columns:\n")
+		for _, column := range header {
+			fmt.Fprintf(&b, "      - name: %s\n", column)
+		}
+	}
+	return ioutil.WriteFile(fileName, []byte(b.String()), 0644)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// discordAdapter reads Discord channel exports in the JSON format of
+// DiscordChatExporter: one file per channel, or a directory of them.
+// Reactions are only attributed when the export lists their users.
+type discordAdapter struct{}
+
+type discordExport struct {
+	Guild struct {
+		Name string `json:"name"`
+	} `json:"guild"`
+	Channel struct {
+		Name string `json:"name"`
+	} `json:"channel"`
+	Messages []discordMessage `json:"messages"`
+}
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Nickname string `json:"nickname"`
+	IsBot    bool   `json:"isBot"`
+}
+
+type discordMessage struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Content   string      `json:"content"`
+	Author    discordUser `json:"author"`
+	Reactions []struct {
+		Emoji struct {
+			Name string `json:"name"`
+			Code string `json:"code"`
+		} `json:"emoji"`
+		Count int           `json:"count"`
+		Users []discordUser `json:"users"`
+	} `json:"reactions"`
+	Reference *struct {
+		MessageID string `json:"messageId"`
+	} `json:"reference"`
+}
+
+func (discordAdapter) load(path string) (*importedExport, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	export := &importedExport{
+		path:     path,
+		users:    make(map[string]*User),
+		messages: make(map[string][]Message),
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var channel discordExport
+		err = json.Unmarshal(data, &channel)
+		if err != nil {
+			return nil, jsonError(file, data, err)
+		}
+		key := channelKey(channel.Guild.Name, channel.Channel.Name)
+		export.messages[key] = append(export.messages[key], discordMessages(channel.Messages, export.users)...)
+	}
+	return export, nil
+}
+
+// discordMessages converts the messages of a channel and adds their
+// authors and reacting users to users. Replies become thread replies
+// of the thread of the message they answer.
+func discordMessages(messages []discordMessage, users map[string]*User) []Message {
+	addUser := func(u discordUser) {
+		if _, ok := users[u.ID]; !ok && u.ID != "" && !u.IsBot {
+			users[u.ID] = &User{ID: u.ID, Name: u.Name, Profile: Profile{DisplayName: u.Nickname}}
+		}
+	}
+
+	byID := make(map[string]int)
+	byTimestamp := make(map[string]int)
+	result := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		addUser(m.Author)
+		message := Message{
+			User:      m.Author.ID,
+			Text:      m.Content,
+			Timestamp: slackTimestamp(m.Timestamp),
+		}
+		if m.Author.IsBot {
+			// Like Slack bot messages, which have a bot_id but no user
+			message.User, message.BotID = "", m.Author.ID
+		}
+		if m.Type != "" && m.Type != "Default" && m.Type != "Reply" {
+			message.Subtype = strings.ToLower(m.Type)
+		}
+		if m.Reference != nil {
+			if i, ok := byID[m.Reference.MessageID]; ok {
+				root := &result[i]
+				if isReply(*root) {
+					root = &result[byTimestamp[root.ThreadTimestamp]]
+				}
+				root.ThreadTimestamp = root.Timestamp
+				root.ReplyCount++
+				message.ThreadTimestamp = root.Timestamp
+				message.ParentUserID = root.User
+			}
+		}
+		for _, r := range m.Reactions {
+			name := r.Emoji.Code
+			if name == "" {
+				name = r.Emoji.Name
+			}
+			reaction := Reaction{Name: name, Count: r.Count}
+			for _, u := range r.Users {
+				addUser(u)
+				reaction.Users = append(reaction.Users, u.ID)
+			}
+			message.GivenReactions = append(message.GivenReactions, reaction)
+		}
+		byID[m.ID] = len(result)
+		byTimestamp[message.Timestamp] = len(result)
+		result = append(result, message)
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// exportDOT writes the interaction graph as a Graphviz file, see
+// writeDOT.
+func exportDOT(fileName string, g *interactionGraph, posts map[string]int, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return writeDOT(file, g, posts, users)
+}
+
+// writeDOT writes g as an undirected Graphviz graph. Nodes are users
+// labeled by display name and sized by their posts; edges carry their
+// weight, which also sets their width.
+func writeDOT(w io.Writer, g *interactionGraph, posts map[string]int, users map[string]*User) error {
+	maxPosts, maxWeight := 0, 0.0
+	for n, id := range g.Nodes {
+		if posts[id] > maxPosts {
+			maxPosts = posts[id]
+		}
+		for _, weight := range g.Edges[n] {
+			maxWeight = math.Max(maxWeight, weight)
+		}
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "graph interactions {")
+	fmt.Fprintln(out, `  node [shape=circle, style=filled, fillcolor="#9ecae1", fixedsize=true, fontsize=10];`)
+	for _, id := range g.Nodes {
+		// The area grows with the posts
+		width := 0.5
+		if maxPosts > 0 {
+			width += 1.5 * math.Sqrt(float64(posts[id])/float64(maxPosts))
+		}
+		fmt.Fprintf(out, "  %s [label=%s, width=%.2f, posts=%d];\n", dotQuote(id), dotQuote(displayName(users, id)), width, posts[id])
+	}
+	for n, neighbors := range g.Edges {
+		for _, m := range sortedNeighbors(neighbors) {
+			if m <= n {
+				continue
+			}
+			weight := neighbors[m]
+			fmt.Fprintf(out, "  %s -- %s [weight=%s, penwidth=%.2f];\n",
+				dotQuote(g.Nodes[n]), dotQuote(g.Nodes[m]),
+				strconv.FormatFloat(weight, 'f', -1, 64), 1+4*weight/maxWeight)
+		}
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// DuplicateChannels is a pair of channels of a workspace suggested for
+// consolidation by -duplicate-channels.
+type DuplicateChannels struct {
+	ChannelName string
+	OtherName   string
+	// TopicSimilarity is the cosine similarity of the TF-IDF weighted
+	// words of the two channels.
+	TopicSimilarity float64
+	// MemberOverlap is the Jaccard index of the members.
+	MemberOverlap float64
+	Posts         int
+	OtherPosts    int
+}
+
+// ActivitySplit is the posts of the less active channel as a share of
+// those of the more active one, 1 when the activity is split evenly.
+func (d *DuplicateChannels) ActivitySplit() float64 {
+	if d.Posts == 0 || d.OtherPosts == 0 {
+		return 0
+	}
+	return math.Min(float64(d.Posts), float64(d.OtherPosts)) / math.Max(float64(d.Posts), float64(d.OtherPosts))
+}
+
+// channelWords returns the words of the name, topic and purpose of
+// every channel, and of its messages, with their counts by channel key.
+func channelWords(statsByChannel StatsByChannel, channels map[string]*Channel, messagesByChannel map[string][]Message) map[string]map[string]int {
+	words := make(map[string]map[string]int)
+	add := func(key, text string) {
+		counts := words[key]
+		if counts == nil {
+			counts = make(map[string]int)
+			words[key] = counts
+		}
+		for _, w := range splitWords(normalizeText(slackLink.ReplaceAllString(text, "$2"))) {
+			counts[w]++
+		}
+	}
+	for key, c := range channels {
+		_, channelName := splitChannelKey(key)
+		add(key, channelName+" "+c.Topic.Value+" "+c.Purpose.Value)
+	}
+	for key := range statsByChannel {
+		if channels[key] == nil {
+			_, channelName := splitChannelKey(key)
+			add(key, channelName)
+		}
+		for _, message := range messagesByChannel[key] {
+			add(key, message.Text)
+		}
+	}
+	return words
+}
+
+// tfidfVectors weighs the word counts of each channel by the inverse
+// of the number of channels using the word, normalized to unit length.
+func tfidfVectors(words map[string]map[string]int) map[string]map[string]float64 {
+	channelsUsing := make(map[string]int)
+	for _, counts := range words {
+		for w := range counts {
+			channelsUsing[w]++
+		}
+	}
+	vectors := make(map[string]map[string]float64, len(words))
+	for key, counts := range words {
+		v := make(map[string]float64, len(counts))
+		norm := 0.0
+		for w, n := range counts {
+			weight := float64(n) * math.Log(float64(len(words))/float64(channelsUsing[w]))
+			if weight > 0 {
+				v[w] = weight
+				norm += weight * weight
+			}
+		}
+		for w := range v {
+			v[w] /= math.Sqrt(norm)
+		}
+		vectors[key] = v
+	}
+	return vectors
+}
+
+// findDuplicateChannels returns the pairs of channels of the same
+// workspace, both with posts, whose topic similarity and member overlap
+// reach the thresholds, by decreasing product of the two. Members are
+// those of channels.json, or the posters for channels missing from it.
+func findDuplicateChannels(statsByChannel StatsByChannel, channels map[string]*Channel, messagesByChannel map[string][]Message, similarity, overlap float64) []*DuplicateChannels {
+	vectors := tfidfVectors(channelWords(statsByChannel, channels, messagesByChannel))
+	usages := channelUsages(statsByChannel, channels, 1)
+
+	members := make(map[string]map[string]bool)
+	var keys []string
+	for key, u := range usages {
+		if u.Posts == 0 || channels[key] != nil && channels[key].IsArchived {
+			continue
+		}
+		keys = append(keys, key)
+		set := make(map[string]bool)
+		if c := channels[key]; c != nil {
+			for _, id := range c.Members {
+				set[id] = true
+			}
+		} else {
+			for _, us := range statsByChannel[key] {
+				for userID, s := range us {
+					if s.Posts > 0 {
+						set[userID] = true
+					}
+				}
+			}
+		}
+		members[key] = set
+	}
+	sort.Strings(keys)
+
+	var result []*DuplicateChannels
+	for i, a := range keys {
+		workspace, _ := splitChannelKey(a)
+		for _, b := range keys[i+1:] {
+			if other, _ := splitChannelKey(b); other != workspace {
+				continue
+			}
+			d := &DuplicateChannels{
+				ChannelName:   a,
+				OtherName:     b,
+				MemberOverlap: jaccard(members[a], members[b]),
+				Posts:         usages[a].Posts,
+				OtherPosts:    usages[b].Posts,
+			}
+			if d.MemberOverlap < overlap {
+				continue
+			}
+			for w, x := range vectors[a] {
+				d.TopicSimilarity += x * vectors[b][w]
+			}
+			if d.TopicSimilarity >= similarity {
+				result = append(result, d)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		si := result[i].TopicSimilarity * result[i].MemberOverlap
+		sj := result[j].TopicSimilarity * result[j].MemberOverlap
+		if si != sj {
+			return si > sj
+		}
+		if result[i].ChannelName != result[j].ChannelName {
+			return result[i].ChannelName < result[j].ChannelName
+		}
+		return result[i].OtherName < result[j].OtherName
+	})
+	return result
+}
+
+func exportDuplicateChannelsCSV(fileName string, duplicates []*DuplicateChannels) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"other_channel_name",
+		"topic_similarity",
+		"member_overlap",
+		"activity_split",
+		"posts",
+		"other_posts",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range duplicates {
+		workspace, channelName := splitChannelKey(d.ChannelName)
+		_, otherName := splitChannelKey(d.OtherName)
+		row := []string{
+			channelName,
+			otherName,
+			formatFloat(d.TopicSimilarity),
+			formatFloat(d.MemberOverlap),
+			formatFloat(d.ActivitySplit()),
+			strconv.Itoa(d.Posts),
+			strconv.Itoa(d.OtherPosts),
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
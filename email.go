@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// writtenFiles lists every file written by the run, for -email-to.
+var writtenFiles []string
+
+// reportEmail is a mail of the report with outputs attached.
+type reportEmail struct {
+	From        string
+	To          []string
+	Subject     string
+	Body        string
+	Attachments []string
+}
+
+// attachments returns the written files whose output kind is listed
+// in kinds, such as summary or report.
+func attachments(kinds string) []string {
+	wanted := make(map[string]bool)
+	for _, kind := range strings.Split(kinds, ",") {
+		wanted[strings.TrimSpace(kind)] = true
+	}
+	var files []string
+	for _, fileName := range writtenFiles {
+		if wanted[outputKind(fileName)] {
+			files = append(files, fileName)
+		}
+	}
+	return files
+}
+
+// parseRecipients returns the addresses of the comma-separated list of
+// -email-to, which must be plain addresses like user@example.com.
+func parseRecipients(list string) ([]string, error) {
+	var addrs []string
+	for _, addr := range strings.Split(list, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil || parsed.Address != addr {
+			return nil, errors.New("-email-to has an invalid address: " + addr)
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("-email-to has no address")
+	}
+	return addrs, nil
+}
+
+// message returns the mail in MIME: the body as text and every
+// attachment encoded in base64.
+func (e *reportEmail) message() ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", e.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", e.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(part)
+	qp.Write([]byte(e.Body))
+	qp.Close()
+
+	for _, fileName := range e.Attachments {
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(fileName))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(fileName)})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+	err = mw.Close()
+	return buf.Bytes(), err
+}
+
+// send mails e through the SMTP server at addr, with STARTTLS when
+// the server offers it. The password is read from $SMTP_PASSWORD and
+// only used with a user.
+func (e *reportEmail) send(addr, user string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.New("-smtp-addr must be HOST:PORT, got " + addr)
+	}
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	message, err := e.message()
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(addr, auth, e.From, e.To, message)
+}
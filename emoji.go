@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+)
+
+// EmojiUsage is how often one emoji was used as a reaction in a
+// channel during a month.
+type EmojiUsage struct {
+	ChannelName string
+	Month       string
+	Emoji       string
+	Reactions   int
+	Messages    int
+}
+
+// emojiLeaderboard returns the top emoji of each channel and month,
+// most used first, keeping at most top per channel and month.
+func emojiLeaderboard(messagesByChannel map[string][]Message, top int) [][]*EmojiUsage {
+	var result [][]*EmojiUsage
+	for channelName, messages := range messagesByChannel {
+		byMonth := make(map[string]map[string]*EmojiUsage)
+		for _, message := range messages {
+			postedAt, err := parseTimestamp(message.Timestamp)
+			if err != nil {
+				continue
+			}
+			month := postedAt.Format("2006-01")
+			for _, reaction := range message.GivenReactions {
+				if byMonth[month] == nil {
+					byMonth[month] = make(map[string]*EmojiUsage)
+				}
+				usage, ok := byMonth[month][reaction.Name]
+				if !ok {
+					usage = &EmojiUsage{ChannelName: channelName, Month: month, Emoji: reaction.Name}
+					byMonth[month][reaction.Name] = usage
+				}
+				usage.Reactions += reaction.Count
+				usage.Messages++
+			}
+		}
+
+		for _, emoji := range byMonth {
+			board := make([]*EmojiUsage, 0, len(emoji))
+			for _, usage := range emoji {
+				board = append(board, usage)
+			}
+			sort.Slice(board, func(i, j int) bool {
+				if board[i].Reactions != board[j].Reactions {
+					return board[i].Reactions > board[j].Reactions
+				}
+				return board[i].Emoji < board[j].Emoji
+			})
+			if len(board) > top {
+				board = board[:top]
+			}
+			if len(board) == 0 {
+				continue
+			}
+			result = append(result, board)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i][0].ChannelName != result[j][0].ChannelName {
+			return result[i][0].ChannelName < result[j][0].ChannelName
+		}
+		return result[i][0].Month < result[j][0].Month
+	})
+	return result
+}
+
+// topEmoji returns the most used reaction emoji over all channels,
+// keeping at most top.
+func topEmoji(messagesByChannel map[string][]Message, top int) []*ReportEmoji {
+	byEmoji := make(map[string]*ReportEmoji)
+	for _, messages := range messagesByChannel {
+		for _, message := range messages {
+			for _, reaction := range message.GivenReactions {
+				usage, ok := byEmoji[reaction.Name]
+				if !ok {
+					usage = &ReportEmoji{Name: reaction.Name}
+					byEmoji[reaction.Name] = usage
+				}
+				usage.Reactions += reaction.Count
+				usage.Messages++
+			}
+		}
+	}
+
+	board := make([]*ReportEmoji, 0, len(byEmoji))
+	for _, usage := range byEmoji {
+		board = append(board, usage)
+	}
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Reactions != board[j].Reactions {
+			return board[i].Reactions > board[j].Reactions
+		}
+		return board[i].Name < board[j].Name
+	})
+	if len(board) > top {
+		board = board[:top]
+	}
+	return board
+}
+
+func exportEmojiCSV(fileName string, boards [][]*EmojiUsage) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"month",
+		"rank",
+		"emoji",
+		"reactions",
+		"messages",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, board := range boards {
+		for i, usage := range board {
+			workspace, channelName := splitChannelKey(usage.ChannelName)
+			row := []string{
+				channelName,
+				usage.Month,
+				strconv.Itoa(i + 1),
+				usage.Emoji,
+				strconv.Itoa(usage.Reactions),
+				strconv.Itoa(usage.Messages),
+			}
+			if multiWorkspace {
+				row = append(row, workspace)
+			}
+			err := writer.Write(row)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
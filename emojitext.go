@@ -0,0 +1,440 @@
+package main
+
+import "strings"
+
+// emojiCharacters maps the names of standard emoji, as Slack writes
+// them in reactions and statuses, to their characters. Custom emoji
+// and names missing here are written as :name:.
+var emojiCharacters = map[string]string{
+	"+1":                            "👍",
+	"thumbsup":                      "👍",
+	"-1":                            "👎",
+	"thumbsdown":                    "👎",
+	"ok_hand":                       "👌",
+	"clap":                          "👏",
+	"raised_hands":                  "🙌",
+	"pray":                          "🙏",
+	"wave":                          "👋",
+	"muscle":                        "💪",
+	"point_up":                      "☝️",
+	"point_up_2":                    "👆",
+	"point_down":                    "👇",
+	"point_left":                    "👈",
+	"point_right":                   "👉",
+	"raised_hand":                   "✋",
+	"hand":                          "✋",
+	"v":                             "✌️",
+	"crossed_fingers":               "🤞",
+	"handshake":                     "🤝",
+	"writing_hand":                  "✍️",
+	"facepunch":                     "👊",
+	"punch":                         "👊",
+	"fist":                          "✊",
+	"open_hands":                    "👐",
+	"eyes":                          "👀",
+	"brain":                         "🧠",
+	"smile":                         "😄",
+	"smiley":                        "😃",
+	"grinning":                      "😀",
+	"grin":                          "😁",
+	"laughing":                      "😆",
+	"satisfied":                     "😆",
+	"sweat_smile":                   "😅",
+	"joy":                           "😂",
+	"rolling_on_the_floor_laughing": "🤣",
+	"slightly_smiling_face":         "🙂",
+	"upside_down_face":              "🙃",
+	"wink":                          "😉",
+	"blush":                         "😊",
+	"innocent":                      "😇",
+	"heart_eyes":                    "😍",
+	"star-struck":                   "🤩",
+	"kissing_heart":                 "😘",
+	"yum":                           "😋",
+	"stuck_out_tongue":              "😛",
+	"stuck_out_tongue_winking_eye":  "😜",
+	"hugging_face":                  "🤗",
+	"thinking_face":                 "🤔",
+	"face_with_raised_eyebrow":      "🤨",
+	"neutral_face":                  "😐",
+	"expressionless":                "😑",
+	"no_mouth":                      "😶",
+	"smirk":                         "😏",
+	"unamused":                      "😒",
+	"face_with_rolling_eyes":        "🙄",
+	"grimacing":                     "😬",
+	"relieved":                      "😌",
+	"pensive":                       "😔",
+	"sleepy":                        "😪",
+	"sleeping":                      "😴",
+	"mask":                          "😷",
+	"face_with_thermometer":         "🤒",
+	"nauseated_face":                "🤢",
+	"exploding_head":                "🤯",
+	"sunglasses":                    "😎",
+	"nerd_face":                     "🤓",
+	"confused":                      "😕",
+	"worried":                       "😟",
+	"slightly_frowning_face":        "🙁",
+	"open_mouth":                    "😮",
+	"hushed":                        "😯",
+	"astonished":                    "😲",
+	"flushed":                       "😳",
+	"pleading_face":                 "🥺",
+	"fearful":                       "😨",
+	"cold_sweat":                    "😰",
+	"cry":                           "😢",
+	"sob":                           "😭",
+	"scream":                        "😱",
+	"disappointed":                  "😞",
+	"sweat":                         "😓",
+	"weary":                         "😩",
+	"tired_face":                    "😫",
+	"triumph":                       "😤",
+	"rage":                          "😡",
+	"angry":                         "😠",
+	"skull":                         "💀",
+	"poop":                          "💩",
+	"hankey":                        "💩",
+	"clown_face":                    "🤡",
+	"ghost":                         "👻",
+	"alien":                         "👽",
+	"robot_face":                    "🤖",
+	"see_no_evil":                   "🙈",
+	"hear_no_evil":                  "🙉",
+	"speak_no_evil":                 "🙊",
+	"partying_face":                 "🥳",
+	"face_palm":                     "🤦",
+	"shrug":                         "🤷",
+	"heart":                         "❤️",
+	"orange_heart":                  "🧡",
+	"yellow_heart":                  "💛",
+	"green_heart":                   "💚",
+	"blue_heart":                    "💙",
+	"purple_heart":                  "💜",
+	"black_heart":                   "🖤",
+	"broken_heart":                  "💔",
+	"two_hearts":                    "💕",
+	"sparkling_heart":               "💖",
+	"heartpulse":                    "💗",
+	"heartbeat":                     "💓",
+	"100":                           "💯",
+	"fire":                          "🔥",
+	"sparkles":                      "✨",
+	"star":                          "⭐",
+	"star2":                         "🌟",
+	"dizzy":                         "💫",
+	"boom":                          "💥",
+	"collision":                     "💥",
+	"zap":                           "⚡",
+	"tada":                          "🎉",
+	"confetti_ball":                 "🎊",
+	"balloon":                       "🎈",
+	"gift":                          "🎁",
+	"birthday":                      "🎂",
+	"trophy":                        "🏆",
+	"first_place_medal":             "🥇",
+	"medal":                         "🏅",
+	"sports_medal":                  "🏅",
+	"crown":                         "👑",
+	"gem":                           "💎",
+	"rocket":                        "🚀",
+	"white_check_mark":              "✅",
+	"heavy_check_mark":              "✔️",
+	"ballot_box_with_check":         "☑️",
+	"x":                             "❌",
+	"heavy_multiplication_x":        "✖️",
+	"negative_squared_cross_mark":   "❎",
+	"heavy_plus_sign":               "➕",
+	"heavy_minus_sign":              "➖",
+	"question":                      "❓",
+	"grey_question":                 "❔",
+	"exclamation":                   "❗",
+	"heavy_exclamation_mark":        "❗",
+	"bangbang":                      "‼️",
+	"interrobang":                   "⁉️",
+	"warning":                       "⚠️",
+	"no_entry":                      "⛔",
+	"no_entry_sign":                 "🚫",
+	"stop_sign":                     "🛑",
+	"rotating_light":                "🚨",
+	"bell":                          "🔔",
+	"mega":                          "📣",
+	"loudspeaker":                   "📢",
+	"speech_balloon":                "💬",
+	"thought_balloon":               "💭",
+	"bulb":                          "💡",
+	"memo":                          "📝",
+	"pencil":                        "📝",
+	"pencil2":                       "✏️",
+	"pushpin":                       "📌",
+	"round_pushpin":                 "📍",
+	"paperclip":                     "📎",
+	"link":                          "🔗",
+	"lock":                          "🔒",
+	"unlock":                        "🔓",
+	"key":                           "🔑",
+	"mag":                           "🔍",
+	"mag_right":                     "🔎",
+	"calendar":                      "📆",
+	"date":                          "📅",
+	"spiral_calendar_pad":           "🗓️",
+	"clipboard":                     "📋",
+	"chart_with_upwards_trend":      "📈",
+	"chart_with_downwards_trend":    "📉",
+	"bar_chart":                     "📊",
+	"email":                         "📧",
+	"envelope":                      "✉️",
+	"inbox_tray":                    "📥",
+	"outbox_tray":                   "📤",
+	"package":                       "📦",
+	"books":                         "📚",
+	"book":                          "📖",
+	"computer":                      "💻",
+	"iphone":                        "📱",
+	"phone":                         "☎️",
+	"telephone_receiver":            "📞",
+	"headphones":                    "🎧",
+	"camera":                        "📷",
+	"movie_camera":                  "🎥",
+	"tv":                            "📺",
+	"hammer":                        "🔨",
+	"wrench":                        "🔧",
+	"hammer_and_wrench":             "🛠️",
+	"gear":                          "⚙️",
+	"construction":                  "🚧",
+	"hourglass":                     "⌛",
+	"hourglass_flowing_sand":        "⏳",
+	"alarm_clock":                   "⏰",
+	"stopwatch":                     "⏱️",
+	"watch":                         "⌚",
+	"moneybag":                      "💰",
+	"dollar":                        "💵",
+	"money_with_wings":              "💸",
+	"chart":                         "💹",
+	"dart":                          "🎯",
+	"checkered_flag":                "🏁",
+	"triangular_flag_on_post":       "🚩",
+	"recycle":                       "♻️",
+	"arrow_up":                      "⬆️",
+	"arrow_down":                    "⬇️",
+	"arrow_left":                    "⬅️",
+	"arrow_right":                   "➡️",
+	"arrows_counterclockwise":       "🔄",
+	"repeat":                        "🔁",
+	"heavy_dollar_sign":             "💲",
+	"new":                           "🆕",
+	"ok":                            "🆗",
+	"sos":                           "🆘",
+	"red_circle":                    "🔴",
+	"large_blue_circle":             "🔵",
+	"large_green_circle":            "🟢",
+	"large_yellow_circle":           "🟡",
+	"white_circle":                  "⚪",
+	"black_circle":                  "⚫",
+	"sunny":                         "☀️",
+	"cloud":                         "☁️",
+	"umbrella":                      "☔",
+	"snowflake":                     "❄️",
+	"rainbow":                       "🌈",
+	"ocean":                         "🌊",
+	"earth_asia":                    "🌏",
+	"earth_americas":                "🌎",
+	"earth_africa":                  "🌍",
+	"globe_with_meridians":          "🌐",
+	"crescent_moon":                 "🌙",
+	"palm_tree":                     "🌴",
+	"evergreen_tree":                "🌲",
+	"deciduous_tree":                "🌳",
+	"cactus":                        "🌵",
+	"seedling":                      "🌱",
+	"herb":                          "🌿",
+	"four_leaf_clover":              "🍀",
+	"maple_leaf":                    "🍁",
+	"fallen_leaf":                   "🍂",
+	"cherry_blossom":                "🌸",
+	"rose":                          "🌹",
+	"sunflower":                     "🌻",
+	"tulip":                         "🌷",
+	"bouquet":                       "💐",
+	"dog":                           "🐶",
+	"cat":                           "🐱",
+	"mouse":                         "🐭",
+	"rabbit":                        "🐰",
+	"fox_face":                      "🦊",
+	"bear":                          "🐻",
+	"panda_face":                    "🐼",
+	"tiger":                         "🐯",
+	"lion_face":                     "🦁",
+	"cow":                           "🐮",
+	"pig":                           "🐷",
+	"frog":                          "🐸",
+	"monkey_face":                   "🐵",
+	"chicken":                       "🐔",
+	"penguin":                       "🐧",
+	"bird":                          "🐦",
+	"owl":                           "🦉",
+	"unicorn_face":                  "🦄",
+	"bee":                           "🐝",
+	"honeybee":                      "🐝",
+	"bug":                           "🐛",
+	"butterfly":                     "🦋",
+	"snail":                         "🐌",
+	"turtle":                        "🐢",
+	"snake":                         "🐍",
+	"octopus":                       "🐙",
+	"whale":                         "🐳",
+	"dolphin":                       "🐬",
+	"fish":                          "🐟",
+	"tropical_fish":                 "🐠",
+	"shark":                         "🦈",
+	"sloth":                         "🦥",
+	"coffee":                        "☕",
+	"tea":                           "🍵",
+	"beer":                          "🍺",
+	"beers":                         "🍻",
+	"wine_glass":                    "🍷",
+	"clinking_glasses":              "🥂",
+	"pizza":                         "🍕",
+	"hamburger":                     "🍔",
+	"fries":                         "🍟",
+	"taco":                          "🌮",
+	"sushi":                         "🍣",
+	"ramen":                         "🍜",
+	"rice":                          "🍚",
+	"bento":                         "🍱",
+	"bread":                         "🍞",
+	"doughnut":                      "🍩",
+	"cookie":                        "🍪",
+	"cake":                          "🍰",
+	"ice_cream":                     "🍨",
+	"popcorn":                       "🍿",
+	"apple":                         "🍎",
+	"green_apple":                   "🍏",
+	"banana":                        "🍌",
+	"watermelon":                    "🍉",
+	"strawberry":                    "🍓",
+	"peach":                         "🍑",
+	"avocado":                       "🥑",
+	"hot_pepper":                    "🌶️",
+	"car":                           "🚗",
+	"red_car":                       "🚗",
+	"taxi":                          "🚕",
+	"bus":                           "🚌",
+	"train":                         "🚋",
+	"bullettrain_side":              "🚄",
+	"airplane":                      "✈️",
+	"ship":                          "🚢",
+	"bike":                          "🚲",
+	"house":                         "🏠",
+	"house_with_garden":             "🏡",
+	"office":                        "🏢",
+	"hospital":                      "🏥",
+	"school":                        "🏫",
+	"tent":                          "⛺",
+	"desert_island":                 "🏝️",
+	"beach_with_umbrella":           "🏖️",
+	"mountain":                      "⛰️",
+	"snow_capped_mountain":          "🏔️",
+	"soccer":                        "⚽",
+	"basketball":                    "🏀",
+	"football":                      "🏈",
+	"baseball":                      "⚾",
+	"tennis":                        "🎾",
+	"golf":                          "⛳",
+	"running":                       "🏃",
+	"runner":                        "🏃",
+	"walking":                       "🚶",
+	"bicyclist":                     "🚴",
+	"swimmer":                       "🏊",
+	"video_game":                    "🎮",
+	"game_die":                      "🎲",
+	"musical_note":                  "🎵",
+	"notes":                         "🎶",
+	"microphone":                    "🎤",
+	"guitar":                        "🎸",
+	"art":                           "🎨",
+	"performing_arts":               "🎭",
+	"ticket":                        "🎫",
+	"christmas_tree":                "🎄",
+	"jack_o_lantern":                "🎃",
+	"bow":                           "🙇",
+	"raising_hand":                  "🙋",
+	"ok_woman":                      "🙆",
+	"no_good":                       "🙅",
+	"information_desk_person":       "💁",
+	"man-gesturing-ok":              "🙆‍♂️",
+	"woman-gesturing-ok":            "🙆‍♀️",
+	"baby":                          "👶",
+	"bust_in_silhouette":            "👤",
+	"busts_in_silhouette":           "👥",
+	"male-technologist":             "👨‍💻",
+	"female-technologist":           "👩‍💻",
+	"spiral_note_pad":               "🗒️",
+	"file_folder":                   "📁",
+	"open_file_folder":              "📂",
+	"page_facing_up":                "📄",
+	"newspaper":                     "📰",
+	"label":                         "🏷️",
+	"bookmark":                      "🔖",
+	"battery":                       "🔋",
+	"electric_plug":                 "🔌",
+	"satellite_antenna":             "📡",
+	"pill":                          "💊",
+	"syringe":                       "💉",
+	"thermometer":                   "🌡️",
+	"zzz":                           "💤",
+	"sweat_drops":                   "💦",
+	"dash":                          "💨",
+	"wastebasket":                   "🗑️",
+	"shipit":                        "🐿️",
+	"squirrel":                      "🐿️",
+	"building_construction":         "🏗️",
+}
+
+// skinTones are the modifiers of the ::skin-tone-2 to ::skin-tone-6
+// suffixes of emoji names.
+var skinTones = map[string]string{
+	"2": "\U0001F3FB",
+	"3": "\U0001F3FC",
+	"4": "\U0001F3FD",
+	"5": "\U0001F3FE",
+	"6": "\U0001F3FF",
+}
+
+// emojiText returns the character of an emoji name, with or without
+// colons, such as 👍 for :+1:. Flags are named flag-CC after their
+// country code. Names without a character, such as those of custom
+// emoji, are returned as :name:.
+func emojiText(name string) string {
+	name = strings.Trim(name, ":")
+	if name == "" {
+		return ""
+	}
+	base, tone := name, ""
+	if i := strings.Index(name, "::skin-tone-"); i >= 0 {
+		base, tone = name[:i], name[i+len("::skin-tone-"):]
+	}
+
+	text, ok := emojiCharacters[base]
+	if !ok && len(base) == 7 && strings.HasPrefix(base, "flag-") {
+		text, ok = flagEmoji(base[5:])
+	}
+	if !ok {
+		return ":" + name + ":"
+	}
+	return text + skinTones[tone]
+}
+
+// flagEmoji returns the flag of a two-letter country code, written as
+// a pair of regional indicator symbols.
+func flagEmoji(code string) (string, bool) {
+	var b strings.Builder
+	for _, r := range code {
+		if r < 'a' || r > 'z' {
+			return "", false
+		}
+		b.WriteRune(0x1F1E6 + r - 'a')
+	}
+	return b.String(), true
+}
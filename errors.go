@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+
+	"ssossan/slack_analytics/stats"
+)
+
+// The errors of reading inputs and writing outputs are in package
+// stats, so that programs embedding this module can inspect them.
+type (
+	ParseError  = stats.ParseError
+	ExportError = stats.ExportError
+)
+
+// jsonError returns err, from decoding data read from file, as a
+// ParseError at the line of the offending byte. It returns nil if err
+// is nil.
+func jsonError(file string, data []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return &ParseError{File: file, Cause: err}
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return &ParseError{File: file, Line: 1 + bytes.Count(data[:offset], []byte("\n")), Cause: err}
+}
+
+// csvError returns err, from reading the CSV file, as a ParseError at
+// the line of the offending record. It returns nil if err is nil.
+func csvError(file string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &ParseError{File: file, Line: parseErr.Line, Cause: parseErr.Err}
+	}
+	var lineErr *ParseError
+	if errors.As(err, &lineErr) && lineErr.File == "" {
+		return &ParseError{File: file, Line: lineErr.Line, Cause: lineErr.Cause}
+	}
+	return &ParseError{File: file, Cause: err}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"runtime"
+	"sort"
+)
+
+// messageHook is a subprocess that receives every message as one line
+// of JSON on stdin and answers each with one line on stdout holding a
+// JSON object of numeric annotations, e.g. {"sentiment": 0.8}.
+type messageHook struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// hook is started from -exec-per-message.
+var hook *messageHook
+
+// annotationKeys holds every annotation name returned by the hook.
+var annotationKeys = make(map[string]bool)
+
+type hookMessage struct {
+	Channel string `json:"channel"`
+	Message
+}
+
+func startHook(command string) (*messageHook, error) {
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return &messageHook{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}, nil
+}
+
+// annotate sends message to the hook and waits for its annotations.
+func (h *messageHook) annotate(channelName string, message Message) (map[string]float64, error) {
+	data, err := json.Marshal(hookMessage{Channel: channelName, Message: message})
+	if err != nil {
+		return nil, err
+	}
+	_, err = h.stdin.Write(append(data, '\n'))
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := h.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var annotations map[string]float64
+	err = json.Unmarshal(line, &annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range annotations {
+		annotationKeys[key] = true
+	}
+	return annotations, nil
+}
+
+func (h *messageHook) close() error {
+	h.stdin.Close()
+	return h.cmd.Wait()
+}
+
+func sortedAnnotationKeys() []string {
+	keys := make([]string, 0, len(annotationKeys))
+	for key := range annotationKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expr is a parsed arithmetic expression over named variables, as
+// given to -score-expr.
+type expr interface {
+	eval(vars map[string]float64) float64
+}
+
+type number float64
+
+type variable string
+
+type unary struct {
+	x expr
+}
+
+type binary struct {
+	op   rune
+	l, r expr
+}
+
+func (n number) eval(map[string]float64) float64 { return float64(n) }
+
+func (v variable) eval(vars map[string]float64) float64 { return vars[string(v)] }
+
+func (u unary) eval(vars map[string]float64) float64 { return -u.x.eval(vars) }
+
+// eval evaluates the operation. Division by zero yields zero, like
+// the rates of the outputs.
+func (b binary) eval(vars map[string]float64) float64 {
+	l, r := b.l.eval(vars), b.r.eval(vars)
+	switch b.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	}
+	if r == 0 {
+		return 0
+	}
+	return l / r
+}
+
+// scoreVariables are the names usable in -score-expr.
+var scoreVariables = []string{
+	"posts",
+	"received_reactions",
+	"received_reaction_users",
+	"given_reactions",
+	"given_reaction_users",
+	"received_replies",
+	"replies_received",
+	"mentions",
+	"received_mentions",
+	"mentioned_users",
+	"received_mention_users",
+	"days_active",
+}
+
+// scoreVars returns the variables of s for -score-expr.
+func scoreVars(s *Stats, daysActive int) map[string]float64 {
+	return map[string]float64{
+		"posts":                   float64(s.Posts),
+		"received_reactions":      float64(s.GivenReactions),
+		"received_reaction_users": float64(s.GivenReactionUser.Len()),
+		"given_reactions":         float64(s.ReceivedReactions),
+		"given_reaction_users":    float64(s.ReceivedReactionUsers.Len()),
+		"received_replies":        float64(s.ReceivedReplies),
+		"replies_received":        float64(s.ReceivedReplies),
+		"mentions":                float64(s.Mentions),
+		"received_mentions":       float64(s.ReceivedMentions),
+		"mentioned_users":         float64(s.MentionedUsers.Len()),
+		"received_mention_users":  float64(s.ReceivedMentionUsers.Len()),
+		"days_active":             float64(daysActive),
+	}
+}
+
+// parseExpr parses an expression of numbers, scoreVariables, + - * /
+// and parentheses.
+func parseExpr(s string) (expr, error) {
+	return parseExprOf(s, scoreVariables)
+}
+
+// parseExprOf parses an expression over the variables vars.
+func parseExprOf(s string, vars []string) (expr, error) {
+	p := &exprParser{s: []rune(s), vars: vars}
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	p.space()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.s[p.pos], p.pos+1)
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	s    []rune
+	pos  int
+	vars []string
+}
+
+func (p *exprParser) space() {
+	for p.pos < len(p.s) && unicode.IsSpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space rune, or 0 at the end.
+func (p *exprParser) peek() rune {
+	p.space()
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *exprParser) sum() (expr, error) {
+	l, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		r, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op, l, r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) product() (expr, error) {
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		r, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op, l, r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) operand() (expr, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, errors.New("unexpected end of expression")
+	case c == '-':
+		p.pos++
+		x, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return unary{x}, nil
+	case c == '(':
+		p.pos++
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return e, nil
+	case c == '.' || unicode.IsDigit(c):
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] == '.' || unicode.IsDigit(p.s[p.pos])) {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(string(p.s[start:p.pos]), 64)
+		if err != nil {
+			return nil, err
+		}
+		return number(n), nil
+	case c == '_' || unicode.IsLetter(c):
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] == '_' || unicode.IsLetter(p.s[p.pos]) || unicode.IsDigit(p.s[p.pos])) {
+			p.pos++
+		}
+		name := string(p.s[start:p.pos])
+		for _, known := range p.vars {
+			if name == known {
+				return variable(name), nil
+			}
+		}
+		return nil, errors.New("unknown variable " + name + ", expected one of " + strings.Join(p.vars, ", "))
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Metric families that -metrics can enable. Posts are always counted
+// since rows are made of them; disabling the family only drops its
+// columns. The other families are not computed when disabled.
+const (
+	familyPosts     = "posts"
+	familyReactions = "reactions"
+	familyThreads   = "threads"
+	familyMentions  = "mentions"
+)
+
+var metricFamilies = []string{familyPosts, familyReactions, familyThreads, familyMentions}
+
+// enabledFamilies holds the families selected by -metrics, or nil
+// for all.
+var enabledFamilies map[string]bool
+
+func familyEnabled(family string) bool {
+	return enabledFamilies == nil || enabledFamilies[family]
+}
+
+// columnFamilies maps output columns to their metric family. Columns
+// not listed are always written.
+var columnFamilies = buildColumnFamilies()
+
+func buildColumnFamilies() map[string]string {
+	families := map[string]string{
+		"posts":                       familyPosts,
+		"posts_percentile_workspace":  familyPosts,
+		"posts_percentile_channel":    familyPosts,
+		"holiday_posts":               familyPosts,
+		"posts_per_non_holiday_day":   familyPosts,
+		"received_reations":           familyReactions,
+		"received_reactions":          familyReactions,
+		"received_reaction_users":     familyReactions,
+		"given_reactions":             familyReactions,
+		"given_reation_users":         familyReactions,
+		"given_reaction_users":        familyReactions,
+		"received_reactions_per_post": familyReactions,
+		"distinct_reactors_per_post":  familyReactions,
+		"self_reactions":              familyReactions,
+		"received_replies":            familyThreads,
+		"replies_per_post":            familyThreads,
+		"mentions":                    familyMentions,
+		"received_mentions":           familyMentions,
+		"mentioned_users":             familyMentions,
+		"received_mention_users":      familyMentions,
+	}
+	families["received_reaction_users_overall"] = familyReactions
+	families["given_reaction_users_overall"] = familyReactions
+	families["mentioned_users_overall"] = familyMentions
+	families["received_mention_users_overall"] = familyMentions
+	for _, window := range rollingWindows {
+		w := strconv.Itoa(window)
+		families["posts_"+w+"d_avg"] = familyPosts
+		families["channel_posts_"+w+"d_avg"] = familyPosts
+		families["received_reactions_"+w+"d_avg"] = familyReactions
+		families["channel_received_reactions_"+w+"d_avg"] = familyReactions
+	}
+	return families
+}
+
+// enabledColumns returns the indexes of the header columns to write.
+func enabledColumns(header []string) []int {
+	var keep []int
+	for i, name := range header {
+		family, ok := columnFamilies[name]
+		if !ok || familyEnabled(family) {
+			keep = append(keep, i)
+		}
+	}
+	return keep
+}
+
+func selectColumns(row []string, keep []int) []string {
+	if len(keep) == len(row) {
+		return row
+	}
+	selected := make([]string, len(keep))
+	for i, j := range keep {
+		selected[i] = row[j]
+	}
+	return selected
+}
+
+// mentionedUsers returns the IDs of the users mentioned in text, as
+// <@U123> or <@U123|name>.
+func mentionedUsers(text string) []string {
+	var ids []string
+	scanMentions(text, func(id, label string) {
+		ids = append(ids, id)
+	})
+	return ids
+}
+
+// scanMentions calls f with the ID and the label, if any, of each user
+// mentioned in text. It scans the text by hand since it runs on every
+// message and a regular expression showed up in profiles.
+func scanMentions(text string, f func(id, label string)) {
+	for {
+		i := strings.Index(text, "<@")
+		if i < 0 {
+			return
+		}
+		text = text[i+2:]
+		if len(text) == 0 || (text[0] != 'U' && text[0] != 'W') {
+			continue
+		}
+		n := 1
+		for n < len(text) && (text[n] >= 'A' && text[n] <= 'Z' || text[n] >= '0' && text[n] <= '9') {
+			n++
+		}
+		if n == 1 || n == len(text) {
+			continue
+		}
+		switch text[n] {
+		case '>':
+			f(text[:n], "")
+			text = text[n+1:]
+		case '|':
+			end := strings.IndexByte(text[n:], '>')
+			if end < 0 {
+				continue
+			}
+			f(text[:n], text[n+1:n+end])
+			text = text[n+end+1:]
+		}
+	}
+}
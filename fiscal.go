@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseMonth parses the English name of a month, in full or its first
+// three letters, in any case.
+func parseMonth(name string) (time.Month, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for m := time.January; m <= time.December; m++ {
+		full := strings.ToLower(m.String())
+		if name == full || len(name) == 3 && strings.HasPrefix(full, name) {
+			return m, nil
+		}
+	}
+	return 0, errors.New("invalid month " + name + ", expected a name such as April or apr")
+}
+
+// fiscalQuarter returns the quarter of a 2006-01-02 day, as FY24Q1, in
+// fiscal years starting in month start. Fiscal years are named after
+// the calendar year they end in, so with April, April 2023 to March
+// 2024 is FY24. It returns "" for invalid days.
+func fiscalQuarter(day string, start time.Month) string {
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return ""
+	}
+	offset := (int(t.Month()) - int(start) + 12) % 12
+	endYear := t.Year()
+	if start != time.January && t.Month() >= start {
+		endYear++
+	}
+	return fmt.Sprintf("FY%02dQ%d", endYear%100, offset/3+1)
+}
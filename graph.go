@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of interactions between users.
+const (
+	interactionMention  = "mention"
+	interactionReply    = "reply"
+	interactionReaction = "reaction"
+	interactionThread   = "thread"
+)
+
+// edgeWeights holds the weight of each kind of interaction in the
+// interaction graph, see -graph-edges. Kinds missing make no edges.
+var edgeWeights = map[string]float64{
+	interactionMention:  1,
+	interactionReply:    1,
+	interactionReaction: 1,
+}
+
+// parseEdgeWeights parses a comma-separated list of kind=weight pairs
+// such as "mention=1,reply=2,thread=0.5".
+func parseEdgeWeights(list string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, pair := range strings.Split(list, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("invalid edge weight " + pair)
+		}
+		kind := strings.TrimSpace(parts[0])
+		switch kind {
+		case interactionMention, interactionReply, interactionReaction, interactionThread:
+		default:
+			return nil, errors.New("unknown interaction " + kind + ", expected mention, reply, reaction or thread")
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || weight < 0 {
+			return nil, errors.New("invalid edge weight " + pair)
+		}
+		if weight > 0 {
+			weights[kind] = weight
+		}
+	}
+	return weights, nil
+}
+
+// interaction is an interaction of user From with user To: a mention
+// of To in a message of From, a reply of From in a thread started by
+// To, a reaction of From to a message of To, or, with a weight for
+// thread in edgeWeights, both posting in the same thread.
+type interaction struct {
+	From string
+	To   string
+	Kind string
+	// At is when the message was posted, also for reactions, whose
+	// time is not exported.
+	At time.Time
+}
+
+// interactions returns the interactions of the messages between
+// distinct users that can be attributed, see lookupUser. Bot messages
+// are left out. Threads count as an interaction of every pair of their
+// participants, once, when the thread started.
+func interactions(messagesByChannel map[string][]Message, users map[string]*User) []interaction {
+	var result []interaction
+	add := func(from, to, kind string, at time.Time) {
+		if from == to || lookupUser(users, from) == nil || lookupUser(users, to) == nil {
+			return
+		}
+		result = append(result, interaction{from, to, kind, at})
+	}
+
+	for _, messages := range messagesByChannel {
+		rootAuthors := make(map[string]string)
+		for _, message := range messages {
+			if !isReply(message) {
+				rootAuthors[message.Timestamp] = message.User
+			}
+		}
+
+		for _, message := range messages {
+			if message.BotID != "" {
+				continue
+			}
+			postedAt, err := parseTimestamp(message.Timestamp)
+			if err != nil {
+				continue
+			}
+			for _, id := range mentionedUsers(message.Text) {
+				add(message.User, id, interactionMention, postedAt)
+			}
+			if isReply(message) {
+				parent := message.ParentUserID
+				if parent == "" {
+					parent = rootAuthors[message.ThreadTimestamp]
+				}
+				add(message.User, parent, interactionReply, postedAt)
+			}
+			author := creditedUser(message, users)
+			for _, reaction := range message.GivenReactions {
+				for _, u := range reaction.Users {
+					add(u, author, interactionReaction, postedAt)
+				}
+			}
+		}
+
+		if edgeWeights[interactionThread] == 0 {
+			continue
+		}
+		for ts, participants := range threadParticipants(messages) {
+			startedAt, err := parseTimestamp(ts)
+			if err != nil {
+				continue
+			}
+			for i, a := range participants {
+				for _, b := range participants[i+1:] {
+					add(a, b, interactionThread, startedAt)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// threadParticipants returns the sorted users who posted the root or a
+// reply of each thread of messages with replies, by thread ts. Bot
+// messages are left out.
+func threadParticipants(messages []Message) map[string][]string {
+	posted := make(map[string]map[string]bool)
+	replied := make(map[string]bool)
+	for _, message := range messages {
+		if message.BotID != "" || message.User == "" {
+			continue
+		}
+		ts := message.Timestamp
+		if isReply(message) {
+			ts = message.ThreadTimestamp
+			replied[ts] = true
+		}
+		if posted[ts] == nil {
+			posted[ts] = make(map[string]bool)
+		}
+		posted[ts][message.User] = true
+	}
+
+	result := make(map[string][]string, len(replied))
+	for ts := range replied {
+		participants := make([]string, 0, len(posted[ts]))
+		for userID := range posted[ts] {
+			participants = append(participants, userID)
+		}
+		sort.Strings(participants)
+		result[ts] = participants
+	}
+	return result
+}
+
+// interactionGraph is the undirected graph of the interactions between
+// users, weighted by their number times the weight of their kind in
+// edgeWeights.
+type interactionGraph struct {
+	// Nodes are the user IDs, sorted.
+	Nodes []string
+	// Edges holds the weight of the edges of each node by neighbor
+	// index, in both directions.
+	Edges []map[int]float64
+}
+
+// buildGraph returns the graph of interactions.
+func buildGraph(interactions []interaction) *interactionGraph {
+	index := make(map[string]int)
+	for _, i := range interactions {
+		if edgeWeights[i.Kind] == 0 {
+			continue
+		}
+		index[i.From] = 0
+		index[i.To] = 0
+	}
+	g := &interactionGraph{Nodes: make([]string, 0, len(index))}
+	for id := range index {
+		g.Nodes = append(g.Nodes, id)
+	}
+	sort.Strings(g.Nodes)
+	g.Edges = make([]map[int]float64, len(g.Nodes))
+	for n, id := range g.Nodes {
+		index[id] = n
+		g.Edges[n] = make(map[int]float64)
+	}
+	for _, i := range interactions {
+		weight := edgeWeights[i.Kind]
+		if weight == 0 {
+			continue
+		}
+		from, to := index[i.From], index[i.To]
+		g.Edges[from][to] += weight
+		g.Edges[to][from] += weight
+	}
+	return g
+}
+
+// degree returns the total weight of the edges of node n.
+func (g *interactionGraph) degree(n int) float64 {
+	d := 0.0
+	for _, w := range g.Edges[n] {
+		d += w
+	}
+	return d
+}
+
+// sortedNeighbors returns the neighbor indexes of an edge map in order.
+func sortedNeighbors(neighbors map[int]float64) []int {
+	sorted := make([]int, 0, len(neighbors))
+	for m := range neighbors {
+		sorted = append(sorted, m)
+	}
+	sort.Ints(sorted)
+	return sorted
+}
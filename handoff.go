@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+)
+
+// handoffWindow is the number of latest responder replies of a thread
+// in which a responder needs a strict majority to own it.
+const handoffWindow = 5
+
+// ThreadHandoffs counts the long threads of a channel and the changes
+// of their owner, the responder answering most of the latest replies.
+type ThreadHandoffs struct {
+	ChannelName string
+	LongThreads int
+	HandedOff   int
+	Handoffs    int
+}
+
+// detectHandoffs counts, for each channel, the threads with at least
+// minReplies replies and how often their ownership passed from one
+// responder to another. Responders are the repliers other than the
+// author of the root message; bots are left out.
+func detectHandoffs(messagesByChannel map[string][]Message, minReplies int) []*ThreadHandoffs {
+	var result []*ThreadHandoffs
+	for channelName, messages := range messagesByChannel {
+		type reply struct {
+			ts   string
+			user string
+		}
+		threads := make(map[string][]reply)
+		authors := make(map[string]string)
+		for _, message := range messages {
+			if message.ThreadTimestamp == "" || message.User == "" || message.BotID != "" {
+				continue
+			}
+			if !isReply(message) {
+				authors[message.ThreadTimestamp] = message.User
+				continue
+			}
+			if message.ParentUserID != "" {
+				authors[message.ThreadTimestamp] = message.ParentUserID
+			}
+			threads[message.ThreadTimestamp] = append(threads[message.ThreadTimestamp], reply{message.Timestamp, message.User})
+		}
+
+		h := &ThreadHandoffs{ChannelName: channelName}
+		for threadTs, replies := range threads {
+			if len(replies) < minReplies {
+				continue
+			}
+			h.LongThreads++
+			sort.Slice(replies, func(i, j int) bool { return replies[i].ts < replies[j].ts })
+
+			var window []string
+			owner := ""
+			handoffs := 0
+			for _, r := range replies {
+				if r.user == authors[threadTs] {
+					continue
+				}
+				window = append(window, r.user)
+				if len(window) > handoffWindow {
+					window = window[1:]
+				}
+				if dominant := majority(window); dominant != "" && dominant != owner {
+					if owner != "" {
+						handoffs++
+					}
+					owner = dominant
+				}
+			}
+			if handoffs > 0 {
+				h.HandedOff++
+				h.Handoffs += handoffs
+			}
+		}
+		if h.LongThreads > 0 {
+			result = append(result, h)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ChannelName < result[j].ChannelName })
+	return result
+}
+
+// majority returns the user holding more than half of a full window
+// of replies, or empty if there is none.
+func majority(window []string) string {
+	if len(window) < handoffWindow {
+		return ""
+	}
+	counts := make(map[string]int)
+	for _, user := range window {
+		counts[user]++
+		if counts[user]*2 > len(window) {
+			return user
+		}
+	}
+	return ""
+}
+
+func exportHandoffsCSV(fileName string, handoffs []*ThreadHandoffs) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"long_threads",
+		"threads_handed_off",
+		"handoffs",
+		"handoffs_per_long_thread",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range handoffs {
+		workspace, channelName := splitChannelKey(h.ChannelName)
+		row := []string{
+			channelName,
+			strconv.Itoa(h.LongThreads),
+			strconv.Itoa(h.HandedOff),
+			strconv.Itoa(h.Handoffs),
+			formatRate(h.Handoffs, h.LongThreads),
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// holidays holds the days loaded from the -holidays file.
+var holidays = make(map[string]bool)
+
+func isHoliday(day string) bool {
+	return holidays[day]
+}
+
+// loadHolidays reads an iCalendar file (.ics) or a text file with one
+// YYYY-MM-DD date per line. Text lines may carry a name after a comma
+// and lines starting with # are ignored.
+func loadHolidays(fileName string) (map[string]bool, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(fileName), ".ics") {
+		return parseICS(file)
+	}
+
+	days := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		date := strings.TrimSpace(strings.SplitN(line, ",", 2)[0])
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, err
+		}
+		days[t.Format("2006-01-02")] = true
+	}
+	return days, scanner.Err()
+}
+
+// parseICS collects the days covered by each VEVENT. DTEND is
+// exclusive as in RFC 5545; recurrence rules are not expanded.
+func parseICS(file *os.File) (map[string]bool, error) {
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			// Folded continuation of the previous line
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	days := make(map[string]bool)
+	var start, end time.Time
+	for _, line := range lines {
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		name := strings.ToUpper(strings.SplitN(line[:i], ";", 2)[0])
+		value := line[i+1:]
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			start, end = time.Time{}, time.Time{}
+		case name == "DTSTART":
+			start = parseICSDate(value)
+		case name == "DTEND":
+			end = parseICSDate(value)
+		case name == "END" && value == "VEVENT":
+			if start.IsZero() {
+				continue
+			}
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for t := start; t.Before(end); t = t.AddDate(0, 0, 1) {
+				days[t.Format("2006-01-02")] = true
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseICSDate(value string) time.Time {
+	if len(value) < 8 {
+		return time.Time{}
+	}
+	t, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+func exportHTML(fileName string, report *Report, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return writeHTML(file, report, users)
+}
+
+// writeHTML writes report to w as a web page, with the tables of the
+// Markdown report and a calendar of the daily posts of each channel
+// and user listed.
+func writeHTML(w io.Writer, report *Report, users map[string]*User) error {
+	first, _ := time.Parse("2006-01-02", report.From)
+	last, _ := time.Parse("2006-01-02", report.To)
+	calendar := func(daily map[string]int) (template.HTML, error) {
+		c := drawCalendar(func(w, h int) canvas { return newSVGCanvas(w, h) }, daily, first, last)
+		if c == nil {
+			return "", nil
+		}
+		var b strings.Builder
+		err := c.encode(&b)
+		return template.HTML(b.String()), err
+	}
+
+	tmpl, err := template.New("report.html.tmpl").Funcs(template.FuncMap{
+		"tr":           tr,
+		"sparkline":    sparkline,
+		"calendar":     calendar,
+		"channelLabel": channelLabel,
+		"emoji":        emojiText,
+		"userName":     func(id string) string { return displayName(users, id) },
+		"userStatus":   func(id string) string { return statusEmoji(users, id) },
+	}).ParseFS(templates, "templates/report.html.tmpl")
+	if err != nil {
+		return err
+	}
+
+	weekly := make([]int, len(report.Weeks))
+	for i, week := range report.Weeks {
+		weekly[i] = week.Posts
+	}
+
+	return tmpl.Execute(w, struct {
+		Report      *Report
+		WeeklyPosts []int
+		Lang        string
+	}{report, weekly, messageLanguage})
+}
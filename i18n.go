@@ -0,0 +1,63 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//go:embed locales
+var locales embed.FS
+
+// catalog maps English messages to those of the language of -lang, or
+// is nil for English.
+var catalog map[string]string
+
+// messageLanguage is the language of catalog.
+var messageLanguage = "en"
+
+// envLanguage returns the language of the locale of the environment,
+// such as ja for ja_JP.UTF-8.
+func envLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		fields := strings.FieldsFunc(os.Getenv(name), func(r rune) bool { return r == '_' || r == '.' || r == '-' })
+		if len(fields) > 0 {
+			return strings.ToLower(fields[0])
+		}
+	}
+	return "en"
+}
+
+// setLanguage loads the message catalog of lang. Languages without a
+// catalog are an error, except C and POSIX locales, which are English.
+func setLanguage(lang string) error {
+	if lang == "en" || lang == "c" || lang == "posix" {
+		catalog, messageLanguage = nil, "en"
+		return nil
+	}
+	data, err := locales.ReadFile("locales/" + lang + ".json")
+	if err != nil {
+		return errors.New("no messages in " + lang)
+	}
+	err = json.Unmarshal(data, &catalog)
+	if err != nil {
+		return err
+	}
+	messageLanguage = lang
+	return nil
+}
+
+// tr returns the message of the language of -lang for the English
+// message format, formatted with args if any.
+func tr(format string, args ...interface{}) string {
+	if translated, ok := catalog[format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// activeDay is a user's activity on a day over all channels.
+type activeDay struct {
+	Day      string
+	Posts    int
+	Channels []string
+}
+
+// highActivityDays returns, for each user, the days on which they
+// posted at least factor times their average posts per active day.
+func highActivityDays(statsByChannel StatsByChannel, factor float64) map[string][]*activeDay {
+	byUser := make(map[string]map[string]*activeDay)
+	for key, ud := range statsByChannel {
+		_, channelName := splitChannelKey(key)
+		for day, us := range ud {
+			for userID, s := range us {
+				if s.Posts == 0 || !includeRow(s) {
+					continue
+				}
+				if byUser[userID] == nil {
+					byUser[userID] = make(map[string]*activeDay)
+				}
+				d, ok := byUser[userID][day]
+				if !ok {
+					d = &activeDay{Day: day}
+					byUser[userID][day] = d
+				}
+				d.Posts += s.Posts
+				d.Channels = append(d.Channels, channelName)
+			}
+		}
+	}
+
+	result := make(map[string][]*activeDay)
+	for userID, days := range byUser {
+		if len(days) < 2 {
+			// A single active day is the average
+			continue
+		}
+		total := 0
+		for _, d := range days {
+			total += d.Posts
+		}
+		average := float64(total) / float64(len(days))
+		for _, d := range days {
+			if float64(d.Posts) >= factor*average {
+				sort.Strings(d.Channels)
+				result[userID] = append(result[userID], d)
+			}
+		}
+		sort.Slice(result[userID], func(i, j int) bool { return result[userID][i].Day < result[userID][j].Day })
+	}
+	return result
+}
+
+// exportICal writes a calendar of each user with high activity days
+// into the directory dirName, named after the user, with an all-day
+// event per day. Events are free time, so that overlays do not block
+// calendars.
+func exportICal(dirName string, days map[string][]*activeDay, users map[string]*User) error {
+	err := os.MkdirAll(dirName, 0755)
+	if err != nil {
+		return err
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for userID, userDays := range days {
+		if len(userDays) == 0 {
+			continue
+		}
+		name := userID
+		if u := lookupUser(users, userID); u != nil {
+			name = u.Name
+		}
+		err := writeICal(filepath.Join(dirName, fileSlug(name)+".ics"), userID, name, userDays, stamp)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeICal(fileName, userID, name string, days []*activeDay, stamp string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	line := func(format string, a ...interface{}) {
+		icalLine(w, fmt.Sprintf(format, a...))
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//slack-analytics//high activity days//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:%s", icalText("Slack activity of "+name))
+	for _, d := range days {
+		start, err := time.Parse("2006-01-02", d.Day)
+		if err != nil {
+			return err
+		}
+		line("BEGIN:VEVENT")
+		line("UID:%s-%s@slack-analytics", start.Format("20060102"), icalText(userID))
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART;VALUE=DATE:%s", start.Format("20060102"))
+		line("DTEND;VALUE=DATE:%s", start.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:%s", icalText(fmt.Sprintf("High Slack activity: %d posts", d.Posts)))
+		line("DESCRIPTION:%s", icalText("Posted in #"+strings.Join(d.Channels, ", #")))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return w.Flush()
+}
+
+// icalText escapes s as an iCalendar text value.
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// icalLine writes a content line ended by CRLF, folded into lines of
+// at most 75 octets without splitting characters.
+func icalLine(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space
+		limit = 74
+	}
+	w.WriteString(s + "\r\n")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InactiveMember is a channel member without posts or given reactions
+// in the channel during the inactivity window.
+type InactiveMember struct {
+	ChannelName string
+	UserID      string
+	// LastActive is the last day the member was active in the
+	// channel, or empty if never.
+	LastActive string
+}
+
+// findInactiveMembers checks the members listed in channels.json over
+// the window days ending at the last day of the export.
+func findInactiveMembers(statsByChannel StatsByChannel, channels map[string]*Channel, window int) []InactiveMember {
+	last := ""
+	for _, ud := range statsByChannel {
+		for day := range ud {
+			if day > last {
+				last = day
+			}
+		}
+	}
+	end, err := time.Parse("2006-01-02", last)
+	if err != nil {
+		return nil
+	}
+	start := end.AddDate(0, 0, -window+1).Format("2006-01-02")
+
+	var result []InactiveMember
+	for key, c := range channels {
+		ud := statsByChannel[key]
+		for _, member := range c.Members {
+			lastActive := ""
+			for day, us := range ud {
+				s := us[member]
+				if s == nil || (s.Posts == 0 && s.ReceivedReactions == 0) {
+					continue
+				}
+				if day > lastActive {
+					lastActive = day
+				}
+			}
+			if lastActive < start {
+				result = append(result, InactiveMember{key, member, lastActive})
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelName != result[j].ChannelName {
+			return result[i].ChannelName < result[j].ChannelName
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result
+}
+
+func exportInactiveCSV(fileName string, inactive []InactiveMember, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"user_id",
+		"display_name",
+		"name",
+		"last_active",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range inactive {
+		var displayName, name string
+		if u := lookupUser(users, m.UserID); u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+		}
+		workspace, channelName := splitChannelKey(m.ChannelName)
+		row := []string{
+			channelName,
+			m.UserID,
+			displayName,
+			name,
+			m.LastActive,
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
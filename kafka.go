@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kafkaBatchSize is the number of records published per request.
+const kafkaBatchSize = 500
+
+// kafkaSink publishes records to a Kafka topic through a Kafka REST
+// Proxy (API v2), serialized as JSON or, with a schema registry
+// behind the proxy, Avro.
+type kafkaSink struct {
+	url    string
+	topic  string
+	format string
+	client *http.Client
+}
+
+func newKafkaSink(url, topic, format string) *kafkaSink {
+	return &kafkaSink{
+		url:    strings.TrimSuffix(url, "/"),
+		topic:  topic,
+		format: format,
+		client: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// messageRecords writes a row per message of messagesByChannel to w:
+// who posted it where and when, with its reactions and replies. The
+// text is left out. Bot messages are skipped.
+func messageRecords(w io.Writer, messagesByChannel map[string][]Message) error {
+	writer := newSchemaWriter(w)
+	defer writer.Flush()
+
+	header := []string{"channel_name", "ts", "posted_at", "user_id", "thread_ts", "reactions", "replies"}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(messagesByChannel))
+	for key := range messagesByChannel {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		workspace, channelName := splitChannelKey(key)
+		for _, message := range messagesByChannel[key] {
+			if message.BotID != "" || message.User == "" || lowActivity[message.User] {
+				continue
+			}
+			postedAt, err := parseTimestamp(message.Timestamp)
+			if err != nil {
+				continue
+			}
+			reactions := 0
+			for _, reaction := range message.GivenReactions {
+				reactions += len(reaction.Users)
+			}
+			row := []string{
+				channelName,
+				message.Timestamp,
+				formatTime(postedAt),
+				message.User,
+				message.ThreadTimestamp,
+				strconv.Itoa(reactions),
+				strconv.Itoa(message.ReplyCount),
+			}
+			if multiWorkspace {
+				row = append(row, workspace)
+			}
+			err = writer.Write(row)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// publish writes the table of write, one of the CSV writers of the
+// outputs, and publishes each row as a record named by kind. Values
+// are typed by their column like in -datapackage, and empty ones are
+// null.
+func (k *kafkaSink) publish(kind string, write func(io.Writer) error) (int, error) {
+	header, rows, err := renderTable(write)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := make([]string, len(header))
+	for i, column := range header {
+		fields[i] = avroName(column)
+	}
+	var schema string
+	if k.format == "avro" {
+		schema, err = avroSchema(kind, header, fields)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for start := 0; start < len(rows); start += kafkaBatchSize {
+		end := start + kafkaBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		records := make([]map[string]interface{}, 0, end-start)
+		for _, row := range rows[start:end] {
+			value := make(map[string]interface{}, len(header))
+			for i, column := range header {
+				v := typedValue(column, row[i])
+				if k.format == "avro" && v != nil {
+					// Avro JSON names the branch of a union
+					v = map[string]interface{}{avroType(column): v}
+				}
+				value[fields[i]] = v
+			}
+			records = append(records, map[string]interface{}{"value": value})
+		}
+		err := k.post(schema, records)
+		if err != nil {
+			return start, err
+		}
+	}
+	return len(rows), nil
+}
+
+// post produces records to the topic, failing if any was rejected.
+func (k *kafkaSink) post(schema string, records []map[string]interface{}) error {
+	payload := map[string]interface{}{"records": records}
+	contentType := "application/vnd.kafka.json.v2+json"
+	if k.format == "avro" {
+		payload["value_schema"] = schema
+		contentType = "application/vnd.kafka.avro.v2+json"
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, k.url+"/topics/"+k.topic, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Message string `json:"message"`
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s %s", k.topic, resp.Status, result.Message)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("%s: error %d: %s", k.topic, *offset.ErrorCode, offset.Error)
+		}
+	}
+	return nil
+}
+
+// kafkaKinds are the records -kafka-records can publish.
+var kafkaKinds = []string{"messages", "daily", "summary"}
+
+// parseKafkaKinds parses the comma-separated list of -kafka-records.
+func parseKafkaKinds(list string) ([]string, error) {
+	var kinds []string
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.TrimSpace(kind)
+		valid := false
+		for _, name := range kafkaKinds {
+			valid = valid || kind == name
+		}
+		if !valid {
+			return nil, errors.New("-kafka-records must list messages, daily or summary")
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
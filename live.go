@@ -0,0 +1,525 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"ssossan/slack_analytics/stats"
+)
+
+// liveStore holds the stats updated by the events received by the
+// listen subcommand.
+type liveStore struct {
+	mu    sync.Mutex
+	stats StatsByChannel
+	users map[string]*User
+	// channels maps channel IDs to channel keys.
+	channels map[string]string
+	// events holds when the events applied were received, since Slack
+	// retries deliveries it got no timely answer for. Retries come
+	// within minutes, so IDs are forgotten after an hour.
+	events map[string]time.Time
+	pruned time.Time
+	// posted holds the messages counted, by channel key and ts, with
+	// the user their reactions are credited to, so that deleting one
+	// posted before the listener started does not subtract what was
+	// never added.
+	posted map[string]string
+	// reacted holds the reactions counted, by channel key, ts of the
+	// post, emoji and reactor, with the user they were credited to, so
+	// that a reaction both in the export and in an event is counted
+	// once.
+	reacted map[string]string
+	// reactions counts the reactions of each reactor to the posts of
+	// an author on a day, by channel key, day, author and reactor, so
+	// that a reactor leaves the distinct sets with their last reaction.
+	reactions map[string]int
+	// mentions counts the messages of an author mentioning a user on a
+	// day, by channel key, day, author and mentioned user, so that a
+	// user leaves the distinct sets with the last of those messages.
+	mentions map[string]int
+	// threads tracks the threads of the export while it is backfilled,
+	// and is nil otherwise.
+	threads *threadTracker
+}
+
+func newLiveStore() *liveStore {
+	return &liveStore{
+		stats:     make(StatsByChannel),
+		users:     make(map[string]*User),
+		channels:  make(map[string]string),
+		events:    make(map[string]time.Time),
+		posted:    make(map[string]string),
+		reacted:   make(map[string]string),
+		reactions: make(map[string]int),
+		mentions:  make(map[string]int),
+	}
+}
+
+// slackEvent is an event of the Events API, with the fields of the
+// event types handled.
+type slackEvent struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Text     string `json:"text"`
+	Ts       string `json:"ts"`
+	ThreadTs string `json:"thread_ts"`
+	Parent   string `json:"parent_user_id"`
+	Reaction string `json:"reaction"`
+	ItemUser string `json:"item_user"`
+	// Previous is the deleted message of message_deleted events.
+	Previous *Message `json:"previous_message"`
+	Item     struct {
+		Type    string `json:"type"`
+		Channel string `json:"channel"`
+		Ts      string `json:"ts"`
+	} `json:"item"`
+}
+
+// runListen implements the listen subcommand, which receives messages
+// and reactions from the Slack Events API and keeps the stats up to
+// date, serving them as CSV.
+func runListen(args []string) {
+	flags := flag.NewFlagSet("listen", flag.ExitOnError)
+	addr := flags.String("addr", ":3000", "address to listen on for the events of Slack")
+	statsAddr := flags.String("stats-addr", "127.0.0.1:3001", "address to serve the CSV files on, which must not be public")
+	secret := flags.String("signing-secret", os.Getenv("SLACK_SIGNING_SECRET"), "signing secret of the Slack app (default $SLACK_SIGNING_SECRET)")
+	backfill := flags.Bool("backfill", false, "count the messages of the export before listening")
+	policyFile := flags.String("policy", "", "YAML privacy policy restricting the outputs and columns served")
+	token := flags.String("token", os.Getenv("SLACK_TOKEN"), "token to fetch the messages posted since the export with -backfill (default $SLACK_TOKEN)")
+	flags.BoolVar(excludeSelf, "exclude-self-reactions", false, "leave reactions of users to their own messages out of the reaction counts, counting them in self_reactions")
+	flags.StringVar(shareCredit, "share-credit", "author", "user credited with the reactions to a shared message: author (of the original message) or sharer")
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		fmt.Println(tr("Error: Too many arguments. The correct usage is `go run . listen [FLAGS] [EXPORT_PATH]`."))
+		return
+	}
+	if *secret == "" {
+		fmt.Println(tr("Error: -signing-secret or SLACK_SIGNING_SECRET must be set."))
+		return
+	}
+	if *shareCredit != "author" && *shareCredit != "sharer" {
+		fmt.Println(tr("Error: -share-credit must be author or sharer."))
+		return
+	}
+	if *backfill && flags.NArg() == 0 {
+		fmt.Println(tr("Error: -backfill requires an export path."))
+		return
+	}
+
+	if *policyFile != "" {
+		var err error
+		policy, err = loadPolicy(*policyFile)
+		if err != nil {
+			fmt.Println(tr("Error loading privacy policy:"), err)
+			return
+		}
+	}
+
+	store := newLiveStore()
+	if flags.NArg() == 1 {
+		err := store.loadExport(flags.Arg(0))
+		if err != nil {
+			fmt.Println(tr("Error loading export:"), err)
+			return
+		}
+	}
+	if *backfill {
+		latest, err := store.backfill(flags.Arg(0))
+		if err != nil {
+			fmt.Println(tr("Error backfilling export:"), err)
+			return
+		}
+		fmt.Println(tr("Backfilled %d messages", len(store.posted)))
+		if *token != "" {
+			// Events received meanwhile are counted once
+			go store.catchUp(*token, latest)
+		}
+	}
+
+	if *statsAddr == *addr {
+		fmt.Println(tr("Error: -stats-addr must differ from -addr, which Slack must reach."))
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/slack/events", store.eventsHandler(*secret))
+	// The stats of users are served apart from the public webhook
+	statsMux := http.NewServeMux()
+	statsMux.HandleFunc("/daily.csv", func(w http.ResponseWriter, r *http.Request) {
+		if !policy.allowsOutput("daily") {
+			http.Error(w, "not allowed by the privacy policy", http.StatusForbidden)
+			return
+		}
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		w.Header().Set("Content-Type", "text/csv")
+		writeCSV(w, store.stats)
+	})
+	statsMux.HandleFunc("/summary.csv", func(w http.ResponseWriter, r *http.Request) {
+		if !policy.allowsOutput("summary") {
+			http.Error(w, "not allowed by the privacy policy", http.StatusForbidden)
+			return
+		}
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		w.Header().Set("Content-Type", "text/csv")
+		writeSummaryCSV(w, summarize(store.stats))
+	})
+
+	errs := make(chan error, 2)
+	go func() { errs <- http.ListenAndServe(*addr, mux) }()
+	go func() { errs <- http.ListenAndServe(*statsAddr, statsMux) }()
+	fmt.Println(tr("Listening on %s", *addr))
+	fmt.Println(tr("Serving the CSV files on %s", *statsAddr))
+	err := <-errs
+	fmt.Println(tr("Error listening:"), err)
+}
+
+// loadExport reads the users and channels of a Slack export, so that
+// events are attributed to named users and channels.
+func (s *liveStore) loadExport(path string) error {
+	workspaces, err := findWorkspaces(longPath(path))
+	if err != nil {
+		return err
+	}
+	for _, ws := range workspaces {
+		users, err := loadUsers(ws.UsersFile)
+		if err != nil {
+			return err
+		}
+		for id, u := range users {
+			s.users[id] = u
+		}
+	}
+	channels, err := loadChannels(workspaces)
+	if err != nil {
+		return err
+	}
+	for key, c := range channels {
+		s.channels[c.ID] = key
+	}
+	return nil
+}
+
+// eventsHandler answers the requests of the Events API: the URL
+// verification and the event callbacks.
+func (s *liveStore) eventsHandler(secret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !verifySlackSignature(secret, r.Header.Get("X-Slack-Request-Timestamp"), r.Header.Get("X-Slack-Signature"), body, time.Now()) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var envelope struct {
+			Type      string     `json:"type"`
+			Challenge string     `json:"challenge"`
+			EventID   string     `json:"event_id"`
+			Event     slackEvent `json:"event"`
+		}
+		err = json.Unmarshal(body, &envelope)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch envelope.Type {
+		case "url_verification":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(envelope.Challenge))
+		case "event_callback":
+			s.apply(envelope.EventID, envelope.Event)
+		}
+	})
+}
+
+// verifySlackSignature checks the signature Slack computes over each
+// request with the signing secret of the app. Requests older than five
+// minutes are refused so that they cannot be replayed.
+func verifySlackSignature(secret, timestamp, signature string, body []byte, now time.Time) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > 5*time.Minute || d < -5*time.Minute {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// channelKey returns the key of the stats of a channel ID, or the ID
+// itself for channels missing from the export.
+func (s *liveStore) channelKey(id string) string {
+	if key, ok := s.channels[id]; ok {
+		return key
+	}
+	return id
+}
+
+// apply updates the stats with an event. Events applied already are
+// ignored.
+func (s *liveStore) apply(eventID string, e slackEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if eventID != "" {
+		if _, ok := s.events[eventID]; ok {
+			return
+		}
+		now := time.Now()
+		s.events[eventID] = now
+		if now.Sub(s.pruned) > 10*time.Minute {
+			for id, t := range s.events {
+				if now.Sub(t) > time.Hour {
+					delete(s.events, id)
+				}
+			}
+			s.pruned = now
+		}
+	}
+
+	switch e.Type {
+	case "message":
+		switch e.Subtype {
+		case "message_deleted":
+			if e.Previous != nil {
+				s.removeMessage(s.channelKey(e.Channel), *e.Previous)
+			}
+			return
+		case "message_changed", "message_replied":
+			// Edits and thread notifications are not new posts
+			return
+		}
+		message := Message{
+			User:            e.User,
+			Text:            e.Text,
+			Timestamp:       e.Ts,
+			ThreadTimestamp: e.ThreadTs,
+			ParentUserID:    e.Parent,
+			Subtype:         e.Subtype,
+			BotID:           e.BotID,
+		}
+		s.record(s.channelKey(e.Channel), message)
+	case "reaction_added", "reaction_removed":
+		if e.Item.Type != "message" {
+			return
+		}
+		channelName := s.channelKey(e.Item.Channel)
+		if e.Type == "reaction_added" {
+			s.addReaction(channelName, e.Item.Ts, e.ItemUser, e.Reaction, e.User)
+		} else {
+			s.removeReaction(channelName, e.Item.Ts, e.Reaction, e.User)
+		}
+	}
+}
+
+// record counts a message with its reactions, unless it was counted
+// already.
+func (s *liveStore) record(channelName string, message Message) {
+	key := channelName + "/" + message.Timestamp
+	if _, ok := s.posted[key]; ok {
+		return
+	}
+	reactions := message.GivenReactions
+	message.GivenReactions = nil
+	// Count the message alone, then add it. listen runs no message
+	// hook, the only source of errors. Threads are tracked during the
+	// backfill only, so that a long-running listener keeps no state
+	// per thread.
+	delta := make(StatsByChannel)
+	attributed, _, _ := updateStats(delta, channelName, []Message{message}, s.users, s.threads)
+	if attributed == 0 {
+		return
+	}
+	s.countMentions(channelName, delta, 1)
+	s.stats.AddAll(delta)
+	s.posted[key] = creditedUser(message, s.users)
+	for _, reaction := range reactions {
+		for _, reactor := range reaction.Users {
+			s.addReaction(channelName, message.Timestamp, message.User, reaction.Name, reactor)
+		}
+	}
+}
+
+// dayStats returns the day of the post at ts and the stats of the
+// users of the channel on that day.
+func (s *liveStore) dayStats(channelName, ts string) (string, StatsByUser, bool) {
+	postedAt, err := parseTimestamp(ts)
+	if err != nil {
+		return "", nil, false
+	}
+	ud, ok := s.stats[channelName]
+	if !ok {
+		ud = make(StatsByDay)
+		s.stats[channelName] = ud
+	}
+	day := postedAt.Format("2006-01-02")
+	statsByUser, ok := ud[day]
+	if !ok {
+		statsByUser = make(StatsByUser)
+		ud[day] = statsByUser
+	}
+	return day, statsByUser, true
+}
+
+// reactionMessage returns the post at ts of author with only the
+// reaction of reactor, as counted by countReactions.
+func reactionMessage(ts, author, emoji, reactor string) Message {
+	return Message{
+		User:           author,
+		Timestamp:      ts,
+		GivenReactions: []Reaction{{Name: emoji, Users: []string{reactor}, Count: 1}},
+	}
+}
+
+// addReaction counts a reaction as the batch run does, crediting it to
+// the user the post was credited to when counted, or else to author.
+func (s *liveStore) addReaction(channelName, ts, author, emoji, reactor string) {
+	reaction := channelName + "/" + ts + "/" + emoji + "/" + reactor
+	if _, ok := s.reacted[reaction]; ok {
+		return
+	}
+	if credited, ok := s.posted[channelName+"/"+ts]; ok {
+		author = credited
+	}
+	day, statsByUser, ok := s.dayStats(channelName, ts)
+	if !ok {
+		return
+	}
+	message := reactionMessage(ts, author, emoji, reactor)
+	authorStats := statsFor(statsByUser, s.users, author)
+	if authorStats == nil {
+		skip(skipUnknownUser, channelName, message, author)
+		return
+	}
+	if lookupUser(s.users, reactor) == nil {
+		skip(skipUnknownReactor, channelName, message, reactor)
+		return
+	}
+	s.reacted[reaction] = author
+	countReactions(statsByUser, s.users, channelName, message, authorStats)
+	s.reactions[channelName+"/"+day+"/"+author+"/"+reactor]++
+}
+
+// removeReaction undoes addReaction. Reactions that were not counted,
+// such as those added before the listener started, are ignored.
+func (s *liveStore) removeReaction(channelName, ts, emoji, reactor string) {
+	reaction := channelName + "/" + ts + "/" + emoji + "/" + reactor
+	author, ok := s.reacted[reaction]
+	if !ok {
+		return
+	}
+	delete(s.reacted, reaction)
+	day, statsByUser, _ := s.dayStats(channelName, ts)
+
+	// Count the reaction alone, then subtract it
+	delta := make(StatsByUser)
+	countReactions(delta, s.users, channelName, reactionMessage(ts, author, emoji, reactor), statsFor(delta, s.users, author))
+	key := channelName + "/" + day + "/" + author + "/" + reactor
+	s.reactions[key]--
+	if s.reactions[key] == 0 {
+		delete(s.reactions, key)
+	}
+	for userID, d := range delta {
+		if s.reactions[key] > 0 {
+			// The reactor reacted to another post of the author
+			d.GivenReactionUser = stats.UserSet{}
+			d.ReceivedReactionUsers = stats.UserSet{}
+		}
+		if stats := statsByUser[userID]; stats != nil {
+			stats.Subtract(d)
+			s.prune(channelName, day, userID)
+		}
+	}
+}
+
+// removeMessage undoes the counts of a deleted message: its post,
+// mentions and reply, and the reactions it had. First and last seen
+// times are kept.
+func (s *liveStore) removeMessage(channelName string, message Message) {
+	key := channelName + "/" + message.Timestamp
+	if _, ok := s.posted[key]; !ok {
+		return
+	}
+	delete(s.posted, key)
+
+	for _, reaction := range message.GivenReactions {
+		for _, reactor := range reaction.Users {
+			s.removeReaction(channelName, message.Timestamp, reaction.Name, reactor)
+		}
+	}
+	message.GivenReactions = nil
+
+	// Count the message alone, then subtract it
+	delta := make(StatsByChannel)
+	updateStats(delta, channelName, []Message{message}, s.users, nil)
+	s.countMentions(channelName, delta, -1)
+	for day, us := range delta[channelName] {
+		for userID, d := range us {
+			// Users mentioned in another message of the author that
+			// day stay in the distinct sets
+			members, _ := d.MentionedUsers.Members()
+			for _, id := range members {
+				if s.mentions[channelName+"/"+day+"/"+d.UserID+"/"+stats.UserSymbols.Name(id)] > 0 {
+					d.MentionedUsers.Remove(id)
+				}
+			}
+			members, _ = d.ReceivedMentionUsers.Members()
+			for _, id := range members {
+				if s.mentions[channelName+"/"+day+"/"+stats.UserSymbols.Name(id)+"/"+d.UserID] > 0 {
+					d.ReceivedMentionUsers.Remove(id)
+				}
+			}
+			if stats := s.stats[channelName][day][userID]; stats != nil {
+				stats.Subtract(d)
+				s.prune(channelName, day, userID)
+			}
+		}
+	}
+}
+
+// countMentions adds n to the number of messages in which the users
+// of delta, the stats of a single message, mentioned each user.
+func (s *liveStore) countMentions(channelName string, delta StatsByChannel, n int) {
+	for day, us := range delta[channelName] {
+		for _, d := range us {
+			members, _ := d.MentionedUsers.Members()
+			for _, id := range members {
+				key := channelName + "/" + day + "/" + d.UserID + "/" + stats.UserSymbols.Name(id)
+				s.mentions[key] += n
+				if s.mentions[key] <= 0 {
+					delete(s.mentions, key)
+				}
+			}
+		}
+	}
+}
+
+// prune drops the Stats of a user on a day once removals brought all
+// of its counters back to zero, so no empty row is exported.
+func (s *liveStore) prune(channelName, day, userID string) {
+	if stats := s.stats[channelName][day][userID]; stats != nil && stats.IsZero() {
+		delete(s.stats[channelName][day], userID)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// activityTotals is a user's activity over all channels and days.
+type activityTotals struct {
+	Posts             int
+	ReceivedReactions int
+	GivenReactions    int
+	ActiveDays        int
+}
+
+func (t *activityTotals) add(o *activityTotals) {
+	t.Posts += o.Posts
+	t.ReceivedReactions += o.ReceivedReactions
+	t.GivenReactions += o.GivenReactions
+	t.ActiveDays += o.ActiveDays
+}
+
+// ManagerRollup is the activity of a manager's direct reports and of
+// everyone below them in the management chain.
+type ManagerRollup struct {
+	Manager       string
+	DirectReports int
+	Direct        activityTotals
+	OrgSize       int
+	Org           activityTotals
+
+	// parent is the manager of Manager, if any.
+	parent string
+}
+
+func userTotals(statsByChannel StatsByChannel) map[string]*activityTotals {
+	totals := make(map[string]*activityTotals)
+	days := make(map[string]map[string]bool)
+	for _, ud := range statsByChannel {
+		for day, us := range ud {
+			for userID, s := range us {
+				t, ok := totals[userID]
+				if !ok {
+					t = &activityTotals{}
+					totals[userID] = t
+					days[userID] = make(map[string]bool)
+				}
+				t.Posts += s.Posts
+				t.ReceivedReactions += s.GivenReactions
+				t.GivenReactions += s.ReceivedReactions
+				days[userID][day] = true
+			}
+		}
+	}
+	for userID, t := range totals {
+		t.ActiveDays = len(days[userID])
+	}
+	return totals
+}
+
+// rollupManagers aggregates activity along the manager attribute. A
+// manager is a user ID or an email; managers missing from users.json
+// are kept under that value.
+func rollupManagers(statsByChannel StatsByChannel, users map[string]*User) []*ManagerRollup {
+	byEmail := make(map[string]string)
+	for id, u := range users {
+		if u.Profile.Email != "" {
+			byEmail[strings.ToLower(u.Profile.Email)] = id
+		}
+	}
+	managerOf := make(map[string]string)
+	for id, u := range users {
+		if u.Attrs == nil || u.Attrs.Manager == "" {
+			continue
+		}
+		manager := u.Attrs.Manager
+		if id, ok := byEmail[strings.ToLower(manager)]; ok {
+			manager = id
+		}
+		managerOf[id] = manager
+	}
+
+	totals := userTotals(statsByChannel)
+	rollups := make(map[string]*ManagerRollup)
+	rollupFor := func(manager string) *ManagerRollup {
+		r, ok := rollups[manager]
+		if !ok {
+			r = &ManagerRollup{Manager: manager}
+			rollups[manager] = r
+		}
+		return r
+	}
+
+	for userID, manager := range managerOf {
+		t := totals[userID]
+		if t == nil {
+			t = &activityTotals{}
+		}
+
+		direct := rollupFor(manager)
+		direct.DirectReports++
+		direct.Direct.add(t)
+
+		seen := map[string]bool{userID: true}
+		for m, ok := manager, true; ok && !seen[m]; m, ok = managerOf[m] {
+			seen[m] = true
+			r := rollupFor(m)
+			r.OrgSize++
+			r.Org.add(t)
+		}
+	}
+
+	result := make([]*ManagerRollup, 0, len(rollups))
+	for _, r := range rollups {
+		r.parent = managerOf[r.Manager]
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Manager < result[j].Manager })
+	return result
+}
+
+func exportManagersCSV(fileName string, rollups []*ManagerRollup, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"manager",
+		"display_name",
+		"name",
+		"direct_reports",
+		"direct_posts",
+		"direct_received_reactions",
+		"direct_given_reactions",
+		"direct_active_days",
+		"org_size",
+		"org_posts",
+		"org_received_reactions",
+		"org_given_reactions",
+		"org_active_days",
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	suppressed := suppressedOrgs(rollups, *minGroupSize)
+	for _, r := range rollups {
+		// Groups smaller than -min-group-size are suppressed; the
+		// direct reports are part of the organization
+		org := !suppressed[r.Manager]
+		direct := org && r.DirectReports >= *minGroupSize
+		if !direct && !org {
+			continue
+		}
+		var displayName, name string
+		if u := users[r.Manager]; u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+		}
+		row := []string{
+			r.Manager,
+			displayName,
+			name,
+			strconv.Itoa(r.DirectReports),
+			strconv.Itoa(r.Direct.Posts),
+			strconv.Itoa(r.Direct.ReceivedReactions),
+			strconv.Itoa(r.Direct.GivenReactions),
+			strconv.Itoa(r.Direct.ActiveDays),
+			strconv.Itoa(r.OrgSize),
+			strconv.Itoa(r.Org.Posts),
+			strconv.Itoa(r.Org.ReceivedReactions),
+			strconv.Itoa(r.Org.GivenReactions),
+			strconv.Itoa(r.Org.ActiveDays),
+		}
+		if !direct {
+			blank(row[4:8])
+		}
+		if !org {
+			blank(row[9:13])
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// suppressedOrgs returns the managers whose organization is smaller
+// than minSize, or whose row subtracted from the organization of the
+// nearest manager above them still shown would reveal a group smaller
+// than minSize.
+func suppressedOrgs(rollups []*ManagerRollup, minSize int) map[string]bool {
+	byManager := make(map[string]*ManagerRollup)
+	for _, r := range rollups {
+		byManager[r.Manager] = r
+	}
+	suppressed := make(map[string]bool)
+	checked := make(map[string]bool)
+	var check func(r *ManagerRollup)
+	check = func(r *ManagerRollup) {
+		if checked[r.Manager] {
+			return
+		}
+		checked[r.Manager] = true
+		if r.OrgSize < minSize {
+			suppressed[r.Manager] = true
+			return
+		}
+		// Managers above are checked first, so that the nearest one
+		// shown is known; seen stops at cycles of the manager attribute
+		seen := map[string]bool{r.Manager: true}
+		for p := byManager[r.parent]; p != nil && !seen[p.Manager]; p = byManager[p.parent] {
+			seen[p.Manager] = true
+			check(p)
+			if suppressed[p.Manager] {
+				continue
+			}
+			if difference := p.OrgSize - r.OrgSize; difference > 0 && difference < minSize {
+				suppressed[r.Manager] = true
+			}
+			return
+		}
+	}
+	for _, r := range rollups {
+		check(r)
+	}
+	return suppressed
+}
+
+// blank empties the cells of a suppressed group.
+func blank(cells []string) {
+	for i := range cells {
+		cells[i] = ""
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// ManifestEntry records the size and SHA-256 of one export file.
+type ManifestEntry struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// buildManifest hashes every JSON file under basePath. Paths are
+// relative to basePath with forward slashes.
+func buildManifest(basePath string) ([]*ManifestEntry, error) {
+	var entries []*ManifestEntry
+	err := filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(basePath, path)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &ManifestEntry{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: sum})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func exportManifestCSV(fileName string, entries []*ManifestEntry) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	err = writer.Write([]string{"path", "bytes", "sha256"})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		err := writer.Write([]string{e.Path, strconv.FormatInt(e.Size, 10), e.SHA256})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadManifest(fileName string) (map[string]*ManifestEntry, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, csvError(fileName, err)
+	}
+	if len(records) == 0 || len(records[0]) < 3 || records[0][0] != "path" {
+		return nil, &ParseError{File: fileName, Cause: errors.New("not a manifest file")}
+	}
+
+	entries := make(map[string]*ManifestEntry)
+	for _, record := range records[1:] {
+		size, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		entries[record[0]] = &ManifestEntry{Path: record[0], Size: size, SHA256: record[2]}
+	}
+	return entries, nil
+}
+
+// compareManifest returns a line for every file that was added,
+// removed, truncated or changed since the manifest was written.
+func compareManifest(recorded map[string]*ManifestEntry, current []*ManifestEntry) []string {
+	var changes []string
+	seen := make(map[string]bool)
+	for _, e := range current {
+		seen[e.Path] = true
+		old, ok := recorded[e.Path]
+		switch {
+		case !ok:
+			changes = append(changes, "added "+e.Path)
+		case e.Size < old.Size:
+			changes = append(changes, fmt.Sprintf("truncated %s (%d bytes, was %d)", e.Path, e.Size, old.Size))
+		case e.SHA256 != old.SHA256:
+			changes = append(changes, "changed "+e.Path)
+		}
+	}
+
+	var missing []string
+	for path := range recorded {
+		if !seen[path] {
+			missing = append(missing, "missing "+path)
+		}
+	}
+	sort.Strings(missing)
+	return append(changes, missing...)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"embed"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws values as a line of block characters scaled to the
+// largest value.
+func sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = v * (len(sparkBlocks) - 1) / max
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// channelLabel returns the channel of key, prefixed by its workspace
+// if any.
+func channelLabel(key string) string {
+	workspace, channelName := splitChannelKey(key)
+	if workspace != "" {
+		return workspace + "/" + channelName
+	}
+	return channelName
+}
+
+// displayName returns the display name of a user, or their name or ID
+// if they have none.
+func displayName(users map[string]*User, userID string) string {
+	user := lookupUser(users, userID)
+	if user == nil {
+		return userID
+	}
+	if user.Profile.DisplayName != "" {
+		return user.Profile.DisplayName
+	}
+	return user.Name
+}
+
+// statusEmoji returns the status emoji of a user, if any.
+func statusEmoji(users map[string]*User, userID string) string {
+	if user := lookupUser(users, userID); user != nil {
+		return user.Profile.StatusEmoji
+	}
+	return ""
+}
+
+func exportMarkdown(fileName string, report *Report, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return writeMarkdown(file, report, users)
+}
+
+// writeMarkdown writes report to w in Markdown.
+func writeMarkdown(w io.Writer, report *Report, users map[string]*User) error {
+	tmpl, err := template.New("report.md.tmpl").Funcs(template.FuncMap{
+		"cell":         markdownCell,
+		"tr":           tr,
+		"sparkline":    sparkline,
+		"channelLabel": channelLabel,
+		"emoji":        emojiText,
+		"userName":     func(id string) string { return displayName(users, id) },
+		"userStatus":   func(id string) string { return statusEmoji(users, id) },
+	}).ParseFS(templates, "templates/report.md.tmpl")
+	if err != nil {
+		return err
+	}
+
+	weekly := make([]int, len(report.Weeks))
+	for i, week := range report.Weeks {
+		weekly[i] = week.Posts
+	}
+
+	return tmpl.Execute(w, struct {
+		Report      *Report
+		WeeklyPosts []int
+	}{report, weekly})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+// mattermostAdapter reads Mattermost bulk exports: JSON Lines files
+// written by `mmctl export create`, with one user, channel or post per
+// line. Usernames are used as user IDs. Direct messages are left out,
+// like those of Slack exports.
+type mattermostAdapter struct{}
+
+type mattermostLine struct {
+	Type string `json:"type"`
+	User *struct {
+		Username  string `json:"username"`
+		Email     string `json:"email"`
+		Position  string `json:"position"`
+		Nickname  string `json:"nickname"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Roles     string `json:"roles"`
+		DeleteAt  int64  `json:"delete_at"`
+	} `json:"user"`
+	Post *mattermostPost `json:"post"`
+}
+
+type mattermostPost struct {
+	Team      string               `json:"team"`
+	Channel   string               `json:"channel"`
+	User      string               `json:"user"`
+	Message   string               `json:"message"`
+	CreateAt  int64                `json:"create_at"`
+	Reactions []mattermostReaction `json:"reactions"`
+	Replies   []mattermostPost     `json:"replies"`
+}
+
+type mattermostReaction struct {
+	User      string `json:"user"`
+	EmojiName string `json:"emoji_name"`
+}
+
+func (mattermostAdapter) load(path string) (*importedExport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	export := &importedExport{
+		path:     path,
+		users:    make(map[string]*User),
+		messages: make(map[string][]Message),
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var line mattermostLine
+		err := json.Unmarshal(scanner.Bytes(), &line)
+		if err != nil {
+			return nil, &ParseError{File: path, Line: n, Cause: err}
+		}
+
+		switch {
+		case line.Type == "user" && line.User != nil:
+			u := line.User
+			displayName := u.Nickname
+			if displayName == "" {
+				displayName = strings.TrimSpace(u.FirstName + " " + u.LastName)
+			}
+			export.users[u.Username] = &User{
+				ID:           u.Username,
+				Name:         u.Username,
+				Profile:      Profile{DisplayName: displayName, Email: u.Email, Title: u.Position},
+				IsRestricted: strings.Contains(u.Roles, "system_guest"),
+				Deleted:      u.DeleteAt > 0,
+			}
+		case line.Type == "post" && line.Post != nil:
+			p := line.Post
+			key := channelKey(p.Team, p.Channel)
+			root := mattermostMessage(*p)
+			export.messages[key] = append(export.messages[key], root)
+			for _, reply := range p.Replies {
+				message := mattermostMessage(reply)
+				message.ThreadTimestamp = root.Timestamp
+				message.ParentUserID = root.User
+				export.messages[key] = append(export.messages[key], message)
+			}
+		}
+	}
+	return export, scanner.Err()
+}
+
+// mattermostMessage converts a post, grouping its reactions by emoji.
+func mattermostMessage(p mattermostPost) Message {
+	ts := slackTimestamp(time.Unix(0, p.CreateAt*int64(time.Millisecond)))
+	message := Message{User: p.User, Text: p.Message, Timestamp: ts}
+	if len(p.Replies) > 0 {
+		message.ThreadTimestamp = ts
+		message.ReplyCount = len(p.Replies)
+	}
+	byEmoji := make(map[string]int)
+	for _, r := range p.Reactions {
+		i, ok := byEmoji[r.EmojiName]
+		if !ok {
+			i = len(message.GivenReactions)
+			byEmoji[r.EmojiName] = i
+			message.GivenReactions = append(message.GivenReactions, Reaction{Name: r.EmojiName})
+		}
+		message.GivenReactions[i].Users = append(message.GivenReactions[i].Users, r.User)
+		message.GivenReactions[i].Count++
+	}
+	return message
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"strings"
+)
+
+// readPreviousCSV returns the records of fileName, or nil if it does
+// not exist yet.
+func readPreviousCSV(fileName string) ([][]string, error) {
+	file, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	return records, csvError(fileName, err)
+}
+
+// mergePreviousCSV appends to the daily file fileName the previous
+// rows of channel, day and user combinations it does not have, so that
+// the new rows win conflicts.
+func mergePreviousCSV(fileName string, previous [][]string) error {
+	if len(previous) == 0 {
+		return nil
+	}
+	current, err := readPreviousCSV(fileName)
+	if err != nil {
+		return err
+	}
+	if len(current) == 0 || strings.Join(current[0], ",") != strings.Join(previous[0], ",") {
+		return errors.New("columns differ from the existing file, merge needs the same flags as the earlier runs")
+	}
+
+	var keyColumns []int
+	hasUserID := false
+	for i, name := range current[0] {
+		switch name {
+		case "workspace", "channel_name", "day", "user_id":
+			keyColumns = append(keyColumns, i)
+		}
+		hasUserID = hasUserID || name == "user_id"
+	}
+	if !hasUserID {
+		return errors.New("no user_id column to merge the rows on, the privacy policy must not deny it")
+	}
+	key := func(row []string) string {
+		parts := make([]string, len(keyColumns))
+		for i, c := range keyColumns {
+			if c < len(row) {
+				parts[i] = row[c]
+			}
+		}
+		return strings.Join(parts, "\x00")
+	}
+
+	keys := make(map[string]bool)
+	for _, row := range current[1:] {
+		keys[key(row)] = true
+	}
+
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	for _, row := range previous[1:] {
+		if keys[key(row)] {
+			continue
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
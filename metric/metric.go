@@ -0,0 +1,27 @@
+// Package metric defines the interface of metric plugins.
+//
+// A plugin is a Go package main built with -buildmode=plugin that
+// exports a constructor:
+//
+//	func New() metric.Metric
+//
+// Each metric adds one column to the daily output.
+package metric
+
+// Message is a Slack message as seen by a Metric.
+type Message struct {
+	Channel   string
+	Day       string
+	User      string
+	Text      string
+	Timestamp string
+}
+
+type Metric interface {
+	// Name is the column header of the metric.
+	Name() string
+	// Observe is called once for every message of the export.
+	Observe(m Message)
+	// Value returns the column value for user on day in channel.
+	Value(channel, day, user string) string
+}
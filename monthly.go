@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+)
+
+// monthlyStats totals the Stats of every user per channel and month.
+// Distinct user sets are unioned over the month, which summing the
+// daily counts would not give.
+func monthlyStats(statsByChannel StatsByChannel) map[string]map[string]SummaryByUser {
+	return periodStats(statsByChannel, func(day string) string {
+		if len(day) < 7 {
+			return ""
+		}
+		return day[:7]
+	})
+}
+
+// periodStats totals the Stats of every user per channel and period,
+// as returned by period for each day. Days without a period are left
+// out.
+func periodStats(statsByChannel StatsByChannel, period func(day string) string) map[string]map[string]SummaryByUser {
+	result := make(map[string]map[string]SummaryByUser)
+	for channelName, ud := range statsByChannel {
+		periods := make(map[string]SummaryByUser)
+		for day, us := range ud {
+			p := period(day)
+			if p == "" {
+				continue
+			}
+			su, ok := periods[p]
+			if !ok {
+				su = make(SummaryByUser)
+				periods[p] = su
+			}
+			for userID, s := range us {
+				summary, ok := su[userID]
+				if !ok {
+					summary = newSummary(s)
+					su[userID] = summary
+				}
+				summary.Stats.Add(s)
+				summary.DaysActive++
+			}
+		}
+		result[channelName] = periods
+	}
+	return result
+}
+
+// exportPeriodCSV writes the totals of periodStats, with the period in
+// a column named column. Periods are ordered by less, or by name if
+// nil.
+func exportPeriodCSV(fileName, column string, totals map[string]map[string]SummaryByUser, less func(a, b string) bool) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"display_name",
+		"name",
+		"channel_name",
+		column,
+		"days_active",
+		"posts",
+		"received_reactions",
+		"received_reaction_users",
+		"given_reactions",
+		"given_reaction_users",
+		"mentioned_users",
+		"received_mention_users",
+		"row_type",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	keep := enabledColumns(header)
+	err = writer.Write(selectColumns(header, keep))
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		workspace, channelName := splitChannelKey(key)
+		periods := make([]string, 0, len(totals[key]))
+		for period := range totals[key] {
+			periods = append(periods, period)
+		}
+		if less == nil {
+			sort.Strings(periods)
+		} else {
+			sort.Slice(periods, func(i, j int) bool { return less(periods[i], periods[j]) })
+		}
+		for _, period := range periods {
+			su := totals[key][period]
+			userIDs := make([]string, 0, len(su))
+			for userID, s := range su {
+				if includeRow(&s.Stats) {
+					userIDs = append(userIDs, userID)
+				}
+			}
+			sort.Strings(userIDs)
+			for _, userID := range userIDs {
+				s := su[userID]
+				row := []string{
+					s.DisplayName,
+					s.Name,
+					channelName,
+					period,
+					strconv.Itoa(s.DaysActive),
+					strconv.Itoa(s.Posts),
+					strconv.Itoa(s.GivenReactions),
+					strconv.Itoa(s.GivenReactionUser.Len()),
+					strconv.Itoa(s.ReceivedReactions),
+					strconv.Itoa(s.ReceivedReactionUsers.Len()),
+					strconv.Itoa(s.MentionedUsers.Len()),
+					strconv.Itoa(s.ReceivedMentionUsers.Len()),
+					rowType(&s.Stats),
+				}
+				if multiWorkspace {
+					row = append(row, workspace)
+				}
+				err := writer.Write(selectColumns(row, keep))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
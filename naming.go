@@ -0,0 +1,187 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// channelUsage is the activity of a channel over the whole export.
+type channelUsage struct {
+	ChannelName string
+	Posts       int
+	Posters     int
+	Reactions   int
+	// LastActive is the last day with posts, or empty if none.
+	LastActive string
+	// Activity is active, quiet or none, see activityLevel.
+	Activity string
+}
+
+// channelUsages returns the activity of the channels with messages and
+// of those listed in channels.json, by channel key.
+func channelUsages(statsByChannel StatsByChannel, channels map[string]*Channel, window int) map[string]*channelUsage {
+	usages := make(map[string]*channelUsage)
+	for key := range channels {
+		usages[key] = &channelUsage{ChannelName: key}
+	}
+	for key, ud := range statsByChannel {
+		u, ok := usages[key]
+		if !ok {
+			u = &channelUsage{ChannelName: key}
+			usages[key] = u
+		}
+		posters := make(map[string]bool)
+		for day, us := range ud {
+			for userID, s := range us {
+				u.Posts += s.Posts
+				u.Reactions += s.ReceivedReactions
+				if s.Posts > 0 {
+					posters[userID] = true
+					if day > u.LastActive {
+						u.LastActive = day
+					}
+				}
+			}
+		}
+		u.Posters = len(posters)
+	}
+
+	start := ""
+	if end, err := time.Parse("2006-01-02", lastStatsDay(statsByChannel)); err == nil {
+		start = end.AddDate(0, 0, -window+1).Format("2006-01-02")
+	}
+	for _, u := range usages {
+		u.Activity = activityLevel(u.LastActive, start)
+	}
+	return usages
+}
+
+// lastStatsDay returns the last day of the stats, or empty if none.
+func lastStatsDay(statsByChannel StatsByChannel) string {
+	last := ""
+	for _, ud := range statsByChannel {
+		for day := range ud {
+			if day > last {
+				last = day
+			}
+		}
+	}
+	return last
+}
+
+// activityLevel is active for a channel with posts since the day start,
+// quiet for one with posts only before, and none for one without posts.
+func activityLevel(lastActive, start string) string {
+	switch {
+	case lastActive == "":
+		return "none"
+	case lastActive >= start:
+		return "active"
+	}
+	return "quiet"
+}
+
+// namingConvention is the -naming-prefixes channel names must start
+// with, except those of -naming-exempt.
+type namingConvention struct {
+	prefixes []string
+	exempt   map[string]bool
+}
+
+// parseNamingConvention parses the comma-separated prefixes and exempt
+// channel names.
+func parseNamingConvention(prefixes, exempt string) namingConvention {
+	c := namingConvention{exempt: make(map[string]bool)}
+	for _, p := range strings.Split(prefixes, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			c.prefixes = append(c.prefixes, p)
+		}
+	}
+	for _, name := range strings.Split(exempt, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			c.exempt[name] = true
+		}
+	}
+	return c
+}
+
+// allows reports whether a channel name follows the convention.
+func (c namingConvention) allows(channelName string) bool {
+	if c.exempt[channelName] {
+		return true
+	}
+	for _, p := range c.prefixes {
+		if strings.HasPrefix(channelName, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// namingViolations returns the channels whose name breaks the
+// convention, most posts first.
+func namingViolations(usages map[string]*channelUsage, c namingConvention) []*channelUsage {
+	var result []*channelUsage
+	for key, u := range usages {
+		if _, channelName := splitChannelKey(key); !c.allows(channelName) {
+			result = append(result, u)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Posts != result[j].Posts {
+			return result[i].Posts > result[j].Posts
+		}
+		return result[i].ChannelName < result[j].ChannelName
+	})
+	return result
+}
+
+func exportNamingCSV(fileName string, violations []*channelUsage) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"posts",
+		"posters",
+		"reactions",
+		"last_active",
+		"activity",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range violations {
+		workspace, channelName := splitChannelKey(u.ChannelName)
+		row := []string{
+			channelName,
+			strconv.Itoa(u.Posts),
+			strconv.Itoa(u.Posters),
+			strconv.Itoa(u.Reactions),
+			u.LastActive,
+			u.Activity,
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
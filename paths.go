@@ -0,0 +1,31 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// outputFileName returns the name of the daily file for basePath: the
+// path without dots, separators or drive colons, in the current
+// directory. A \\?\ prefix of a Windows long path is dropped first.
+func outputFileName(basePath string) string {
+	name := strings.TrimPrefix(basePath, `\\?\`)
+	name = strings.NewReplacer(".", "", "/", "", `\`, "", ":", "").Replace(name)
+	return "./" + name + ".csv"
+}
+
+// longPath returns path in a form that may exceed the 260 character
+// limit of Windows paths. Go adds the \\?\ prefix to absolute paths
+// itself, so relative paths are made absolute. Other systems have no
+// such limit.
+func longPath(path string) string {
+	if runtime.GOOS != "windows" || strings.HasPrefix(path, `\\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestOutputFileName(t *testing.T) {
+	tests := []struct {
+		basePath string
+		want     string
+	}{
+		{"slack-export", "./slack-export.csv"},
+		{"./exports/slack", "./exportsslack.csv"},
+		{"../exports/slack.v2/", "./exportsslackv2.csv"},
+		{`C:\Users\analyst\slack-export`, "./CUsersanalystslack-export.csv"},
+		{`C:/Users/analyst/slack-export`, "./CUsersanalystslack-export.csv"},
+		{`D:slack-export`, "./Dslack-export.csv"},
+		{`\\?\C:\exports\slack-export`, "./Cexportsslack-export.csv"},
+		{`\\?\UNC\server\share\slack-export`, "./UNCservershareslack-export.csv"},
+		{`exports\team:general`, "./exportsteamgeneral.csv"},
+		{`exports\dev\ops:oncall\2024`, "./exportsdevopsoncall2024.csv"},
+	}
+	for _, tt := range tests {
+		got := outputFileName(tt.basePath)
+		if got != tt.want {
+			t.Errorf("outputFileName(%q) = %q, want %q", tt.basePath, got, tt.want)
+		}
+		if strings.ContainsAny(strings.TrimPrefix(got, "./"), `/\:`) {
+			t.Errorf("outputFileName(%q) = %q leaves a separator or colon", tt.basePath, got)
+		}
+	}
+}
+
+func TestLongPath(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		for _, path := range []string{"export", "./export", "/tmp/export", `C:\export`} {
+			if got := longPath(path); got != path {
+				t.Errorf("longPath(%q) = %q, want it unchanged", path, got)
+			}
+		}
+		return
+	}
+
+	for _, path := range []string{`\\?\C:\export`, `\\server\share\export`} {
+		if got := longPath(path); got != path {
+			t.Errorf("longPath(%q) = %q, want it unchanged", path, got)
+		}
+	}
+	abs, err := filepath.Abs("export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := longPath("export"); got != abs {
+		t.Errorf("longPath(%q) = %q, want %q", "export", got, abs)
+	}
+	if got := longPath(`C:\export`); got != `C:\export` {
+		t.Errorf("longPath(%q) = %q, want it unchanged", `C:\export`, got)
+	}
+}
+
+// TestLongPathFiles writes and reads a file deeper than the 260
+// character limit of Windows paths, from a relative path.
+func TestLongPathFiles(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	rel := filepath.Join(strings.Repeat("c", 60), strings.Repeat("h", 60), strings.Repeat("a", 60), strings.Repeat("n", 60), strings.Repeat("l", 60))
+	if len(filepath.Join(dir, rel)) <= 260 {
+		t.Fatalf("path of %d characters is not long", len(filepath.Join(dir, rel)))
+	}
+	err = os.MkdirAll(longPath(rel), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(rel, "2024-01-02.json")
+	err = ioutil.WriteFile(longPath(fileName), []byte("[]"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := readMessagesFromJSONFile(longPath(fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Errorf("read %d messages, want 0", len(messages))
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// A4 page size and margin, in points.
+const (
+	pdfWidth  = 595.0
+	pdfHeight = 842.0
+	pdfMargin = 50.0
+)
+
+// pdfDocument lays out text and rectangles on A4 pages, starting a new
+// page when the current one is full. Text is written in the standard
+// Helvetica fonts, which only cover Latin-1, or in the TrueType font
+// of -pdf-font, embedded in the file. Text the font cannot show is an
+// error rather than being written as "?".
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	y     float64
+	font  *ttfFont
+	// used maps the glyphs of font shown to their character, for
+	// their widths and the text to copy from the PDF.
+	used map[uint16]rune
+	err  error
+}
+
+func newPDF(font *ttfFont) *pdfDocument {
+	d := &pdfDocument{font: font, used: make(map[uint16]rune)}
+	d.newPage()
+	return d
+}
+
+func (d *pdfDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfHeight - pdfMargin
+}
+
+func (d *pdfDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// space moves down by height, starting a new page if it does not fit.
+func (d *pdfDocument) space(height float64) {
+	if d.y-height < pdfMargin {
+		d.newPage()
+	}
+	d.y -= height
+}
+
+// text writes s in black with its baseline at x, y.
+func (d *pdfDocument) text(x, y, size float64, bold bool, s string) {
+	d.page().WriteString("0 g 0 G ")
+	d.showText(d.page(), x, y, size, bold, s)
+}
+
+// showText writes to w the operators showing s with its baseline at
+// x, y, in the current color. The embedded font has no bold face, so
+// bold text is also stroked.
+func (d *pdfDocument) showText(w io.Writer, x, y, size float64, bold bool, s string) {
+	if d.font != nil {
+		mode := "0 Tr"
+		if bold {
+			mode = pdfNumber(size/30) + " w 2 Tr"
+		}
+		fmt.Fprintf(w, "BT /F3 %s Tf %s %s %s Td <%s> Tj ET\n", pdfNumber(size), mode, pdfNumber(x), pdfNumber(y), d.glyphString(s))
+		return
+	}
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(w, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, pdfNumber(size), pdfNumber(x), pdfNumber(y), d.latin1String(s))
+}
+
+// fail records the first error of the document.
+func (d *pdfDocument) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// rect fills a rectangle in the given gray level (0 black, 1 white).
+func (d *pdfDocument) rect(x, y, w, h, gray float64) {
+	fmt.Fprintf(d.page(), "%s g %s %s %s %s re f\n", pdfNumber(gray), pdfNumber(x), pdfNumber(y), pdfNumber(w), pdfNumber(h))
+}
+
+// line writes one line of text at the left margin.
+func (d *pdfDocument) line(size float64, bold bool, s string) {
+	d.space(size * 1.4)
+	d.text(pdfMargin, d.y, size, bold, s)
+}
+
+// row writes one table row with cells starting at the given columns.
+func (d *pdfDocument) row(columns []float64, bold bool, cells ...string) {
+	d.space(14)
+	for i, cell := range cells {
+		d.text(columns[i], d.y, 9, bold, cell)
+	}
+}
+
+func pdfNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// pdfText replaces the control characters of s, such as line breaks
+// in names, by spaces.
+func pdfText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// latin1String escapes s for a PDF string in WinAnsiEncoding.
+func (d *pdfDocument) latin1String(s string) string {
+	var b strings.Builder
+	for _, r := range pdfText(s) {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			d.fail(fmt.Errorf("%q has characters outside Latin-1, which need a TrueType font given with -pdf-font", s))
+			b.WriteRune('?')
+		}
+	}
+	return b.String()
+}
+
+// glyphString returns s as the hex string of the glyphs of the
+// embedded font.
+func (d *pdfDocument) glyphString(s string) string {
+	var b strings.Builder
+	for _, r := range pdfText(s) {
+		glyph, ok := d.font.glyphs[r]
+		if !ok || int(glyph) >= len(d.font.advances) {
+			d.fail(fmt.Errorf("%q has %q, which the font %s lacks", s, r, d.font.name))
+		}
+		d.used[glyph] = r
+		fmt.Fprintf(&b, "%04x", glyph)
+	}
+	return b.String()
+}
+
+// pdfCanvas draws a chart on a PDF page, so that the charts of the HTML
+// report are drawn the same. Pixels are scaled to points when the
+// chart is placed by drawCanvas.
+type pdfCanvas struct {
+	d             *pdfDocument
+	width, height int
+	b             bytes.Buffer
+}
+
+func newPDFCanvas(d *pdfDocument, width, height int) *pdfCanvas {
+	return &pdfCanvas{d: d, width: width, height: height}
+}
+
+func pdfColor(c color.RGBA) string {
+	return fmt.Sprintf("%s %s %s", pdfNumber(float64(c.R)/255), pdfNumber(float64(c.G)/255), pdfNumber(float64(c.B)/255))
+}
+
+func (p *pdfCanvas) rect(x, y, w, h int, c color.RGBA) {
+	fmt.Fprintf(&p.b, "%s rg %d %d %d %d re f\n", pdfColor(c), x, p.height-y-h, w, h)
+}
+
+func (p *pdfCanvas) line(x1, y1, x2, y2 int, c color.RGBA) {
+	fmt.Fprintf(&p.b, "%s RG 1.5 w %d %d m %d %d l S\n", pdfColor(c), x1, p.height-y1, x2, p.height-y2)
+}
+
+func (p *pdfCanvas) text(x, y int, s string, c color.RGBA) {
+	fmt.Fprintf(&p.b, "%s rg ", pdfColor(c))
+	p.d.showText(&p.b, float64(x), float64(p.height-y-fontHeight+2), 8, false, s)
+}
+
+func (p *pdfCanvas) encode(w io.Writer) error {
+	_, err := w.Write(p.b.Bytes())
+	return err
+}
+
+// drawCanvas places the chart drawn on c at the left margin, scaled to
+// fit the width of the page.
+func (d *pdfDocument) drawCanvas(c *pdfCanvas) {
+	scale := math.Min(0.75, (pdfWidth-2*pdfMargin)/float64(c.width))
+	d.space(float64(c.height) * scale)
+	fmt.Fprintf(d.page(), "q %s 0 0 %s %s %s cm\n", pdfNumber(scale), pdfNumber(scale), pdfNumber(pdfMargin), pdfNumber(d.y))
+	c.encode(d.page())
+	d.page().WriteString("Q\n")
+}
+
+// calendar draws the calendar of the daily posts of the HTML report.
+func (d *pdfDocument) calendar(daily map[string]int, first, last time.Time) {
+	c := drawCalendar(func(w, h int) canvas { return newPDFCanvas(d, w, h) }, daily, first, last)
+	if c != nil {
+		d.drawCanvas(c.(*pdfCanvas))
+	}
+}
+
+// sparkline draws values as small bars from x on the current line, in
+// place of the sparkline of the HTML report.
+func (d *pdfDocument) sparkline(x, width float64, values []int) {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		return
+	}
+	w := width / float64(len(values))
+	for i, v := range values {
+		if v > 0 {
+			d.rect(x+float64(i)*w, d.y-1, math.Max(w-0.5, 0.5), 9*float64(v)/float64(max), 0.4)
+		}
+	}
+}
+
+// bytes returns the document as a PDF file, or the first error of
+// its text.
+func (d *pdfDocument) bytes() ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1-4 are the catalog, page tree and fonts, followed by
+	// a page and its content stream for every page, and the objects
+	// of the embedded font if any.
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = strconv.Itoa(5+2*i) + " 0 R"
+	}
+	fonts := "/F1 3 0 R /F2 4 0 R"
+	fontObject := 5 + 2*len(d.pages)
+	if d.font != nil {
+		fonts += " /F3 " + strconv.Itoa(fontObject) + " 0 R"
+	}
+	out.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pdfNumber(pdfWidth), pdfNumber(pdfHeight), fonts, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+	if d.font != nil {
+		for _, body := range d.fontObjects(fontObject) {
+			object(body)
+		}
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), nil
+}
+
+// fontObjects returns the objects embedding the TrueType font, numbered
+// from first: a Type 0 font showing glyph IDs, its CID font, font
+// descriptor, font file and the map of its glyphs back to text.
+func (d *pdfDocument) fontObjects(first int) []string {
+	f := d.font
+	scale := func(v int) string { return strconv.Itoa(v * 1000 / f.unitsPerEm) }
+	ref := func(i int) string { return strconv.Itoa(first+i) + " 0 R" }
+
+	glyphs := make([]int, 0, len(d.used))
+	for glyph := range d.used {
+		glyphs = append(glyphs, int(glyph))
+	}
+	sort.Ints(glyphs)
+	var widths, unicode strings.Builder
+	for i, glyph := range glyphs {
+		fmt.Fprintf(&widths, "%d [%s] ", glyph, scale(f.advances[glyph]))
+		if i%100 == 0 {
+			if i > 0 {
+				unicode.WriteString("endbfchar\n")
+			}
+			n := len(glyphs) - i
+			if n > 100 {
+				n = 100
+			}
+			fmt.Fprintf(&unicode, "%d beginbfchar\n", n)
+		}
+		fmt.Fprintf(&unicode, "<%04x> <", glyph)
+		for _, u := range utf16.Encode([]rune{d.used[uint16(glyph)]}) {
+			fmt.Fprintf(&unicode, "%04x", u)
+		}
+		unicode.WriteString(">\n")
+	}
+	if len(glyphs) > 0 {
+		unicode.WriteString("endbfchar\n")
+	}
+	toUnicode := "/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <ffff>\nendcodespacerange\n" +
+		unicode.String() + "endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n"
+
+	var file bytes.Buffer
+	z := zlib.NewWriter(&file)
+	z.Write(f.data)
+	z.Close()
+
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%s] /ToUnicode %s >>",
+			f.name, ref(1), ref(4)),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %s /W [%s] /CIDToGIDMap /Identity >>",
+			f.name, ref(2), widths.String()),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%s %s %s %s] /ItalicAngle 0 /Ascent %s /Descent %s /CapHeight %s /StemV 80 /FontFile2 %s >>",
+			f.name, scale(f.bbox[0]), scale(f.bbox[1]), scale(f.bbox[2]), scale(f.bbox[3]), scale(f.ascent), scale(f.descent), scale(f.capHeight), ref(3)),
+		fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", file.Len(), len(f.data), file.String()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(toUnicode), toUnicode),
+	}
+}
+
+// barChart draws values as a bar chart of the given height, labelling
+// some of the bars.
+func (d *pdfDocument) barChart(height float64, values []int, labels []string) {
+	d.space(height + 20)
+	bottom := d.y + 14
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	if len(values) == 0 || max == 0 {
+		return
+	}
+
+	width := (pdfWidth - 2*pdfMargin) / float64(len(values))
+	every := 1 + len(values)/8
+	for i, v := range values {
+		x := pdfMargin + float64(i)*width
+		d.rect(x+1, bottom, width-2, height*float64(v)/float64(max), 0.4)
+		if i%every == 0 {
+			d.text(x+1, bottom-11, 7, false, labels[i])
+		}
+	}
+	d.text(pdfMargin, bottom+height+3, 7, false, tr("max %d", max))
+}
+
+// exportPDF writes the report with the tables and charts of the HTML
+// report, in the font of -pdf-font if given.
+func exportPDF(fileName string, report *Report, users map[string]*User) error {
+	var font *ttfFont
+	if *pdfFont != "" {
+		var err error
+		font, err = loadTTF(*pdfFont)
+		if err != nil {
+			return fmt.Errorf("-pdf-font %s: %v", *pdfFont, err)
+		}
+	}
+
+	d := newPDF(font)
+	d.line(18, true, report.Title)
+	d.line(10, false, tr("%s to %s", report.From, report.To))
+
+	d.space(10)
+	d.line(13, true, tr("Weekly posts"))
+	weekly := make([]int, len(report.Weeks))
+	labels := make([]string, len(report.Weeks))
+	for i, week := range report.Weeks {
+		weekly[i] = week.Posts
+		labels[i] = week.Start
+	}
+	d.barChart(150, weekly, labels)
+
+	columns := []float64{pdfMargin, 250, 300, 400, 450}
+	d.space(10)
+	d.line(13, true, tr("Top channels"))
+	d.row(columns, true, tr("Channel"), tr("Posts"), tr("Received reactions"), tr("Posters"), tr("Weekly posts"))
+	for _, c := range report.Channels {
+		d.row(columns, false, "#"+channelLabel(c.Name), strconv.Itoa(c.Posts), strconv.Itoa(c.Reactions), strconv.Itoa(c.Posters))
+		d.sparkline(columns[4], pdfWidth-pdfMargin-columns[4], c.Weekly)
+	}
+
+	d.space(10)
+	d.line(13, true, tr("Top users"))
+	d.row(columns, true, tr("User"), tr("Posts"), tr("Received reactions"), tr("Channels"))
+	for _, u := range report.Users {
+		d.row(columns, false, displayName(users, u.UserID), strconv.Itoa(u.Posts), strconv.Itoa(u.Reactions), strconv.Itoa(u.Channels))
+	}
+
+	if len(report.Emoji) > 0 {
+		d.space(10)
+		d.line(13, true, tr("Top reactions"))
+		d.row(columns, true, tr("Emoji"), tr("Reactions"), tr("Messages"))
+		for _, e := range report.Emoji {
+			// Emoji fonts are not embedded, so emoji keep their names
+			d.row(columns, false, ":"+strings.Trim(e.Name, ":")+":", strconv.Itoa(e.Reactions), strconv.Itoa(e.Messages))
+		}
+	}
+
+	first, _ := time.Parse("2006-01-02", report.From)
+	last, _ := time.Parse("2006-01-02", report.To)
+	d.space(10)
+	d.line(13, true, tr("Channel activity"))
+	for _, c := range report.Channels {
+		d.line(10, true, "#"+channelLabel(c.Name))
+		d.calendar(c.Daily, first, last)
+	}
+	d.space(10)
+	d.line(13, true, tr("User activity"))
+	for _, u := range report.Users {
+		d.line(10, true, displayName(users, u.UserID))
+		d.calendar(u.Daily, first, last)
+	}
+
+	d.space(10)
+	d.line(13, true, tr("Weekly totals"))
+	d.row(columns, true, tr("Week of"), tr("Posts"), tr("Received reactions"), tr("Posters"))
+	for _, week := range report.Weeks {
+		d.row(columns, false, week.Start, strconv.Itoa(week.Posts), strconv.Itoa(week.Reactions), strconv.Itoa(week.Posters))
+	}
+
+	data, err := d.bytes()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
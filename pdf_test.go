@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestPDFLatin1 checks that the built-in font writes Latin-1 and
+// refuses other text instead of writing "?".
+func TestPDFLatin1(t *testing.T) {
+	d := newPDF(nil)
+	d.line(10, false, "Zoë (café)")
+	data, err := d.bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`(Zo\353 \(caf\351\))`)) {
+		t.Errorf("Latin-1 text not escaped in WinAnsiEncoding")
+	}
+
+	for _, s := range []string{"山田", "Алексей", "ok 👍"} {
+		d := newPDF(nil)
+		d.line(10, false, s)
+		_, err := d.bytes()
+		if err == nil {
+			t.Errorf("%q written without a font covering it", s)
+		}
+	}
+}
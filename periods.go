@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// namedPeriod is a period of -periods, from Start to End included, as
+// 2006-01-02 days.
+type namedPeriod struct {
+	Name  string
+	Start string
+	End   string
+}
+
+// loadPeriods reads a CSV file with a header row naming its name, start
+// and end columns, dates being written as 2006-01-02. Periods are
+// returned by start and may not overlap.
+func loadPeriods(fileName string) ([]namedPeriod, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, csvError(fileName, err)
+	}
+	if len(records) == 0 {
+		return nil, &ParseError{File: fileName, Cause: errors.New("empty file")}
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"name", "start", "end"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.New(fileName + ": missing " + name + " column")
+		}
+	}
+	field := func(record []string, name string) string {
+		i := columns[name]
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var periods []namedPeriod
+	names := make(map[string]bool)
+	for n, record := range records[1:] {
+		p := namedPeriod{field(record, "name"), field(record, "start"), field(record, "end")}
+		_, startErr := time.Parse("2006-01-02", p.Start)
+		_, endErr := time.Parse("2006-01-02", p.End)
+		switch {
+		case p.Name == "":
+			err = errors.New("missing name")
+		case names[p.Name]:
+			err = errors.New("period " + p.Name + " defined twice")
+		case startErr != nil || endErr != nil:
+			err = errors.New("invalid dates of " + p.Name + ", expected YYYY-MM-DD")
+		case p.End < p.Start:
+			err = errors.New(p.Name + " ends before it starts")
+		}
+		if err != nil {
+			return nil, &ParseError{File: fileName, Line: n + 2, Cause: err}
+		}
+		names[p.Name] = true
+		periods = append(periods, p)
+	}
+
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Start < periods[j].Start
+	})
+	for i := 1; i < len(periods); i++ {
+		if periods[i].Start <= periods[i-1].End {
+			return nil, &ParseError{File: fileName, Cause: errors.New(periods[i-1].Name + " and " + periods[i].Name + " overlap")}
+		}
+	}
+	return periods, nil
+}
+
+// periodOf returns the name of the period of a day, or "" if none.
+func periodOf(periods []namedPeriod, day string) string {
+	i := sort.Search(len(periods), func(i int) bool {
+		return periods[i].End >= day
+	})
+	if i < len(periods) && periods[i].Start <= day {
+		return periods[i].Name
+	}
+	return ""
+}
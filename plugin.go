@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+	"strings"
+
+	"ssossan/slack_analytics/metric"
+)
+
+// stringList is a flag that may be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// metrics holds the metrics loaded from -plugin files.
+var metrics []metric.Metric
+
+// loadMetric opens a Go plugin and calls its New function.
+func loadMetric(path string) (metric.Metric, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup("New")
+	if err != nil {
+		return nil, err
+	}
+	newMetric, ok := sym.(func() metric.Metric)
+	if !ok {
+		return nil, fmt.Errorf("%s: New must be a func() metric.Metric", path)
+	}
+	return newMetric(), nil
+}
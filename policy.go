@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// privacyPolicy restricts the outputs and columns written, whatever
+// the flags ask for. It is read from the file given to -policy.
+type privacyPolicy struct {
+	// allowOutputs lists the only outputs written, or nil for all.
+	allowOutputs map[string]bool
+	denyOutputs  map[string]bool
+	denyColumns  map[string]bool
+	// denyIndividual lists the columns left out of outputs with rows
+	// per user, see identifyingColumns.
+	denyIndividual map[string]bool
+}
+
+// policy is the loaded -policy, or nil.
+var policy *privacyPolicy
+
+// outputBase is the path of the daily file without .csv, which the
+// other outputs add a suffix to.
+var outputBase string
+
+// identifyingColumns are the columns telling that the rows of an
+// output are about individual users.
+var identifyingColumns = map[string]bool{
+	"name":            true,
+	"display_name":    true,
+	"user_id":         true,
+	"email":           true,
+	"slack_name":      true,
+	"participant_ids": true,
+}
+
+// loadPolicy reads a policy file, written in a subset of YAML: keys
+// holding lists, either inline as [a, b] or as lines starting with -.
+//
+//	allow_outputs: [daily, summary, burnout]
+//	deny_outputs: [sessions]
+//	deny_columns:
+//	  - email
+//	deny_individual_columns: [after_hours_share, weekend_share]
+func loadPolicy(fileName string) (*privacyPolicy, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	p := &privacyPolicy{
+		denyOutputs:    make(map[string]bool),
+		denyColumns:    make(map[string]bool),
+		denyIndividual: make(map[string]bool),
+	}
+	var list map[string]bool
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		invalid := errors.New("invalid policy on line " + strconv.Itoa(n) + ": " + scanner.Text())
+
+		if strings.HasPrefix(line, "-") {
+			if list == nil {
+				return nil, invalid
+			}
+			list[unquote(strings.TrimSpace(line[1:]))] = true
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, invalid
+		}
+		switch strings.TrimSpace(parts[0]) {
+		case "allow_outputs":
+			p.allowOutputs = make(map[string]bool)
+			list = p.allowOutputs
+		case "deny_outputs":
+			list = p.denyOutputs
+		case "deny_columns":
+			list = p.denyColumns
+		case "deny_individual_columns":
+			list = p.denyIndividual
+		default:
+			return nil, errors.New("unknown policy key on line " + strconv.Itoa(n) + ", expected allow_outputs, deny_outputs, deny_columns or deny_individual_columns")
+		}
+
+		value := strings.TrimSpace(parts[1])
+		if value == "" {
+			continue
+		}
+		if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+			return nil, invalid
+		}
+		for _, item := range strings.Split(value[1:len(value)-1], ",") {
+			if item = unquote(strings.TrimSpace(item)); item != "" {
+				list[item] = true
+			}
+		}
+		list = nil
+	}
+	return p, scanner.Err()
+}
+
+// unquote removes the quotes around a YAML scalar.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// outputKind returns the name of an output in policies: daily for the
+// daily file and those of -split-by groups, summary for their
+// summaries, graph for the graphs of -graph-snapshots, else the suffix
+// of fileName after outputBase, such as summary or report.
+func outputKind(fileName string) string {
+	kind := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	if kind == outputBase {
+		return "daily"
+	}
+	kind = strings.TrimPrefix(kind, outputBase+"_")
+	if strings.HasPrefix(kind, "graph_") {
+		// The graphs of -graph-snapshots
+		return "graph"
+	}
+	if *splitBy != "" && strings.HasPrefix(kind, *splitBy+"_") {
+		// The files of a group of -split-by
+		if strings.HasSuffix(kind, "_summary") {
+			return "summary"
+		}
+		return "daily"
+	}
+	return kind
+}
+
+// allowsOutput reports whether the output kind may be written.
+func (p *privacyPolicy) allowsOutput(kind string) bool {
+	if p == nil {
+		return true
+	}
+	if p.allowOutputs != nil && !p.allowOutputs[kind] {
+		return false
+	}
+	return !p.denyOutputs[kind]
+}
+
+// columns returns the indexes of the header columns allowed. Columns
+// match by their schema v1 or v2 name.
+func (p *privacyPolicy) columns(header []string) []int {
+	individual := false
+	for _, name := range header {
+		if identifyingColumns[name] {
+			individual = true
+		}
+	}
+	denied := func(name string) bool {
+		return p.denyColumns[name] || (individual && p.denyIndividual[name])
+	}
+
+	keep := make([]int, 0, len(header))
+	for i, name := range header {
+		if denied(name) || denied(schemaRenames[name]) {
+			continue
+		}
+		keep = append(keep, i)
+	}
+	return keep
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+)
+
+// readBuffers holds the buffers export files are read into. The
+// decoded messages do not refer to the buffer, so it is reused for the
+// next file instead of allocating one per file.
+var readBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// readFile calls fn with the content of filePath, read into a pooled
+// buffer that must not be kept after fn returns.
+func readFile(filePath string, fn func(data []byte) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buf := readBuffers.Get().(*bytes.Buffer)
+	defer readBuffers.Put(buf)
+	buf.Reset()
+	if info, err := file.Stat(); err == nil {
+		buf.Grow(int(info.Size()) + bytes.MinRead)
+	}
+	_, err = buf.ReadFrom(file)
+	if err != nil {
+		return err
+	}
+	return fn(buf.Bytes())
+}
+
+// messageCountHint estimates the number of messages in an export file
+// by counting its "ts" keys, so that the message slice is allocated
+// once instead of grown while decoding. Files and attachments with a
+// ts of their own only make the estimate larger.
+func messageCountHint(data []byte) int {
+	return bytes.Count(data, []byte(`"ts":`))
+}
+
+// statsSlabSize is the number of Stats allocated at once by newStats.
+const statsSlabSize = 256
+
+var (
+	// statsSlab holds the Stats not yet handed out by newStats.
+	statsSlab []Stats
+	// statsSlabMu guards statsSlab with -parallel.
+	statsSlabMu sync.Mutex
+)
+
+// newStats returns a zero Stats. A Stats is created per user, channel
+// and day, so they are allocated in slabs: the garbage collector then
+// tracks one object per slab rather than millions of small ones. Stats
+// live until the end of the run, so no slab is freed early.
+func newStats() *Stats {
+	statsSlabMu.Lock()
+	defer statsSlabMu.Unlock()
+	if len(statsSlab) == 0 {
+		statsSlab = make([]Stats, statsSlabSize)
+	}
+	s := &statsSlab[0]
+	statsSlab = statsSlab[1:]
+	return s
+}
+
+// dayFormatter formats the "2006-01-02" day of times, in the local
+// time zone. The messages of a day file mostly fall on the same day,
+// so the last day is kept rather than formatted again for each one.
+type dayFormatter struct {
+	start int64
+	end   int64
+	key   string
+}
+
+func (f *dayFormatter) format(t time.Time) string {
+	sec := t.Unix()
+	if f.key != "" && sec >= f.start && sec < f.end {
+		return f.key
+	}
+	y, m, d := t.Date()
+	f.start = time.Date(y, m, d, 0, 0, 0, 0, t.Location()).Unix()
+	f.end = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location()).Unix()
+	f.key = t.Format("2006-01-02")
+	return f.key
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The benchmarks come in pairs, the pooled code of the parsing hot
+// path against the plain code it replaced:
+//
+//	go test -run '^$' -bench . -benchmem
+
+// BenchmarkProcessFiles measures the whole hot path over an export of
+// 20 channels of 20 days.
+func BenchmarkProcessFiles(b *testing.B) {
+	dir, users := writeTestExport(b, 20, 20, 100)
+	files, err := channelFiles(Workspace{Path: dir})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		statsByChannel := make(StatsByChannel)
+		for _, f := range files {
+			err := processFile(f, nil, users, statsByChannel, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchmarkFile(b *testing.B) string {
+	dir, _ := writeTestExport(b, 1, 1, 100)
+	return filepath.Join(dir, "channel-0", "2023-01-02.json")
+}
+
+func BenchmarkReadMessagesPooled(b *testing.B) {
+	fileName := benchmarkFile(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := readMessagesFromJSONFile(fileName)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadMessagesPlain(b *testing.B) {
+	fileName := benchmarkFile(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var messages []Message
+		err = json.Unmarshal(data, &messages)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// statsSink keeps the Stats allocated by the benchmarks on the heap.
+var statsSink *Stats
+
+func BenchmarkNewStatsSlab(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		statsSink = newStats()
+	}
+}
+
+func BenchmarkNewStatsPlain(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		statsSink = &Stats{}
+	}
+}
+
+func BenchmarkDayFormatter(b *testing.B) {
+	var days dayFormatter
+	t := time.Date(2023, 1, 2, 9, 0, 0, 0, time.Local)
+	for i := 0; i < b.N; i++ {
+		days.format(t.Add(time.Duration(i%1000) * time.Second))
+	}
+}
+
+func BenchmarkDayFormat(b *testing.B) {
+	t := time.Date(2023, 1, 2, 9, 0, 0, 0, time.Local)
+	for i := 0; i < b.N; i++ {
+		_ = t.Add(time.Duration(i%1000) * time.Second).Format("2006-01-02")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	// slackLink matches the <target|label> markup of mentions, channels
+	// and links in message text.
+	slackLink = regexp.MustCompile(`<([^<>|]*)(?:\|([^<>]*))?>`)
+
+	// redactions are replaced by [redacted] in previews: email
+	// addresses, numbers of 7 digits or more such as phone and account
+	// numbers, and -redact.
+	redactions = []*regexp.Regexp{
+		regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`),
+		regexp.MustCompile(`\+?\d[\d -]{5,}\d`),
+	}
+
+	slackEscapes = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+)
+
+// addRedaction adds the regular expression of -redact to redactions.
+func addRedaction(expr string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return errors.New("invalid -redact: " + err.Error())
+	}
+	redactions = append(redactions, re)
+	return nil
+}
+
+// preview returns text as Slack shows it, with mentions by name and
+// links by label, on one line, redacted and shortened to
+// -preview-length characters.
+func preview(text string, users map[string]*User) string {
+	if *previewLen <= 0 {
+		return ""
+	}
+	text = slackLink.ReplaceAllStringFunc(text, func(link string) string {
+		parts := slackLink.FindStringSubmatch(link)
+		target, label := parts[1], parts[2]
+		switch {
+		case strings.HasPrefix(target, "@"):
+			if label != "" {
+				return "@" + strings.TrimPrefix(label, "@")
+			}
+			return "@" + displayName(users, target[1:])
+		case strings.HasPrefix(target, "#"):
+			if label != "" {
+				return "#" + label
+			}
+			return target
+		case strings.HasPrefix(target, "!"):
+			// <!here>, <!channel> or <!subteam^ID|@team>
+			if label != "" {
+				return label
+			}
+			return "@" + strings.SplitN(target[1:], "^", 2)[0]
+		case label != "":
+			return label
+		}
+		return target
+	})
+	text = slackEscapes.Replace(text)
+	for _, re := range redactions {
+		text = re.ReplaceAllString(text, "[redacted]")
+	}
+
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) > *previewLen {
+		if *previewLen <= 3 {
+			return string(runes[:*previewLen])
+		}
+		return string(runes[:*previewLen-3]) + "..."
+	}
+	return string(runes)
+}
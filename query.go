@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// runQuery implements the query subcommand, which runs a SQL query on
+// CSV files written by earlier runs. Each file is a table named after
+// the file without .csv, e.g. export_summary.
+func runQuery(args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	asCSV := flags.Bool("csv", false, "print the result as CSV instead of a table")
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		fmt.Println(tr("Error: The correct usage is `go run . query [FLAGS] \"SELECT ...\" FILE.csv...`."))
+		return
+	}
+
+	query, err := parseSQL(flags.Arg(0))
+	if err != nil {
+		fmt.Println(tr("Error parsing query:"), err)
+		return
+	}
+
+	var fileName string
+	var names []string
+	for _, f := range flags.Args()[1:] {
+		name := strings.TrimSuffix(filepath.Base(f), ".csv")
+		names = append(names, name)
+		if strings.EqualFold(name, query.table) {
+			fileName = f
+		}
+	}
+	if fileName == "" {
+		fmt.Println(tr("Error: Unknown table %s, expected one of %s.", query.table, strings.Join(names, ", ")))
+		return
+	}
+
+	records, err := readPreviousCSV(fileName)
+	if err == nil && records == nil {
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		fmt.Println(tr("Error loading %s:", fileName), err)
+		return
+	}
+	table, err := newSQLTable(records)
+	if err != nil {
+		fmt.Println(tr("Error loading %s:", fileName), err)
+		return
+	}
+
+	header, rows, err := query.run(table)
+	if err != nil {
+		fmt.Println(tr("Error running query:"), err)
+		return
+	}
+
+	if *asCSV {
+		writer := csv.NewWriter(os.Stdout)
+		writer.Write(header)
+		writer.WriteAll(rows)
+		return
+	}
+	printTable(header, rows)
+}
+
+// printTable prints rows as a table with aligned columns.
+func printTable(header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for i, name := range header {
+		widths[i] = utf8.RuneCountInString(name)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); i < len(widths) && n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	line := func(cells []string) {
+		padded := make([]string, len(cells))
+		for i, cell := range cells {
+			padded[i] = cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+		}
+		fmt.Println(strings.TrimRight(strings.Join(padded, " | "), " "))
+	}
+	line(header)
+	rules := make([]string, len(widths))
+	for i, w := range widths {
+		rules[i] = strings.Repeat("-", w)
+	}
+	fmt.Println(strings.Join(rules, "-+-"))
+	for _, row := range rows {
+		line(row)
+	}
+	fmt.Printf(tr("(%d rows)\n"), len(rows))
+}
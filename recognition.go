@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ssossan/slack_analytics/stats"
+)
+
+// Recognition is the appreciation a user received on their posts
+// during a month, with their most appreciated message.
+type Recognition struct {
+	UserID     string
+	Month      string
+	Score      float64
+	Reactions  int
+	Reactors   stats.UserSet
+	TopChannel string
+	TopTs      string
+	TopText    string
+	TopScore   float64
+}
+
+// parseEmojiWeights parses a comma-separated list of emoji=weight
+// pairs such as ":raised_hands:=2,tada=1.5".
+func parseEmojiWeights(list string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	if list == "" {
+		return weights, nil
+	}
+	for _, pair := range strings.Split(list, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("invalid emoji weight " + pair)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, errors.New("invalid emoji weight " + pair)
+		}
+		weights[strings.Trim(strings.TrimSpace(parts[0]), ":")] = weight
+	}
+	return weights, nil
+}
+
+// recognize scores the reactions each user received per month. Every
+// reacting user adds the weight of the emoji, 1 unless weighted.
+func recognize(messagesByChannel map[string][]Message, weights map[string]float64, users map[string]*User) []*Recognition {
+	byUserMonth := make(map[string]*Recognition)
+	for channelName, messages := range messagesByChannel {
+		for _, message := range messages {
+			if message.BotID != "" || lookupUser(users, message.User) == nil || lowActivity[message.User] {
+				continue
+			}
+			postedAt, err := parseTimestamp(message.Timestamp)
+			if err != nil {
+				continue
+			}
+
+			author := creditedUser(message, users)
+			score := 0.0
+			reactions := 0
+			var reactors stats.UserSet
+			for _, reaction := range message.GivenReactions {
+				weight, ok := weights[reaction.Name]
+				if !ok {
+					weight = 1
+				}
+				n := 0
+				for _, u := range reaction.Users {
+					if *excludeSelf && u == author {
+						continue
+					}
+					n++
+					reactors.Add(stats.UserSymbols.Intern(u))
+				}
+				score += weight * float64(n)
+				reactions += n
+			}
+			if reactions == 0 {
+				continue
+			}
+
+			month := postedAt.Format("2006-01")
+			key := author + "/" + month
+			r, ok := byUserMonth[key]
+			if !ok {
+				r = &Recognition{UserID: author, Month: month}
+				byUserMonth[key] = r
+			}
+			r.Score += score
+			r.Reactions += reactions
+			r.Reactors.AddAll(reactors)
+			if score > r.TopScore || (score == r.TopScore && message.Timestamp < r.TopTs) {
+				r.TopChannel = channelName
+				r.TopTs = message.Timestamp
+				r.TopText = message.Text
+				r.TopScore = score
+			}
+		}
+	}
+
+	result := make([]*Recognition, 0, len(byUserMonth))
+	for _, r := range byUserMonth {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Month != result[j].Month {
+			return result[i].Month < result[j].Month
+		}
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result
+}
+
+func exportRecognitionCSV(fileName string, recognitions []*Recognition, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"month",
+		"rank",
+		"display_name",
+		"name",
+		"appreciation_score",
+		"received_reactions",
+		"distinct_reactors",
+		"top_message_channel",
+		"top_message_ts",
+		"top_message_score",
+		"top_message_text",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	if *includeEmail {
+		header = append(header, "email")
+	}
+	if *userAttrsFile != "" {
+		header = append(header, userAttrsHeader...)
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	rank := 0
+	for i, r := range recognitions {
+		if i == 0 || recognitions[i-1].Month != r.Month {
+			rank = 0
+		}
+		rank++
+
+		var displayName, name, email string
+		var attrs *UserAttrs
+		if u := lookupUser(users, r.UserID); u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+			email = u.Profile.Email
+			attrs = u.Attrs
+		}
+		workspace, channelName := splitChannelKey(r.TopChannel)
+		row := []string{
+			r.Month,
+			strconv.Itoa(rank),
+			displayName,
+			name,
+			formatFloat(r.Score),
+			strconv.Itoa(r.Reactions),
+			strconv.Itoa(r.Reactors.Len()),
+			channelName,
+			r.TopTs,
+			formatFloat(r.TopScore),
+			preview(r.TopText, users),
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		if *includeEmail {
+			row = append(row, email)
+		}
+		if *userAttrsFile != "" {
+			row = append(row, attrsColumns(attrs)...)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
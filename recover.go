@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+var (
+	// mentionNames holds the latest label each user without a display
+	// name was mentioned with, as <@U123|name>, by user ID.
+	mentionNames = make(map[string]mentionName)
+	// mentionNamesMu guards mentionNames with -parallel.
+	mentionNamesMu sync.Mutex
+
+	// recoveredNames holds the names found for deleted users without
+	// a display name, by user ID, with -recover-names.
+	recoveredNames map[string]string
+)
+
+type mentionName struct {
+	name string
+	ts   string
+}
+
+// needsName reports whether u is a deleted user whose display name is
+// gone, so that a name is worth recovering.
+func needsName(u *User) bool {
+	return u != nil && u.Deleted && u.Profile.DisplayName == ""
+}
+
+// noteMentionNames records the labels of the mentions of message for
+// users whose name needs recovering. The latest message wins.
+func noteMentionNames(message Message, users map[string]*User) {
+	scanMentions(message.Text, func(id, label string) {
+		label = strings.TrimPrefix(strings.TrimSpace(label), "@")
+		if label == "" || !needsName(users[id]) {
+			return
+		}
+		mentionNamesMu.Lock()
+		if message.Timestamp > mentionNames[id].ts {
+			mentionNames[id] = mentionName{label, message.Timestamp}
+		}
+		mentionNamesMu.Unlock()
+	})
+}
+
+// loadPriorNames reads the users of earlier exports and returns the
+// display name, or else the real name, of each user, by ID. The first
+// export naming a user wins.
+func loadPriorNames(paths []string) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, path := range paths {
+		workspaces, err := findWorkspaces(longPath(path))
+		if err != nil {
+			return nil, err
+		}
+		for _, ws := range workspaces {
+			users, err := loadUsers(ws.UsersFile)
+			if err != nil {
+				return nil, err
+			}
+			for id, u := range users {
+				if _, ok := names[id]; ok {
+					continue
+				}
+				if name := profileName(u.Profile); name != "" {
+					names[id] = name
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+func profileName(p Profile) string {
+	if p.DisplayName != "" {
+		return p.DisplayName
+	}
+	return p.RealName
+}
+
+// resolveNames returns a name for each deleted user without a display
+// name, from the first source having one: the real name of their
+// profile, the earlier exports, then the labels of their mentions.
+func resolveNames(users map[string]*User, priorNames map[string]string) map[string]string {
+	names := make(map[string]string)
+	for id, u := range users {
+		if !needsName(u) {
+			continue
+		}
+		name := u.Profile.RealName
+		if name == "" {
+			name = priorNames[id]
+		}
+		if name == "" {
+			name = mentionNames[id].name
+		}
+		if name != "" {
+			names[id] = strings.ReplaceAll(name, ",", " ")
+		}
+	}
+	return names
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Report is the overview written by -format: the most active channels
+// and users and the totals of every week.
+type Report struct {
+	Title    string
+	From     string
+	To       string
+	Channels []*ReportChannel
+	Users    []*ReportUser
+	Weeks    []*ReportWeek
+	// Emoji lists the top reaction emoji, when messages were read
+	// for -emoji-leaderboard.
+	Emoji []*ReportEmoji
+}
+
+// ReportChannel totals a channel. Weekly holds its posts per week of
+// the report and Daily those per day.
+type ReportChannel struct {
+	Name      string
+	Posts     int
+	Reactions int
+	Posters   int
+	Weekly    []int
+	Daily     map[string]int
+}
+
+type ReportUser struct {
+	UserID    string
+	Posts     int
+	Reactions int
+	Channels  int
+	Daily     map[string]int
+}
+
+type ReportEmoji struct {
+	Name      string
+	Reactions int
+	Messages  int
+}
+
+type ReportWeek struct {
+	Start     string
+	Posts     int
+	Reactions int
+	Posters   int
+}
+
+// weekStart returns the Monday starting the week of t.
+func weekStart(t time.Time) time.Time {
+	return t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+}
+
+// buildReport builds the report of statsByChannel, keeping the top
+// channels and users by posts.
+func buildReport(title string, statsByChannel StatsByChannel, top int) *Report {
+	report := &Report{Title: title}
+
+	all := make(map[string]*Stats)
+	for _, ud := range statsByChannel {
+		for day := range ud {
+			all[day] = nil
+		}
+	}
+	first, last, ok := dayRange(all)
+	if !ok {
+		return report
+	}
+	report.From = first.Format("2006-01-02")
+	report.To = last.Format("2006-01-02")
+
+	weekIndex := make(map[string]int)
+	for w := weekStart(first); !w.After(last); w = w.AddDate(0, 0, 7) {
+		start := w.Format("2006-01-02")
+		weekIndex[start] = len(report.Weeks)
+		report.Weeks = append(report.Weeks, &ReportWeek{Start: start})
+	}
+	weekPosters := make([]map[string]bool, len(report.Weeks))
+
+	users := make(map[string]*ReportUser)
+	userChannels := make(map[string]map[string]bool)
+	for channelName, ud := range statsByChannel {
+		channel := &ReportChannel{Name: channelName, Weekly: make([]int, len(report.Weeks)), Daily: make(map[string]int)}
+		posters := make(map[string]bool)
+		for day, us := range ud {
+			t, err := time.Parse("2006-01-02", day)
+			if err != nil {
+				continue
+			}
+			i := weekIndex[weekStart(t).Format("2006-01-02")]
+			week := report.Weeks[i]
+			for userID, s := range us {
+				channel.Posts += s.Posts
+				channel.Reactions += s.GivenReactions
+				channel.Weekly[i] += s.Posts
+				channel.Daily[day] += s.Posts
+				week.Posts += s.Posts
+				week.Reactions += s.GivenReactions
+				if s.Posts == 0 {
+					continue
+				}
+				posters[userID] = true
+				if weekPosters[i] == nil {
+					weekPosters[i] = make(map[string]bool)
+				}
+				weekPosters[i][userID] = true
+
+				u, ok := users[userID]
+				if !ok {
+					u = &ReportUser{UserID: userID, Daily: make(map[string]int)}
+					users[userID] = u
+					userChannels[userID] = make(map[string]bool)
+				}
+				u.Posts += s.Posts
+				u.Reactions += s.GivenReactions
+				u.Daily[day] += s.Posts
+				userChannels[userID][channelName] = true
+			}
+		}
+		channel.Posters = len(posters)
+		report.Channels = append(report.Channels, channel)
+	}
+	for i, week := range report.Weeks {
+		week.Posters = len(weekPosters[i])
+	}
+
+	sort.Slice(report.Channels, func(i, j int) bool {
+		if report.Channels[i].Posts != report.Channels[j].Posts {
+			return report.Channels[i].Posts > report.Channels[j].Posts
+		}
+		return report.Channels[i].Name < report.Channels[j].Name
+	})
+	if len(report.Channels) > top {
+		report.Channels = report.Channels[:top]
+	}
+
+	for userID, u := range users {
+		if lowActivity[userID] {
+			continue
+		}
+		u.Channels = len(userChannels[userID])
+		report.Users = append(report.Users, u)
+	}
+	sort.Slice(report.Users, func(i, j int) bool {
+		if report.Users[i].Posts != report.Users[j].Posts {
+			return report.Users[i].Posts > report.Users[j].Posts
+		}
+		return report.Users[i].UserID < report.Users[j].UserID
+	})
+	if len(report.Users) > top {
+		report.Users = report.Users[:top]
+	}
+	return report
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// runReport implements the report subcommand, which writes the report
+// of -format from the daily file of an earlier run instead of parsing
+// the export again.
+func runReport(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	reportFormat := flags.String("format", "markdown", "report format: markdown, html or pdf")
+	output := flags.String("o", "", "output file (default NAME_report.md, NAME_report.html or NAME_report.pdf)")
+	top := flags.Int("top", 10, "number of channels and users listed in the report")
+	title := flags.String("title", "", "report title (default from the file name)")
+	flags.StringVar(pdfFont, "pdf-font", "", "TrueType font embedded in the PDF report for text outside Latin-1, e.g. Japanese names")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println(tr("Error: No daily CSV file specified. The correct usage is `go run . report [FLAGS] NAME.csv`."))
+		return
+	}
+	if *top < 1 {
+		fmt.Println(tr("Error: -top must be at least 1."))
+		return
+	}
+
+	var export func(string, *Report, map[string]*User) error
+	var ext string
+	switch *reportFormat {
+	case "markdown":
+		export, ext = exportMarkdown, ".md"
+	case "html":
+		export, ext = exportHTML, ".html"
+	case "pdf":
+		export, ext = exportPDF, ".pdf"
+	default:
+		fmt.Println(tr("Error: -format must be markdown, html or pdf."))
+		return
+	}
+	if ext == ".pdf" && messageLanguage != "en" && *pdfFont == "" {
+		fmt.Println(tr("Error: the PDF report in this language needs a TrueType font covering it, given with -pdf-font."))
+		return
+	}
+
+	base := strings.TrimSuffix(flags.Arg(0), ".csv")
+	fileName := *output
+	if fileName == "" {
+		fileName = base + "_report" + ext
+	}
+	if *title == "" {
+		*title = tr("Slack activity of %s", filepath.Base(base))
+	}
+
+	statsByChannel, users, err := loadDailyCSV(flags.Arg(0))
+	if err != nil {
+		fmt.Println(tr("Error loading %s:", flags.Arg(0)), err)
+		return
+	}
+
+	err = export(fileName, buildReport(*title, statsByChannel, *top), users)
+	if err != nil {
+		fmt.Println(tr("Error exporting %s:", fileName), err)
+		return
+	}
+	fmt.Println(fileName, tr(" file created successfully."))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"time"
+)
+
+var rollingWindows = []int{7, 28}
+
+// dayTotals sums the Stats of all users of a channel for each day.
+func dayTotals(ud StatsByDay) map[string]*Stats {
+	totals := make(map[string]*Stats)
+	for day, us := range ud {
+		total := &Stats{}
+		for _, s := range us {
+			total.Add(s)
+		}
+		totals[day] = total
+	}
+	return totals
+}
+
+// rollingColumns returns the user and channel rolling averages of
+// posts and received reactions for each of rollingWindows.
+func rollingColumns(ud StatsByDay, totals map[string]*Stats, day string, userID string) []string {
+	userStats := func(d string) *Stats { return ud[d][userID] }
+	channelStats := func(d string) *Stats { return totals[d] }
+	posts := func(s *Stats) int { return s.Posts }
+	reactions := func(s *Stats) int { return s.GivenReactions }
+
+	var columns []string
+	for _, window := range rollingWindows {
+		columns = append(columns,
+			formatFloat(rollingAverage(day, window, userStats, posts)),
+			formatFloat(rollingAverage(day, window, userStats, reactions)),
+			formatFloat(rollingAverage(day, window, channelStats, posts)),
+			formatFloat(rollingAverage(day, window, channelStats, reactions)),
+		)
+	}
+	return columns
+}
+
+// rollingAverage averages value over the window days ending at day.
+// Days without Stats count as zero and holidays are left out.
+func rollingAverage(day string, window int, stats func(string) *Stats, value func(*Stats) int) float64 {
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return 0
+	}
+
+	sum, days := 0, 0
+	for i := 0; i < window; i++ {
+		d := t.AddDate(0, 0, -i).Format("2006-01-02")
+		if isHoliday(d) {
+			continue
+		}
+		days++
+		if s := stats(d); s != nil {
+			sum += value(s)
+		}
+	}
+	if days == 0 {
+		return 0
+	}
+	return float64(sum) / float64(days)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Output schema versions. v1 is the original layout and stays the
+// default; v2 fixes the column names and types of v1.
+const (
+	schemaV1 = "v1"
+	schemaV2 = "v2"
+)
+
+// schemaRenames maps v1 column names to their v2 names.
+var schemaRenames = map[string]string{
+	"received_reations":   "received_reactions",
+	"given_reation_users": "given_reaction_users",
+}
+
+// v2Header returns a v1 header in schema v2: a leading schema_version
+// column and corrected column names.
+func v2Header(header []string) []string {
+	migrated := make([]string, 0, len(header)+1)
+	migrated = append(migrated, "schema_version")
+	for _, name := range header {
+		if renamed, ok := schemaRenames[name]; ok {
+			name = renamed
+		}
+		migrated = append(migrated, name)
+	}
+	return migrated
+}
+
+// v2Row returns a v1 row in schema v2. Rates over zero posts or days,
+// written as 0 in v1, are left empty since they are undefined.
+func v2Row(header, row []string) []string {
+	migrated := make([]string, 0, len(row)+1)
+	migrated = append(migrated, "2")
+	for i, value := range row {
+		if i < len(header) && isRateColumn(header[i]) && value == "0" {
+			value = ""
+		}
+		migrated = append(migrated, value)
+	}
+	return migrated
+}
+
+func isRateColumn(name string) bool {
+	return strings.HasSuffix(name, "_per_post") || strings.HasSuffix(name, "_per_non_holiday_day")
+}
+
+// schemaWriter is a csv.Writer writing records in the schema selected
+// by -schema. The first record written is taken as the header.
+type schemaWriter struct {
+	*csv.Writer
+	header []string
+	// keep holds the columns allowed by the -policy, once the header
+	// is written.
+	keep []int
+}
+
+func newSchemaWriter(w io.Writer) *schemaWriter {
+	return &schemaWriter{Writer: csv.NewWriter(w)}
+}
+
+func (w *schemaWriter) Write(record []string) error {
+	if policy != nil {
+		if w.keep == nil {
+			w.keep = policy.columns(record)
+		}
+		record = selectColumns(record, w.keep)
+	}
+	if *schema != schemaV2 {
+		return w.Writer.Write(record)
+	}
+	if w.header == nil {
+		w.header = record
+		return w.Writer.Write(v2Header(record))
+	}
+	return w.Writer.Write(v2Row(w.header, record))
+}
+
+// runMigrate implements the migrate subcommand, which converts v1 CSV
+// files to schema v2 as NAME_v2.csv next to each file.
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Println(tr("Error: No CSV file specified. The correct usage is `go run . migrate FILE...`."))
+		return
+	}
+
+	for _, fileName := range flags.Args() {
+		outName := strings.TrimSuffix(fileName, ".csv") + "_v2.csv"
+		err := migrateCSV(fileName, outName)
+		if err != nil {
+			fmt.Println(tr("Error migrating %s:", fileName), err)
+			continue
+		}
+		fmt.Println(outName, tr(" file created successfully."))
+	}
+}
+
+func migrateCSV(fileName, outName string) error {
+	in, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	reader := csv.NewReader(in)
+	header, err := reader.Read()
+	if err != nil {
+		return csvError(fileName, err)
+	}
+	if len(header) > 0 && header[0] == "schema_version" {
+		return errors.New("already in schema v2")
+	}
+
+	out, err := os.Create(outName)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer := csv.NewWriter(out)
+	defer writer.Flush()
+
+	err = writer.Write(v2Header(header))
+	if err != nil {
+		return err
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return csvError(fileName, err)
+		}
+		err = writer.Write(v2Row(header, row))
+		if err != nil {
+			return err
+		}
+	}
+}
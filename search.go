@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// searchMatch is a message matching the search term.
+type searchMatch struct {
+	channelName string
+	message     Message
+	day         string
+	week        string
+}
+
+// runSearch implements the search subcommand, which lists the messages
+// of an export containing a term, with the number of matches per week.
+func runSearch(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	term := flags.String("term", "", "text searched for in messages, case-insensitive")
+	channel := flags.String("channel", "", "search only this channel")
+	asCSV := flags.Bool("csv", false, "print the matching messages as CSV instead of tables")
+	flags.IntVar(previewLen, "preview-length", *previewLen, "characters of the message text printed, 0 for none")
+	redact := flags.String("redact", "", "regular expression of text replaced by [redacted] in printed messages, besides email addresses and long numbers")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println(tr("Error: No export folder specified. The correct usage is `go run . search -term TERM PATH`."))
+		return
+	}
+	if *term == "" {
+		fmt.Println(tr("Error: -term is required."))
+		return
+	}
+	if *redact != "" {
+		err := addRedaction(*redact)
+		if err != nil {
+			fmt.Println(tr("Error:"), err)
+			return
+		}
+	}
+
+	workspaces, err := findWorkspaces(longPath(flags.Arg(0)))
+	if err != nil {
+		fmt.Println(tr("Error finding workspaces:"), err)
+		return
+	}
+	users := make(map[string]*User)
+	for _, ws := range workspaces {
+		wsUsers, err := loadUsers(ws.UsersFile)
+		if err != nil {
+			fmt.Println(tr("Error loading users:"), err)
+			return
+		}
+		for id, u := range wsUsers {
+			users[id] = u
+		}
+	}
+
+	matches, err := searchExport(workspaces, strings.ToLower(*term), *channel)
+	if err != nil {
+		fmt.Println(tr("Error searching messages:"), err)
+		return
+	}
+
+	header := []string{"channel_name", "day", "ts", "display_name", "text"}
+	rows := make([][]string, 0, len(matches))
+	weekly := make(map[string]int)
+	for _, m := range matches {
+		rows = append(rows, []string{m.channelName, m.day, m.message.Timestamp, displayName(users, m.message.User), preview(m.message.Text, users)})
+		weekly[m.week]++
+	}
+
+	if *asCSV {
+		writer := csv.NewWriter(os.Stdout)
+		writer.Write(header)
+		writer.WriteAll(rows)
+		return
+	}
+	printTable(header, rows)
+	fmt.Println()
+
+	weeks := make([]string, 0, len(weekly))
+	for week := range weekly {
+		weeks = append(weeks, week)
+	}
+	sort.Strings(weeks)
+	weekRows := make([][]string, len(weeks))
+	for i, week := range weeks {
+		weekRows[i] = []string{week, strconv.Itoa(weekly[week])}
+	}
+	printTable([]string{"week", "messages"}, weekRows)
+}
+
+// searchExport returns the messages of workspaces whose text contains
+// the lowercase term, in the order they were posted. A non-empty
+// channel restricts the search to the channel of that name.
+func searchExport(workspaces []Workspace, term, channel string) ([]searchMatch, error) {
+	var matches []searchMatch
+	for _, ws := range workspaces {
+		files, err := channelFiles(ws)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if channel != "" {
+				if _, name := splitChannelKey(f.channelName); name != channel {
+					continue
+				}
+			}
+			messages, err := readMessagesFromJSONFile(f.path)
+			if err != nil {
+				return nil, err
+			}
+			for _, message := range messages {
+				if !strings.Contains(strings.ToLower(message.Text), term) {
+					continue
+				}
+				postedAt, err := parseTimestamp(message.Timestamp)
+				if err != nil {
+					continue
+				}
+				matches = append(matches, searchMatch{
+					channelName: f.channelName,
+					message:     message,
+					day:         postedAt.Format("2006-01-02"),
+					week:        weekStart(postedAt).Format("2006-01-02"),
+				})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].message.Timestamp < matches[j].message.Timestamp
+	})
+	return matches, nil
+}
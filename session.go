@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Session is a run of messages in one channel where no pause between
+// consecutive messages exceeds the session gap.
+type Session struct {
+	ChannelName  string
+	Start        time.Time
+	End          time.Time
+	StartTs      string
+	EndTs        string
+	Messages     int
+	Participants map[string]bool
+}
+
+func detectSessions(messagesByChannel map[string][]Message, gap time.Duration) []*Session {
+	var result []*Session
+	for channelName, messages := range messagesByChannel {
+		type timedMessage struct {
+			t    time.Time
+			ts   string
+			user string
+		}
+		var timed []timedMessage
+		for _, message := range messages {
+			if message.User == "" || message.Timestamp == "" {
+				continue
+			}
+			t, err := parseTimestamp(message.Timestamp)
+			if err != nil {
+				continue
+			}
+			timed = append(timed, timedMessage{t, message.Timestamp, message.User})
+		}
+		sort.Slice(timed, func(i, j int) bool {
+			if !timed[i].t.Equal(timed[j].t) {
+				return timed[i].t.Before(timed[j].t)
+			}
+			return timed[i].user < timed[j].user
+		})
+
+		var current *Session
+		for _, m := range timed {
+			if current == nil || m.t.Sub(current.End) > gap {
+				current = &Session{
+					ChannelName:  channelName,
+					Start:        m.t,
+					StartTs:      m.ts,
+					Participants: make(map[string]bool),
+				}
+				result = append(result, current)
+			}
+			current.End = m.t
+			current.EndTs = m.ts
+			current.Messages++
+			current.Participants[m.user] = true
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelName != result[j].ChannelName {
+			return result[i].ChannelName < result[j].ChannelName
+		}
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
+
+func exportSessionsCSV(fileName string, sessions []*Session) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"day",
+		"start",
+		"end",
+		"start_ts",
+		"end_ts",
+		"duration_seconds",
+		"messages",
+		"participants",
+		"participant_ids",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sessions {
+		ids := make([]string, 0, len(s.Participants))
+		for id := range s.Participants {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		workspace, channelName := splitChannelKey(s.ChannelName)
+		row := []string{
+			channelName,
+			s.Start.Format("2006-01-02"),
+			s.Start.Format(time.RFC3339),
+			s.End.Format(time.RFC3339),
+			s.StartTs,
+			s.EndTs,
+			strconv.Itoa(int(s.End.Sub(s.Start).Round(time.Second).Seconds())),
+			strconv.Itoa(s.Messages),
+			strconv.Itoa(len(s.Participants)),
+			strings.Join(ids, ";"),
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
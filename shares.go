@@ -0,0 +1,47 @@
+package main
+
+// Attachment is an attachment of a message. Messages shared into a
+// channel carry the original message as an attachment with is_share,
+// naming its author and channel.
+type Attachment struct {
+	AuthorID    string `json:"author_id,omitempty"`
+	ChannelID   string `json:"channel_id,omitempty"`
+	IsShare     bool   `json:"is_share,omitempty"`
+	IsMsgUnfurl bool   `json:"is_msg_unfurl,omitempty"`
+}
+
+// sharedAuthor returns the author of the message shared by message,
+// or "" if it shares none.
+func sharedAuthor(message Message) string {
+	for _, a := range message.Attachments {
+		if a.IsShare && a.AuthorID != "" {
+			return a.AuthorID
+		}
+	}
+	return ""
+}
+
+// quotedAuthor returns the author of the message shared or quoted by
+// message, as a link unfurled into an attachment, or "" if it
+// shares or quotes none.
+func quotedAuthor(message Message) string {
+	for _, a := range message.Attachments {
+		if (a.IsShare || a.IsMsgUnfurl) && a.AuthorID != "" {
+			return a.AuthorID
+		}
+	}
+	return ""
+}
+
+// creditedUser returns the user credited with the reactions to
+// message: with -share-credit author, the author of the message it
+// shares, unless unknown, otherwise its poster.
+func creditedUser(message Message, users map[string]*User) string {
+	if *shareCredit != "author" {
+		return message.User
+	}
+	if author := sharedAuthor(message); author != "" && lookupUser(users, author) != nil {
+		return author
+	}
+	return message.User
+}
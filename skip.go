@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Reasons for records left out of the stats.
+const (
+	skipMissingTimestamp = "missing_ts"
+	skipInvalidTimestamp = "invalid_ts"
+	skipBotMessage       = "bot_message"
+	skipUnknownUser      = "unknown_user"
+	skipUnknownParent    = "unknown_parent_user"
+	skipUnknownReactor   = "unknown_reacting_user"
+)
+
+var (
+	// skipped counts skipped records by reason.
+	skipped = make(map[string]int)
+	// skippedMu guards skipped and the log with -parallel.
+	skippedMu sync.Mutex
+)
+
+// skip records that a message, or the part of it concerning userID,
+// was left out. With -log-skipped it is also logged to stderr.
+func skip(reason, channelName string, message Message, userID string) {
+	skippedMu.Lock()
+	skipped[reason]++
+	if *logSkipped {
+		fmt.Fprintf(os.Stderr, "skipped reason=%s channel=%q ts=%q user=%q\n", reason, channelName, message.Timestamp, userID)
+	}
+	skippedMu.Unlock()
+	telemetry.add("slack_analytics.skipped."+reason, 1)
+}
+
+// printSkipped prints the number of skipped records per reason.
+func printSkipped() {
+	if len(skipped) == 0 {
+		return
+	}
+
+	reasons := make([]string, 0, len(skipped))
+	for reason := range skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	fmt.Println(tr("Skipped records:"))
+	for _, reason := range reasons {
+		fmt.Printf(tr("  %s: %d\n"), reason, skipped[reason])
+	}
+}
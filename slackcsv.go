@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// slackTable is a CSV file exported from the Analytics page of Slack.
+// Column names are normalized to snake case, e.g. "Days active" to
+// days_active and "Last active (UTC)" to last_active_utc.
+type slackTable struct {
+	columns map[string]int
+	rows    [][]string
+}
+
+func loadSlackCSV(fileName string) (*slackTable, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, csvError(fileName, err)
+	}
+	if len(records) == 0 {
+		return nil, &ParseError{File: fileName, Cause: errors.New("empty file")}
+	}
+
+	t := &slackTable{columns: make(map[string]int), rows: records[1:]}
+	for i, name := range records[0] {
+		t.columns[slackColumnName(name)] = i
+	}
+	return t, nil
+}
+
+// slackColumnName normalizes a column name of Slack to snake case. The
+// files start with a byte order mark, dropped from the first name.
+func slackColumnName(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "_")
+}
+
+func (t *slackTable) has(column string) bool {
+	_, ok := t.columns[column]
+	return ok
+}
+
+func (t *slackTable) field(row []string, column string) string {
+	i, ok := t.columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// number returns a count of Slack, written with thousands separators,
+// or empty if the column is missing.
+func (t *slackTable) number(row []string, column string) string {
+	value := strings.ReplaceAll(t.field(row, column), ",", "")
+	if _, err := strconv.Atoi(value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// channelActivity is a channel's activity over all days, compared
+// with the channel analytics of Slack.
+type channelActivity struct {
+	Posts        int
+	Reactions    int
+	Posters      map[string]bool
+	matchedSlack bool
+}
+
+// channelTotals totals the stats of each channel by channel name.
+// Reactions are the reactions added in the channel.
+func channelTotals(statsByChannel StatsByChannel) map[string]*channelActivity {
+	totals := make(map[string]*channelActivity)
+	for key, ud := range statsByChannel {
+		_, channelName := splitChannelKey(key)
+		t, ok := totals[channelName]
+		if !ok {
+			t = &channelActivity{Posters: make(map[string]bool)}
+			totals[channelName] = t
+		}
+		for _, us := range ud {
+			for userID, s := range us {
+				t.Posts += s.Posts
+				t.Reactions += s.ReceivedReactions
+				if s.Posts > 0 {
+					t.Posters[userID] = true
+				}
+			}
+		}
+	}
+	return totals
+}
+
+// matchSlackMember returns the user ID of a row of the member analytics
+// of Slack, matched by user ID, then email, then username, or empty.
+func matchSlackMember(t *slackTable, row []string, users map[string]*User, byEmail, byName map[string]string) string {
+	if id := t.field(row, "user_id"); id != "" && users[id] != nil {
+		return id
+	}
+	if email := strings.ToLower(t.field(row, "email")); email != "" && byEmail[email] != "" {
+		return byEmail[email]
+	}
+	for _, column := range []string{"username", "display_name", "name"} {
+		if name := t.field(row, column); name != "" && byName[name] != "" {
+			return byName[name]
+		}
+	}
+	return ""
+}
+
+// exportSlackMembersCSV writes the member analytics of Slack next to
+// the totals of users computed from the export. Active users missing
+// from the file of Slack are added with its columns empty.
+func exportSlackMembersCSV(fileName string, slack *slackTable, totals map[string]*activityTotals, users map[string]*User) error {
+	byEmail := make(map[string]string)
+	byName := make(map[string]string)
+	for id, u := range users {
+		if u.Profile.Email != "" {
+			byEmail[strings.ToLower(u.Profile.Email)] = id
+		}
+		byName[u.Name] = id
+		if u.Profile.DisplayName != "" {
+			byName[u.Profile.DisplayName] = id
+		}
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	err = writer.Write([]string{
+		"user_id",
+		"display_name",
+		"name",
+		"slack_name",
+		"days_active",
+		"slack_days_active",
+		"posts",
+		"slack_messages_posted",
+		"given_reactions",
+		"slack_reactions_added",
+		"slack_last_active",
+		"slack_account_type",
+	})
+	if err != nil {
+		return err
+	}
+
+	slackName := "name"
+	if !slack.has(slackName) {
+		slackName = "username"
+	}
+	matched := make(map[string]bool)
+	for _, row := range slack.rows {
+		userID := matchSlackMember(slack, row, users, byEmail, byName)
+		if userID != "" {
+			matched[userID] = true
+		}
+		err := writer.Write(slackMemberRow(userID, users, totals, slack, row, slackName))
+		if err != nil {
+			return err
+		}
+	}
+
+	userIDs := make([]string, 0, len(totals))
+	for userID := range totals {
+		if !matched[userID] {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Strings(userIDs)
+	for _, userID := range userIDs {
+		err := writer.Write(slackMemberRow(userID, users, totals, slack, nil, slackName))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slackMemberRow returns the row of a user, with row the row of Slack
+// for them. Either userID or row may be missing.
+func slackMemberRow(userID string, users map[string]*User, totals map[string]*activityTotals, slack *slackTable, row []string, slackName string) []string {
+	var displayName, name, daysActive, posts, reactions string
+	if userID != "" {
+		if u := lookupUser(users, userID); u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+		}
+		t := totals[userID]
+		if t == nil {
+			t = &activityTotals{}
+		}
+		daysActive = strconv.Itoa(t.ActiveDays)
+		posts = strconv.Itoa(t.Posts)
+		reactions = strconv.Itoa(t.GivenReactions)
+	}
+	return []string{
+		userID,
+		displayName,
+		name,
+		slack.field(row, slackName),
+		daysActive,
+		slack.number(row, "days_active"),
+		posts,
+		slack.number(row, "messages_posted"),
+		reactions,
+		slack.number(row, "reactions_added"),
+		firstField(slack, row, "last_active_utc", "last_active"),
+		slack.field(row, "account_type"),
+	}
+}
+
+// exportSlackChannelsCSV writes the channel analytics of Slack next to
+// the totals computed from the export, matching channels by name.
+func exportSlackChannelsCSV(fileName string, slack *slackTable, totals map[string]*channelActivity) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	err = writer.Write([]string{
+		"channel_name",
+		"posts",
+		"slack_messages_posted",
+		"posters",
+		"slack_members_who_posted",
+		"slack_members_who_viewed",
+		"slack_total_membership",
+		"reactions",
+		"slack_reactions_added",
+		"slack_channel_type",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, row := range slack.rows {
+		channelName := strings.TrimPrefix(firstField(slack, row, "name", "channel_name", "channel"), "#")
+		t := totals[channelName]
+		if t != nil {
+			t.matchedSlack = true
+		}
+		err := writer.Write(slackChannelRow(channelName, t, slack, row))
+		if err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(totals))
+	for name, t := range totals {
+		if !t.matchedSlack {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := writer.Write(slackChannelRow(name, totals[name], slack, nil))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slackChannelRow returns the row of the totals t of a channel and the
+// row of Slack for it, either of which may be missing.
+func slackChannelRow(channelName string, t *channelActivity, slack *slackTable, row []string) []string {
+	var posts, posters, reactions string
+	if t != nil {
+		posts = strconv.Itoa(t.Posts)
+		posters = strconv.Itoa(len(t.Posters))
+		reactions = strconv.Itoa(t.Reactions)
+	}
+	return []string{
+		channelName,
+		posts,
+		slack.number(row, "messages_posted"),
+		posters,
+		slack.number(row, "members_who_posted"),
+		slack.number(row, "members_who_viewed"),
+		slack.number(row, "total_membership"),
+		reactions,
+		slack.number(row, "reactions_added"),
+		slack.field(row, "channel_type"),
+	}
+}
+
+// firstField returns the value of the first of columns in the table.
+func firstField(t *slackTable, row []string, columns ...string) string {
+	for _, column := range columns {
+		if t.has(column) {
+			return t.field(row, column)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// snapshotWindow is the period of each graph of -graph-snapshots:
+// week, month, or a number of days from the first day of the export.
+type snapshotWindow struct {
+	unit string
+	days int
+}
+
+// parseSnapshotWindow parses week, month or a number of days.
+func parseSnapshotWindow(value string) (snapshotWindow, error) {
+	if value == "week" || value == "month" {
+		return snapshotWindow{unit: value}, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 {
+		return snapshotWindow{}, errors.New("invalid -graph-snapshots " + value + ", expected week, month or a number of days")
+	}
+	return snapshotWindow{unit: "days", days: days}, nil
+}
+
+// label returns the period of t: its month as 2006-01, or the first
+// day of its week or window of days, which start on first.
+func (w snapshotWindow) label(t, first time.Time) string {
+	switch w.unit {
+	case "week":
+		return weekStart(t).Format("2006-01-02")
+	case "month":
+		return t.Format("2006-01")
+	}
+	y, m, d := t.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	y, m, d = first.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	n := int(day.Sub(start).Hours()/24) / w.days
+	return start.AddDate(0, 0, n*w.days).Format("2006-01-02")
+}
+
+// GraphSnapshot is the interaction graph of a period.
+type GraphSnapshot struct {
+	Period string
+	Graph  *interactionGraph
+	// Posts are the posts of each user during the period.
+	Posts map[string]int
+}
+
+// graphSnapshots returns the interaction graph of every period with
+// interactions, in order.
+func graphSnapshots(interactions []interaction, statsByChannel StatsByChannel, w snapshotWindow) []*GraphSnapshot {
+	first := ""
+	for _, ud := range statsByChannel {
+		for day := range ud {
+			if first == "" || day < first {
+				first = day
+			}
+		}
+	}
+	start, _ := time.ParseInLocation("2006-01-02", first, time.Local)
+	for _, i := range interactions {
+		if start.IsZero() || i.At.Before(start) {
+			start = i.At
+		}
+	}
+
+	byPeriod := make(map[string][]interaction)
+	for _, i := range interactions {
+		period := w.label(i.At, start)
+		byPeriod[period] = append(byPeriod[period], i)
+	}
+	posts := make(map[string]map[string]int)
+	for _, ud := range statsByChannel {
+		for day, us := range ud {
+			t, err := time.ParseInLocation("2006-01-02", day, time.Local)
+			if err != nil {
+				continue
+			}
+			period := w.label(t, start)
+			if byPeriod[period] == nil {
+				continue
+			}
+			if posts[period] == nil {
+				posts[period] = make(map[string]int)
+			}
+			for userID, s := range us {
+				posts[period][userID] += s.Posts
+			}
+		}
+	}
+
+	result := make([]*GraphSnapshot, 0, len(byPeriod))
+	for period, list := range byPeriod {
+		result = append(result, &GraphSnapshot{Period: period, Graph: buildGraph(list), Posts: posts[period]})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Period < result[j].Period
+	})
+	return result
+}
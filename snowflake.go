@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// snowflakeTypes maps Table Schema column types to Snowflake types.
+var snowflakeTypes = map[string]string{
+	typeString:    "VARCHAR",
+	typeInteger:   "INTEGER",
+	typeNumber:    "FLOAT",
+	typeBoolean:   "BOOLEAN",
+	typeDate:      "DATE",
+	typeDatetime:  "TIMESTAMP_TZ",
+	typeYearMonth: "VARCHAR",
+}
+
+// loadSnowflake loads the daily table of write into table, a
+// [DATABASE.][SCHEMA.]TABLE name, with the Snowflake client of
+// -snowflake-cmd: the rows are uploaded to the stage of the table with
+// PUT and copied into it with COPY INTO, replacing the rows of their
+// days. The table is created if missing. The parts of the name are
+// quoted, so they are case-sensitive.
+func loadSnowflake(table string, write func(io.Writer) error) (int, error) {
+	header, rows, err := renderTable(write)
+	if err != nil {
+		return 0, err
+	}
+	day := -1
+	for i, column := range header {
+		if column == "day" {
+			day = i
+		}
+	}
+	if day < 0 {
+		return 0, errors.New("no day column to replace the rows of")
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	dir, err := ioutil.TempDir("", "slack-analytics")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	// A unique name, so that concurrent runs do not load or purge each
+	// other's files in the stage of the table
+	dataFile := filepath.Join(dir, fmt.Sprintf("%s_%d.csv", avroName(filepath.Base(outputBase)), time.Now().UnixNano()))
+	file, err := os.Create(dataFile)
+	if err != nil {
+		return 0, err
+	}
+	err = csv.NewWriter(file).WriteAll(append([][]string{header}, rows...))
+	file.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	first, last := rows[0][day], rows[0][day]
+	for _, row := range rows {
+		if row[day] < first {
+			first = row[day]
+		}
+		if row[day] > last {
+			last = row[day]
+		}
+	}
+	script := filepath.Join(dir, "load.sql")
+	err = ioutil.WriteFile(script, []byte(snowflakeScript(table, dataFile, header, first, last)), 0600)
+	if err != nil {
+		return 0, err
+	}
+
+	args := strings.Fields(*sfCommand)
+	if len(args) == 0 {
+		return 0, errors.New("no Snowflake client given with -snowflake-cmd")
+	}
+	args = append(args, "-f", script)
+	if *sfConnection != "" {
+		args = append(args, "-c", *sfConnection)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", *sfCommand, err)
+	}
+	return len(rows), nil
+}
+
+// snowflakeScript returns the statements loading dataFile into table.
+// Columns are matched by name, so that columns of new flags can be
+// added to an existing table.
+func snowflakeScript(table, dataFile string, header []string, first, last string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = snowflakeIdentifier(part)
+	}
+	table = strings.Join(parts, ".")
+
+	var b strings.Builder
+	columns := make([]string, len(header))
+	for i, column := range header {
+		columns[i] = avroName(column) + " " + snowflakeTypes[columnType(column)]
+	}
+	fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (\n  %s\n) CLUSTER BY (day, channel_name);\n", table, strings.Join(columns, ",\n  "))
+	for _, column := range columns {
+		fmt.Fprintf(&b, "ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s;\n", table, column)
+	}
+
+	// The stage of a table is @%TABLE, after its database and schema
+	parts[len(parts)-1] = "%" + parts[len(parts)-1]
+	stage := "@" + strings.Join(parts, ".")
+	fmt.Fprintf(&b, "PUT 'file://%s' %s AUTO_COMPRESS = TRUE;\n", filepath.ToSlash(dataFile), stage)
+	fmt.Fprintf(&b, "BEGIN;\n")
+	fmt.Fprintf(&b, "DELETE FROM %s WHERE day BETWEEN '%s' AND '%s';\n", table, first, last)
+	fmt.Fprintf(&b, "COPY INTO %s FROM %s FILES = ('%s.gz')\n", table, stage, filepath.Base(dataFile))
+	fmt.Fprintf(&b, "  FILE_FORMAT = (TYPE = CSV PARSE_HEADER = TRUE FIELD_OPTIONALLY_ENCLOSED_BY = '\"' EMPTY_FIELD_AS_NULL = TRUE)\n")
+	fmt.Fprintf(&b, "  MATCH_BY_COLUMN_NAME = CASE_INSENSITIVE ON_ERROR = ABORT_STATEMENT PURGE = TRUE;\n")
+	fmt.Fprintf(&b, "COMMIT;\n")
+	return b.String()
+}
+
+// snowflakeIdentifier quotes name as a Snowflake identifier.
+func snowflakeIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
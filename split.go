@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// splitAttrs are the user attributes -split-by accepts.
+var splitAttrs = []string{"department", "location", "manager"}
+
+// splitMembers holds the users whose rows are written while writing
+// the files of one group of -split-by, or nil for all.
+var splitMembers map[string]bool
+
+// userAttr returns the value of the attribute name of a, empty for a
+// nil UserAttrs.
+func userAttr(a *UserAttrs, name string) string {
+	if a == nil {
+		return ""
+	}
+	switch name {
+	case "department":
+		return a.Department
+	case "location":
+		return a.Location
+	case "manager":
+		return a.Manager
+	}
+	return ""
+}
+
+// splitGroups returns the users of each value of the attribute name,
+// by a file-safe form of the value. Users without it are unassigned.
+func splitGroups(users map[string]*User, name string) map[string]map[string]bool {
+	groups := make(map[string]map[string]bool)
+	for id, u := range users {
+		group := fileSlug(userAttr(u.Attrs, name))
+		if group == "" {
+			group = "unassigned"
+		}
+		if groups[group] == nil {
+			groups[group] = make(map[string]bool)
+		}
+		groups[group][id] = true
+	}
+	return groups
+}
+
+// fileSlug returns value lowercased with runs of other characters than
+// letters and digits replaced by -, so that it fits in a file name
+// and never holds the _ separating the parts of output names.
+func fileSlug(value string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+func sortedGroups(groups map[string]map[string]bool) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
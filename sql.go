@@ -0,0 +1,965 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A small SQL engine over CSV tables for the query subcommand. It
+// supports a subset of SQL: SELECT [DISTINCT] with expressions, aliases
+// and *, FROM one table, WHERE, GROUP BY, HAVING, ORDER BY (also by
+// position) and LIMIT, the aggregates COUNT, SUM, AVG, MIN and MAX
+// (with DISTINCT), arithmetic, comparisons, IN, LIKE, AND, OR and NOT.
+// Values are text, compared and computed as numbers when they parse as
+// numbers; empty cells are NULL. What it cannot run the way SQL would,
+// such as joins or arithmetic on text, is an error rather than a guess.
+
+// sqlValue is a cell or computed value.
+type sqlValue struct {
+	s     string
+	f     float64
+	isNum bool
+}
+
+func sqlText(s string) sqlValue {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return sqlValue{s: s, f: f, isNum: true}
+	}
+	return sqlValue{s: s}
+}
+
+func sqlNumber(f float64) sqlValue {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if f != math.Trunc(f) {
+		s = formatFloat(f)
+	}
+	return sqlValue{s: s, f: f, isNum: true}
+}
+
+func sqlBool(b bool) sqlValue {
+	if b {
+		return sqlNumber(1)
+	}
+	return sqlNumber(0)
+}
+
+func (v sqlValue) truth() bool {
+	if v.isNum {
+		return v.f != 0
+	}
+	return v.s != "" && v.s != "false"
+}
+
+// compare orders a and b, numerically when both are numbers.
+func (a sqlValue) compare(b sqlValue) int {
+	if a.isNum && b.isNum {
+		switch {
+		case a.f < b.f:
+			return -1
+		case a.f > b.f:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a.s, b.s)
+}
+
+type sqlExpr interface{}
+
+type sqlColumn struct {
+	name string
+}
+
+type sqlLiteral struct {
+	v sqlValue
+}
+
+type sqlUnary struct {
+	op string
+	x  sqlExpr
+}
+
+type sqlBinary struct {
+	op   string
+	l, r sqlExpr
+}
+
+// sqlCall is an aggregate: COUNT(*), SUM(x), COUNT(DISTINCT x), ...
+type sqlCall struct {
+	fn       string
+	distinct bool
+	arg      sqlExpr
+}
+
+type sqlSelectItem struct {
+	expr  sqlExpr
+	alias string
+	star  bool
+}
+
+type sqlOrder struct {
+	expr sqlExpr
+	desc bool
+}
+
+type sqlQuery struct {
+	distinct bool
+	items    []sqlSelectItem
+	table    string
+	where    sqlExpr
+	groupBy  []sqlExpr
+	having   sqlExpr
+	orderBy  []sqlOrder
+	limit    int
+}
+
+// sqlTable is a CSV file loaded for a query.
+type sqlTable struct {
+	header  []string
+	columns map[string]int
+	rows    [][]string
+}
+
+func newSQLTable(records [][]string) (*sqlTable, error) {
+	if len(records) == 0 {
+		return nil, errors.New("empty table")
+	}
+	t := &sqlTable{header: records[0], columns: make(map[string]int), rows: records[1:]}
+	for i, name := range t.header {
+		t.columns[strings.ToLower(name)] = i
+	}
+	return t, nil
+}
+
+type sqlToken struct {
+	kind string // ident, number, string, op or end
+	text string
+}
+
+func tokenizeSQL(s string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	r := []rune(s)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(r) && (r[i] == '_' || unicode.IsLetter(r[i]) || unicode.IsDigit(r[i])) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{"ident", string(r[start:i])})
+		case c == '"':
+			end := i + 1
+			for end < len(r) && r[end] != '"' {
+				end++
+			}
+			if end == len(r) {
+				return nil, errors.New("unterminated quoted identifier")
+			}
+			tokens = append(tokens, sqlToken{"ident", string(r[i+1 : end])})
+			i = end + 1
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(r) && unicode.IsDigit(r[i+1])):
+			start := i
+			for i < len(r) && (unicode.IsDigit(r[i]) || r[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, sqlToken{"number", string(r[start:i])})
+		case c == '\'':
+			var b strings.Builder
+			i++
+			for {
+				if i == len(r) {
+					return nil, errors.New("unterminated string")
+				}
+				if r[i] == '\'' {
+					if i+1 < len(r) && r[i+1] == '\'' {
+						b.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteRune(r[i])
+				i++
+			}
+			tokens = append(tokens, sqlToken{"string", b.String()})
+		case c == '.':
+			return nil, errors.New("qualified names such as table.column are not supported, a query reads one table")
+		default:
+			op := string(c)
+			if i+1 < len(r) {
+				switch two := string(r[i : i+2]); two {
+				case "<=", ">=", "!=", "<>":
+					op = two
+				}
+			}
+			if len(op) == 1 && !strings.Contains("<=>,()*+-/", op) {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+			tokens = append(tokens, sqlToken{"op", op})
+			i += len(op)
+		}
+	}
+	return append(tokens, sqlToken{kind: "end"}), nil
+}
+
+type sqlParser struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func (p *sqlParser) peek() sqlToken {
+	return p.tokens[p.pos]
+}
+
+// keyword reports whether the next token is one of the keywords, and
+// consumes it if so.
+func (p *sqlParser) keyword(words ...string) bool {
+	t := p.peek()
+	if t.kind != "ident" {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *sqlParser) op(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != "op" {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *sqlParser) expect(op string) error {
+	if _, ok := p.op(op); !ok {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *sqlParser) unexpected() error {
+	t := p.peek()
+	if t.kind == "end" {
+		return errors.New("unexpected end of query")
+	}
+	return fmt.Errorf("unexpected %q", t.text)
+}
+
+var sqlReserved = map[string]bool{
+	"select": true, "from": true, "where": true, "group": true, "by": true, "having": true,
+	"order": true, "limit": true, "as": true, "and": true, "or": true, "not": true,
+	"like": true, "asc": true, "desc": true, "distinct": true, "in": true,
+	"join": true, "on": true,
+}
+
+func parseSQL(s string) (*sqlQuery, error) {
+	tokens, err := tokenizeSQL(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &sqlParser{tokens: tokens}
+	q := &sqlQuery{limit: -1}
+
+	if !p.keyword("select") {
+		return nil, errors.New("query must start with SELECT")
+	}
+	q.distinct = p.keyword("distinct")
+	for {
+		if _, ok := p.op("*"); ok {
+			q.items = append(q.items, sqlSelectItem{star: true})
+		} else {
+			e, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			item := sqlSelectItem{expr: e}
+			if p.keyword("as") || (p.peek().kind == "ident" && !sqlReserved[strings.ToLower(p.peek().text)]) {
+				if p.peek().kind != "ident" {
+					return nil, p.unexpected()
+				}
+				item.alias = p.peek().text
+				p.pos++
+			}
+			q.items = append(q.items, item)
+		}
+		if _, ok := p.op(","); !ok {
+			break
+		}
+	}
+
+	if !p.keyword("from") {
+		return nil, errors.New("missing FROM")
+	}
+	if p.peek().kind != "ident" {
+		return nil, p.unexpected()
+	}
+	q.table = p.peek().text
+	p.pos++
+	if _, ok := p.op(","); ok || p.keyword("join", "inner", "left", "right", "full", "cross", "natural") {
+		return nil, errors.New("joins are not supported, a query reads one table")
+	}
+
+	if p.keyword("where") {
+		if q.where, err = p.or(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("group") {
+		if !p.keyword("by") {
+			return nil, errors.New("missing BY after GROUP")
+		}
+		for {
+			e, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			q.groupBy = append(q.groupBy, e)
+			if _, ok := p.op(","); !ok {
+				break
+			}
+		}
+	}
+	if p.keyword("having") {
+		if q.having, err = p.or(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("order") {
+		if !p.keyword("by") {
+			return nil, errors.New("missing BY after ORDER")
+		}
+		for {
+			e, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			order := sqlOrder{expr: e}
+			if p.keyword("desc") {
+				order.desc = true
+			} else {
+				p.keyword("asc")
+			}
+			q.orderBy = append(q.orderBy, order)
+			if _, ok := p.op(","); !ok {
+				break
+			}
+		}
+	}
+	if p.keyword("limit") {
+		t := p.peek()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != "number" || err != nil {
+			return nil, errors.New("LIMIT needs a number")
+		}
+		q.limit = n
+		p.pos++
+	}
+	if p.peek().kind != "end" {
+		return nil, p.unexpected()
+	}
+
+	for i, e := range q.groupBy {
+		if q.groupBy[i], err = q.position(e, "GROUP BY"); err != nil {
+			return nil, err
+		}
+		if hasAggregate(q.groupBy[i]) {
+			return nil, errors.New("GROUP BY cannot use an aggregate")
+		}
+	}
+	for i, order := range q.orderBy {
+		if q.orderBy[i].expr, err = q.position(order.expr, "ORDER BY"); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// position returns the expression of the result column numbered by e,
+// as in ORDER BY 2, or e itself if it is not a number.
+func (q *sqlQuery) position(e sqlExpr, clause string) (sqlExpr, error) {
+	literal, ok := e.(sqlLiteral)
+	if !ok || !literal.v.isNum {
+		return e, nil
+	}
+	for _, item := range q.items {
+		if item.star {
+			return nil, fmt.Errorf("%s %s would count the columns of *, name the column instead", clause, literal.v.s)
+		}
+	}
+	n := int(literal.v.f)
+	if float64(n) != literal.v.f || n < 1 || n > len(q.items) {
+		return nil, fmt.Errorf("%s %s is not the position of a result column", clause, literal.v.s)
+	}
+	return q.items[n-1].expr, nil
+}
+
+func (p *sqlParser) or() (sqlExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = sqlBinary{"or", l, r}
+	}
+	return l, nil
+}
+
+func (p *sqlParser) and() (sqlExpr, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = sqlBinary{"and", l, r}
+	}
+	return l, nil
+}
+
+func (p *sqlParser) not() (sqlExpr, error) {
+	if p.keyword("not") {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return sqlUnary{"not", x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *sqlParser) comparison() (sqlExpr, error) {
+	l, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if op, ok := p.op("=", "!=", "<>", "<", "<=", ">", ">="); ok {
+		r, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		return sqlBinary{op, l, r}, nil
+	}
+	negate := p.keyword("not")
+	if p.keyword("in") {
+		// x IN (a, b) is x = a OR x = b
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var e sqlExpr
+		for {
+			if p.keyword("select") {
+				return nil, errors.New("subqueries are not supported")
+			}
+			r, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			if e == nil {
+				e = sqlBinary{"=", l, r}
+			} else {
+				e = sqlBinary{"or", e, sqlBinary{"=", l, r}}
+			}
+			if _, ok := p.op(","); !ok {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if negate {
+			e = sqlUnary{"not", e}
+		}
+		return e, nil
+	}
+	if p.keyword("like") {
+		r, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		var e sqlExpr = sqlBinary{"like", l, r}
+		if negate {
+			e = sqlUnary{"not", e}
+		}
+		return e, nil
+	}
+	if negate {
+		return nil, errors.New("NOT must be followed by LIKE or IN here")
+	}
+	return l, nil
+}
+
+func (p *sqlParser) sum() (sqlExpr, error) {
+	l, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.op("+", "-")
+		if !ok {
+			return l, nil
+		}
+		r, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		l = sqlBinary{op, l, r}
+	}
+}
+
+func (p *sqlParser) product() (sqlExpr, error) {
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.op("*", "/")
+		if !ok {
+			return l, nil
+		}
+		r, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		l = sqlBinary{op, l, r}
+	}
+}
+
+func (p *sqlParser) operand() (sqlExpr, error) {
+	t := p.peek()
+	switch t.kind {
+	case "number":
+		p.pos++
+		return sqlLiteral{sqlText(t.text)}, nil
+	case "string":
+		p.pos++
+		return sqlLiteral{sqlValue{s: t.text}}, nil
+	case "op":
+		if _, ok := p.op("-"); ok {
+			x, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			return sqlUnary{"-", x}, nil
+		}
+		if _, ok := p.op("("); ok {
+			if p.keyword("select") {
+				return nil, errors.New("subqueries are not supported")
+			}
+			e, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+	case "ident":
+		if sqlReserved[strings.ToLower(t.text)] {
+			break
+		}
+		p.pos++
+		fn := strings.ToLower(t.text)
+		if _, ok := p.op("("); !ok {
+			return sqlColumn{strings.ToLower(t.text)}, nil
+		}
+		switch fn {
+		case "count", "sum", "avg", "min", "max":
+		default:
+			return nil, errors.New("unknown function " + t.text)
+		}
+		call := sqlCall{fn: fn}
+		if _, ok := p.op("*"); ok && fn == "count" {
+			return call, p.expect(")")
+		}
+		call.distinct = p.keyword("distinct")
+		arg, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		call.arg = arg
+		return call, p.expect(")")
+	}
+	return nil, p.unexpected()
+}
+
+// hasAggregate reports whether e contains an aggregate call.
+func hasAggregate(e sqlExpr) bool {
+	switch e := e.(type) {
+	case sqlCall:
+		return true
+	case sqlUnary:
+		return hasAggregate(e.x)
+	case sqlBinary:
+		return hasAggregate(e.l) || hasAggregate(e.r)
+	}
+	return false
+}
+
+// eval evaluates e over a group of rows. Columns take the value of the
+// first row; aggregates run over all rows.
+func (t *sqlTable) eval(e sqlExpr, group [][]string) (sqlValue, error) {
+	switch e := e.(type) {
+	case sqlLiteral:
+		return e.v, nil
+	case sqlColumn:
+		i, ok := t.columns[e.name]
+		if !ok {
+			return sqlValue{}, errors.New("unknown column " + e.name)
+		}
+		if len(group) == 0 || i >= len(group[0]) {
+			return sqlValue{}, nil
+		}
+		return sqlText(group[0][i]), nil
+	case sqlUnary:
+		x, err := t.eval(e.x, group)
+		if err != nil {
+			return x, err
+		}
+		if e.op == "-" {
+			if x.s == "" {
+				return x, nil
+			}
+			if !x.isNum {
+				return sqlValue{}, fmt.Errorf("cannot negate %q, which is not a number", x.s)
+			}
+			return sqlNumber(-x.f), nil
+		}
+		return sqlBool(!x.truth()), nil
+	case sqlBinary:
+		l, err := t.eval(e.l, group)
+		if err != nil {
+			return l, err
+		}
+		r, err := t.eval(e.r, group)
+		if err != nil {
+			return r, err
+		}
+		switch e.op {
+		case "and":
+			return sqlBool(l.truth() && r.truth()), nil
+		case "or":
+			return sqlBool(l.truth() || r.truth()), nil
+		case "=":
+			return sqlBool(l.compare(r) == 0), nil
+		case "!=", "<>":
+			return sqlBool(l.compare(r) != 0), nil
+		case "<":
+			return sqlBool(l.compare(r) < 0), nil
+		case "<=":
+			return sqlBool(l.compare(r) <= 0), nil
+		case ">":
+			return sqlBool(l.compare(r) > 0), nil
+		case ">=":
+			return sqlBool(l.compare(r) >= 0), nil
+		case "like":
+			return sqlBool(likePattern(r.s).MatchString(l.s)), nil
+		}
+
+		// Arithmetic on NULL is NULL
+		if l.s == "" || r.s == "" {
+			return sqlValue{}, nil
+		}
+		for _, v := range []sqlValue{l, r} {
+			if !v.isNum {
+				return sqlValue{}, fmt.Errorf("cannot compute %s on %q, which is not a number", e.op, v.s)
+			}
+		}
+		switch e.op {
+		case "+":
+			return sqlNumber(l.f + r.f), nil
+		case "-":
+			return sqlNumber(l.f - r.f), nil
+		case "*":
+			return sqlNumber(l.f * r.f), nil
+		case "/":
+			if r.f == 0 {
+				return sqlValue{}, errors.New("division by zero")
+			}
+			return sqlNumber(l.f / r.f), nil
+		}
+	case sqlCall:
+		return t.aggregate(e, group)
+	}
+	return sqlValue{}, errors.New("invalid expression")
+}
+
+func (t *sqlTable) aggregate(call sqlCall, group [][]string) (sqlValue, error) {
+	if call.arg == nil {
+		return sqlNumber(float64(len(group))), nil
+	}
+
+	var values []sqlValue
+	seen := make(map[string]bool)
+	for _, row := range group {
+		v, err := t.eval(call.arg, [][]string{row})
+		if err != nil {
+			return v, err
+		}
+		if v.s == "" {
+			continue
+		}
+		if call.distinct {
+			if seen[v.s] {
+				continue
+			}
+			seen[v.s] = true
+		}
+		values = append(values, v)
+	}
+
+	switch call.fn {
+	case "count":
+		return sqlNumber(float64(len(values))), nil
+	case "sum", "avg":
+		if len(values) == 0 {
+			return sqlValue{}, nil
+		}
+		sum := 0.0
+		for _, v := range values {
+			if !v.isNum {
+				return sqlValue{}, fmt.Errorf("cannot compute %s of %q, which is not a number", strings.ToUpper(call.fn), v.s)
+			}
+			sum += v.f
+		}
+		if call.fn == "sum" {
+			return sqlNumber(sum), nil
+		}
+		return sqlNumber(sum / float64(len(values))), nil
+	}
+
+	if len(values) == 0 {
+		return sqlValue{}, nil
+	}
+	best := values[0]
+	for _, v := range values[1:] {
+		c := v.compare(best)
+		if (call.fn == "min" && c < 0) || (call.fn == "max" && c > 0) {
+			best = v
+		}
+	}
+	return best, nil
+}
+
+// likePattern converts a LIKE pattern, with % and _ wildcards, to a
+// case-insensitive regular expression.
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// run executes q on t, returning the header and rows of the result.
+func (q *sqlQuery) run(t *sqlTable) ([]string, [][]string, error) {
+	var rows [][]string
+	for _, row := range t.rows {
+		if q.where != nil {
+			v, err := t.eval(q.where, [][]string{row})
+			if err != nil {
+				return nil, nil, err
+			}
+			if !v.truth() {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	aggregated := len(q.groupBy) > 0
+	for _, item := range q.items {
+		if item.star && aggregated {
+			return nil, nil, errors.New("SELECT * cannot be grouped")
+		}
+		aggregated = aggregated || (!item.star && hasAggregate(item.expr))
+	}
+
+	var groups [][][]string
+	if aggregated {
+		index := make(map[string]int)
+		for _, row := range rows {
+			parts := make([]string, len(q.groupBy))
+			for i, e := range q.groupBy {
+				v, err := t.eval(e, [][]string{row})
+				if err != nil {
+					return nil, nil, err
+				}
+				parts[i] = v.s
+			}
+			key := strings.Join(parts, "\x00")
+			i, ok := index[key]
+			if !ok {
+				i = len(groups)
+				index[key] = i
+				groups = append(groups, nil)
+			}
+			groups[i] = append(groups[i], row)
+		}
+		if len(groups) == 0 && len(q.groupBy) == 0 {
+			groups = append(groups, nil)
+		}
+	} else {
+		for _, row := range rows {
+			groups = append(groups, [][]string{row})
+		}
+	}
+
+	var header []string
+	for _, item := range q.items {
+		switch {
+		case item.star:
+			header = append(header, t.header...)
+		case item.alias != "":
+			header = append(header, item.alias)
+		default:
+			header = append(header, sqlExprName(item.expr))
+		}
+	}
+
+	type resultRow struct {
+		cells []string
+		keys  []sqlValue
+	}
+	var result []resultRow
+	distinct := make(map[string]bool)
+	for _, group := range groups {
+		if q.having != nil {
+			v, err := t.eval(q.resolveAliases(q.having), group)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !v.truth() {
+				continue
+			}
+		}
+
+		var cells []string
+		for _, item := range q.items {
+			if item.star {
+				cells = append(cells, group[0]...)
+				continue
+			}
+			v, err := t.eval(item.expr, group)
+			if err != nil {
+				return nil, nil, err
+			}
+			cells = append(cells, v.s)
+		}
+		if q.distinct {
+			key := strings.Join(cells, "\x00")
+			if distinct[key] {
+				continue
+			}
+			distinct[key] = true
+		}
+
+		var keys []sqlValue
+		for _, order := range q.orderBy {
+			v, err := t.eval(q.resolveAliases(order.expr), group)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, v)
+		}
+		result = append(result, resultRow{cells, keys})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		for k, order := range q.orderBy {
+			c := result[i].keys[k].compare(result[j].keys[k])
+			if c != 0 {
+				return (c < 0) != order.desc
+			}
+		}
+		return false
+	})
+	if q.limit >= 0 && len(result) > q.limit {
+		result = result[:q.limit]
+	}
+
+	out := make([][]string, len(result))
+	for i, r := range result {
+		out[i] = r.cells
+	}
+	return header, out, nil
+}
+
+// resolveAliases replaces the aliases of result columns in e, as used
+// in HAVING and ORDER BY, with their expressions.
+func (q *sqlQuery) resolveAliases(e sqlExpr) sqlExpr {
+	switch e := e.(type) {
+	case sqlColumn:
+		for _, item := range q.items {
+			if item.alias != "" && strings.EqualFold(item.alias, e.name) {
+				return item.expr
+			}
+		}
+	case sqlUnary:
+		return sqlUnary{e.op, q.resolveAliases(e.x)}
+	case sqlBinary:
+		return sqlBinary{e.op, q.resolveAliases(e.l), q.resolveAliases(e.r)}
+	}
+	return e
+}
+
+// sqlExprName names a result column without alias.
+func sqlExprName(e sqlExpr) string {
+	switch e := e.(type) {
+	case sqlColumn:
+		return e.name
+	case sqlLiteral:
+		return e.v.s
+	case sqlCall:
+		arg := "*"
+		if e.arg != nil {
+			arg = sqlExprName(e.arg)
+			if e.distinct {
+				arg = "distinct " + arg
+			}
+		}
+		return e.fn + "(" + arg + ")"
+	case sqlUnary:
+		if e.op == "-" {
+			return "-" + sqlExprName(e.x)
+		}
+		return "not " + sqlExprName(e.x)
+	case sqlBinary:
+		return sqlExprName(e.l) + " " + e.op + " " + sqlExprName(e.r)
+	}
+	return "?"
+}
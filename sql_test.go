@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSQLQuery(t *testing.T) {
+	table, err := newSQLTable([][]string{
+		{"channel_name", "name", "posts", "words"},
+		{"general", "alice", "3", "30"},
+		{"general", "bob", "1", ""},
+		{"random", "alice", "2", "8"},
+		{"random", "carol", "0", "0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query  string
+		header []string
+		rows   [][]string
+	}{
+		{
+			"SELECT name, posts FROM t ORDER BY 2 DESC LIMIT 2",
+			[]string{"name", "posts"},
+			[][]string{{"alice", "3"}, {"alice", "2"}},
+		},
+		{
+			"SELECT DISTINCT name FROM t ORDER BY name",
+			[]string{"name"},
+			[][]string{{"alice"}, {"bob"}, {"carol"}},
+		},
+		{
+			"SELECT channel_name, SUM(posts) AS posts FROM t GROUP BY 1 ORDER BY posts DESC",
+			[]string{"channel_name", "posts"},
+			[][]string{{"general", "4"}, {"random", "2"}},
+		},
+		{
+			"SELECT name FROM t WHERE name IN ('bob', 'carol') AND channel_name NOT IN ('general')",
+			[]string{"name"},
+			[][]string{{"carol"}},
+		},
+		{
+			// Empty cells are NULL
+			"SELECT AVG(words), COUNT(words), words + 1 FROM t WHERE channel_name = 'general' GROUP BY channel_name",
+			[]string{"avg(words)", "count(words)", "words + 1"},
+			[][]string{{"30", "1", "31"}},
+		},
+		{
+			"SELECT SUM(posts) FROM t WHERE posts > 10",
+			[]string{"sum(posts)"},
+			[][]string{{""}},
+		},
+	}
+	for _, tt := range tests {
+		q, err := parseSQL(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		header, rows, err := q.run(table)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(header, tt.header) || !reflect.DeepEqual(rows, tt.rows) {
+			t.Errorf("%s = %q %q, want %q %q", tt.query, header, rows, tt.header, tt.rows)
+		}
+	}
+}
+
+// TestSQLErrors checks that queries the engine cannot run as SQL would
+// fail instead of returning a different result.
+func TestSQLErrors(t *testing.T) {
+	table, err := newSQLTable([][]string{
+		{"name", "posts"},
+		{"alice", "3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		"SELECT t.name FROM t",
+		"SELECT * FROM t JOIN u ON t.name = u.name",
+		"SELECT * FROM t, u",
+		"SELECT name FROM t WHERE name IN (SELECT name FROM u)",
+		"SELECT name FROM t ORDER BY 3",
+		"SELECT * FROM t ORDER BY 1",
+		"SELECT COUNT(*) FROM t GROUP BY 1",
+		"SELECT posts / 0 FROM t",
+		"SELECT SUM(name) FROM t",
+		"SELECT AVG(name) FROM t",
+		"SELECT name * 2 FROM t",
+		"SELECT -name FROM t",
+		"SELECT LENGTH(name) FROM t",
+	} {
+		q, err := parseSQL(query)
+		if err == nil {
+			_, _, err = q.run(table)
+		}
+		if err == nil {
+			t.Errorf("%s: no error", query)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package stats
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// v1Columns maps the misspelled column names of schema v1 to their
+// names in schema v2.
+var v1Columns = map[string]string{
+	"received_reations":   "received_reactions",
+	"given_reation_users": "given_reaction_users",
+}
+
+// ReadDaily reads a daily CSV file written by slack_analytics, of
+// either schema version, back into stats. Channels are keyed by name,
+// or by workspace/name when the file has a workspace column. Users are
+// keyed by the user_id column, which files written with -merge have,
+// and by name otherwise. The file only holds the sizes of the distinct
+// user sets, so the sets are left empty, and columns left out of the
+// file read as zero.
+func ReadDaily(r io.Reader) (ByChannel, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		if renamed, ok := v1Columns[name]; ok {
+			name = renamed
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"name", "day", "channel_name"} {
+		if _, ok := columns[name]; !ok {
+			return nil, &ParseError{Line: 1, Cause: errors.New("missing column " + name)}
+		}
+	}
+	userColumn := "name"
+	if _, ok := columns["user_id"]; ok {
+		userColumn = "user_id"
+	}
+
+	statsByChannel := make(ByChannel)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return statsByChannel, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		value := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+		number := func(name string) int {
+			n, _ := strconv.Atoi(value(name))
+			return n
+		}
+
+		key := value("channel_name")
+		if workspace := value("workspace"); workspace != "" {
+			key = workspace + "/" + key
+		}
+		ud, ok := statsByChannel[key]
+		if !ok {
+			ud = make(ByDay)
+			statsByChannel[key] = ud
+		}
+		us, ok := ud[value("day")]
+		if !ok {
+			us = make(ByUser)
+			ud[value("day")] = us
+		}
+		restricted, _ := strconv.ParseBool(value("is_restricted"))
+		deleted, _ := strconv.ParseBool(value("deleted"))
+		// The reaction columns are named from the side of the reactor,
+		// unlike the fields
+		us[value(userColumn)] = &Stats{
+			UserID:            value(userColumn),
+			Name:              value("name"),
+			DisplayName:       value("display_name"),
+			Email:             value("email"),
+			Posts:             number("posts"),
+			GivenReactions:    number("received_reactions"),
+			ReceivedReactions: number("given_reactions"),
+			ReceivedReplies:   number("received_replies"),
+			Mentions:          number("mentions"),
+			ReceivedMentions:  number("received_mentions"),
+			IsRestricted:      restricted,
+			Deleted:           deleted,
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package stats
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadDaily(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		key  string
+	}{
+		{
+			name: "v1",
+			csv: "display_name,name,day,posts,received_reations,channel_name\n" +
+				"Alice,alice,2023-01-01,3,2,general\n",
+			key: "alice",
+		},
+		{
+			name: "v2 with user IDs",
+			csv: "schema_version,display_name,name,day,posts,received_reactions,channel_name,user_id\n" +
+				"2,Alice,alice,2023-01-01,3,2,general,U1\n",
+			key: "U1",
+		},
+	}
+	for _, tt := range tests {
+		statsByChannel, err := ReadDaily(strings.NewReader(tt.csv))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		s := statsByChannel["general"]["2023-01-01"][tt.key]
+		if s == nil || s.Name != "alice" || s.Posts != 3 || s.GivenReactions != 2 {
+			t.Errorf("%s: ReadDaily = %+v", tt.name, s)
+		}
+	}
+
+	_, err := ReadDaily(strings.NewReader("name,posts\nalice,1\n"))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 1 {
+		t.Errorf("file without day and channel_name: error %v, want a ParseError at line 1", err)
+	}
+}
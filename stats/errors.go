@@ -0,0 +1,39 @@
+package stats
+
+import "fmt"
+
+// ParseError is an error in an input file, at Line if it is known.
+// File is empty for errors of a reader, such as those of ReadDaily.
+type ParseError struct {
+	File  string
+	Line  int
+	Cause error
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.File == "":
+		return fmt.Sprintf("line %d: %v", e.Line, e.Cause)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Cause)
+	}
+	return fmt.Sprintf("%s: %v", e.File, e.Cause)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Cause
+}
+
+// ExportError is an error writing an output file.
+type ExportError struct {
+	File  string
+	Cause error
+}
+
+func (e *ExportError) Error() string {
+	return fmt.Sprintf("exporting %s: %v", e.File, e.Cause)
+}
+
+func (e *ExportError) Unwrap() error {
+	return e.Cause
+}
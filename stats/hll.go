@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"math"
+	"math/bits"
+)
+
+// hyperLogLog is a HyperLogLog sketch estimating the number of
+// distinct symbols added to it in 2^precision bytes, with a standard
+// error of 1.04/sqrt(2^precision).
+type hyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+func newHyperLogLog(precision uint8) *hyperLogLog {
+	return &hyperLogLog{precision: precision, registers: make([]uint8, 1<<precision)}
+}
+
+// hashSymbol spreads the bits of id over 64 bits with the finalizer of
+// SplitMix64, since symbols are small consecutive ints.
+func hashSymbol(id Symbol) uint64 {
+	x := uint64(id) + 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// add adds id to the sketch. The first bits of its hash pick a
+// register, which keeps the longest run of leading zeros of the rest.
+func (h *hyperLogLog) add(id Symbol) {
+	x := hashSymbol(id)
+	i := x >> (64 - h.precision)
+	rank := uint8(bits.LeadingZeros64(x<<h.precision|1<<(h.precision-1))) + 1
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+
+// merge adds the members of other, a sketch of the same precision.
+func (h *hyperLogLog) merge(other *hyperLogLog) {
+	for i, rank := range other.registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+}
+
+// count returns the estimated number of distinct members, counting
+// empty registers instead for small sets, where the raw estimate is
+// biased.
+func (h *hyperLogLog) count() int {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, rank := range h.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	var alpha float64
+	switch len(h.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(estimate + 0.5)
+}
@@ -0,0 +1,226 @@
+package stats
+
+import "errors"
+
+// Merge returns the stats of a and b combined, as counted by a run
+// over both exports: the counters of the same channel, day and user
+// are added and their distinct user sets unioned. a and b are left
+// unchanged. It fails if a and b hold sketches of different
+// precisions, as counted with different values of SketchPrecision.
+func Merge(a, b ByChannel) (ByChannel, error) {
+	pa, pb := a.sketchPrecision(), b.sketchPrecision()
+	if pa < 0 || pb < 0 || pa > 0 && pb > 0 && pa != pb {
+		return nil, errors.New("stats: cannot merge sketches of different precisions")
+	}
+	merged := a.Clone()
+	merged.AddAll(b.Clone())
+	return merged, nil
+}
+
+// sketchPrecision returns the precision of the sketches among the
+// distinct user sets of statsByChannel, 0 if there are none, or -1 if
+// they differ.
+func (statsByChannel ByChannel) sketchPrecision() int {
+	precision := 0
+	for _, ud := range statsByChannel {
+		for _, us := range ud {
+			for _, s := range us {
+				for _, set := range []UserSet{s.GivenReactionUser, s.ReceivedReactionUsers, s.MentionedUsers, s.ReceivedMentionUsers} {
+					if set.sketch == nil {
+						continue
+					}
+					p := int(set.sketch.precision)
+					if precision != 0 && p != precision {
+						return -1
+					}
+					precision = p
+				}
+			}
+		}
+	}
+	return precision
+}
+
+// Diff returns the change from a to b: for each channel, day and user
+// of either, the counters of b minus those of a, and the members of
+// the distinct user sets of b missing from a. Unchanged rows are left
+// out. For counters, Merge(a, Diff(a, b)) equals b; so do the sets
+// when b is a later run over more of the same export.
+func Diff(a, b ByChannel) ByChannel {
+	diff := make(ByChannel)
+	add := func(channelName, day string, s *Stats) {
+		if diff[channelName] == nil {
+			diff[channelName] = make(ByDay)
+		}
+		if diff[channelName][day] == nil {
+			diff[channelName][day] = make(ByUser)
+		}
+		diff[channelName][day][s.UserID] = s
+	}
+
+	for channelName, ud := range b {
+		for day, us := range ud {
+			for userID, s := range us {
+				d := s.Clone()
+				if old := a[channelName][day][userID]; old != nil {
+					d.Subtract(old)
+				}
+				if !d.IsZero() {
+					add(channelName, day, d)
+				}
+			}
+		}
+	}
+	for channelName, ud := range a {
+		for day, us := range ud {
+			for userID, s := range us {
+				if b[channelName][day][userID] != nil {
+					continue
+				}
+				d := &Stats{
+					UserID:       s.UserID,
+					Name:         s.Name,
+					DisplayName:  s.DisplayName,
+					Email:        s.Email,
+					Title:        s.Title,
+					StatusText:   s.StatusText,
+					StatusEmoji:  s.StatusEmoji,
+					IsRestricted: s.IsRestricted,
+					Deleted:      s.Deleted,
+					Attrs:        s.Attrs,
+				}
+				d.Subtract(s)
+				add(channelName, day, d)
+			}
+		}
+	}
+	return diff
+}
+
+// Add adds the counters of src to s. Distinct user sets are unioned
+// so they stay distinct across the merged period.
+func (s *Stats) Add(src *Stats) {
+	s.Posts += src.Posts
+	s.Words += src.Words
+	s.SharedPosts += src.SharedPosts
+	s.ReceivedShares += src.ReceivedShares
+	s.SelfReactions += src.SelfReactions
+	s.GivenReactions += src.GivenReactions
+	s.ReceivedReactions += src.ReceivedReactions
+	s.ReceivedReplies += src.ReceivedReplies
+	s.Mentions += src.Mentions
+	s.ReceivedMentions += src.ReceivedMentions
+	if !src.FirstSeen.IsZero() {
+		s.Seen(src.FirstSeen)
+		s.Seen(src.LastSeen)
+	}
+
+	for key, value := range src.Annotations {
+		if s.Annotations == nil {
+			s.Annotations = make(map[string]float64)
+		}
+		s.Annotations[key] += value
+	}
+
+	s.GivenReactionUser.AddAll(src.GivenReactionUser)
+	s.ReceivedReactionUsers.AddAll(src.ReceivedReactionUsers)
+	s.MentionedUsers.AddAll(src.MentionedUsers)
+	s.ReceivedMentionUsers.AddAll(src.ReceivedMentionUsers)
+}
+
+// Subtract subtracts the counters of src from s and removes the
+// members of its distinct user sets. Sets turned into sketches keep
+// their members. First and last seen times are left unchanged.
+func (s *Stats) Subtract(src *Stats) {
+	s.Posts -= src.Posts
+	s.Words -= src.Words
+	s.SharedPosts -= src.SharedPosts
+	s.ReceivedShares -= src.ReceivedShares
+	s.SelfReactions -= src.SelfReactions
+	s.GivenReactions -= src.GivenReactions
+	s.ReceivedReactions -= src.ReceivedReactions
+	s.ReceivedReplies -= src.ReceivedReplies
+	s.Mentions -= src.Mentions
+	s.ReceivedMentions -= src.ReceivedMentions
+	for key, value := range src.Annotations {
+		if s.Annotations == nil {
+			s.Annotations = make(map[string]float64)
+		}
+		s.Annotations[key] -= value
+		if s.Annotations[key] == 0 {
+			delete(s.Annotations, key)
+		}
+	}
+	s.GivenReactionUser.RemoveAll(src.GivenReactionUser)
+	s.ReceivedReactionUsers.RemoveAll(src.ReceivedReactionUsers)
+	s.MentionedUsers.RemoveAll(src.MentionedUsers)
+	s.ReceivedMentionUsers.RemoveAll(src.ReceivedMentionUsers)
+}
+
+// IsZero reports whether s has no counts.
+func (s *Stats) IsZero() bool {
+	return s.Posts == 0 && s.Words == 0 && s.GivenReactions == 0 && s.ReceivedReactions == 0 &&
+		s.ReceivedReplies == 0 && s.Mentions == 0 && s.ReceivedMentions == 0 &&
+		s.SharedPosts == 0 && s.ReceivedShares == 0 && s.SelfReactions == 0 &&
+		len(s.Annotations) == 0 && s.GivenReactionUser.Len() == 0 && s.ReceivedReactionUsers.Len() == 0 &&
+		s.MentionedUsers.Len() == 0 && s.ReceivedMentionUsers.Len() == 0
+}
+
+// Clone returns a copy of s not sharing its sets and annotations.
+func (s *Stats) Clone() *Stats {
+	c := *s
+	c.GivenReactionUser = s.GivenReactionUser.Clone()
+	c.ReceivedReactionUsers = s.ReceivedReactionUsers.Clone()
+	c.MentionedUsers = s.MentionedUsers.Clone()
+	c.ReceivedMentionUsers = s.ReceivedMentionUsers.Clone()
+	if s.Annotations != nil {
+		c.Annotations = make(map[string]float64, len(s.Annotations))
+		for key, value := range s.Annotations {
+			c.Annotations[key] = value
+		}
+	}
+	return &c
+}
+
+// Clone returns a copy of statsByChannel not sharing any Stats.
+func (statsByChannel ByChannel) Clone() ByChannel {
+	clone := make(ByChannel, len(statsByChannel))
+	for channelName, ud := range statsByChannel {
+		clone[channelName] = make(ByDay, len(ud))
+		for day, us := range ud {
+			clone[channelName][day] = make(ByUser, len(us))
+			for userID, s := range us {
+				clone[channelName][day][userID] = s.Clone()
+			}
+		}
+	}
+	return clone
+}
+
+// AddAll adds the stats of src to statsByChannel. Channels, days and
+// users missing from statsByChannel are moved over as they are, so src
+// must not be used afterwards.
+func (statsByChannel ByChannel) AddAll(src ByChannel) {
+	for channelName, ud := range src {
+		dud, ok := statsByChannel[channelName]
+		if !ok {
+			statsByChannel[channelName] = ud
+			continue
+		}
+		for day, us := range ud {
+			dus, ok := dud[day]
+			if !ok {
+				dud[day] = us
+				continue
+			}
+			for userID, s := range us {
+				ds, ok := dus[userID]
+				if !ok {
+					dus[userID] = s
+					continue
+				}
+				ds.Add(s)
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package stats
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// row is a user on a day in a channel, with the users who reacted to
+// their posts.
+type row struct {
+	channel, day, user string
+	posts              int
+	reactors           []string
+}
+
+func byChannel(rows ...row) ByChannel {
+	statsByChannel := make(ByChannel)
+	for _, r := range rows {
+		if statsByChannel[r.channel] == nil {
+			statsByChannel[r.channel] = make(ByDay)
+		}
+		if statsByChannel[r.channel][r.day] == nil {
+			statsByChannel[r.channel][r.day] = make(ByUser)
+		}
+		s := &Stats{UserID: r.user, Posts: r.posts}
+		for _, reactor := range r.reactors {
+			s.GivenReactions++
+			s.GivenReactionUser.Add(UserSymbols.Intern(reactor))
+		}
+		statsByChannel[r.channel][r.day][r.user] = s
+	}
+	return statsByChannel
+}
+
+// format lists the rows of statsByChannel in a comparable form.
+func format(statsByChannel ByChannel) map[string]string {
+	rows := make(map[string]string)
+	for channelName, ud := range statsByChannel {
+		for day, us := range ud {
+			for userID, s := range us {
+				members, _ := s.GivenReactionUser.Members()
+				var reactors []string
+				for _, id := range members {
+					reactors = append(reactors, UserSymbols.Name(id))
+				}
+				key := channelName + " " + day + " " + userID
+				rows[key] = strings.Join([]string{
+					strconv.Itoa(s.Posts),
+					strconv.Itoa(s.GivenReactions),
+					strconv.Itoa(s.GivenReactionUser.Len()),
+					strings.Join(reactors, ","),
+				}, " ")
+			}
+		}
+	}
+	return rows
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b ByChannel
+		want map[string]string
+	}{
+		{
+			name: "disjoint",
+			a:    byChannel(row{"general", "2023-01-01", "U1", 1, []string{"U2"}}),
+			b:    byChannel(row{"random", "2023-01-02", "U1", 2, nil}),
+			want: map[string]string{
+				"general 2023-01-01 U1": "1 1 1 U2",
+				"random 2023-01-02 U1":  "2 0 0 ",
+			},
+		},
+		{
+			name: "same user",
+			a:    byChannel(row{"general", "2023-01-01", "U1", 1, []string{"U2", "U3"}}),
+			b:    byChannel(row{"general", "2023-01-01", "U1", 2, []string{"U3", "U4"}}),
+			want: map[string]string{
+				"general 2023-01-01 U1": "3 4 3 U2,U3,U4",
+			},
+		},
+		{
+			name: "empty",
+			a:    byChannel(),
+			b:    byChannel(row{"general", "2023-01-01", "U1", 1, nil}),
+			want: map[string]string{
+				"general 2023-01-01 U1": "1 0 0 ",
+			},
+		},
+	}
+	for _, tt := range tests {
+		before := format(tt.a)
+		merged, err := Merge(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := format(merged)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Merge = %q, want %q", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(format(tt.a), before) {
+			t.Errorf("%s: Merge changed its argument", tt.name)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b ByChannel
+		want map[string]string
+	}{
+		{
+			name: "unchanged",
+			a:    byChannel(row{"general", "2023-01-01", "U1", 1, []string{"U2"}}),
+			b:    byChannel(row{"general", "2023-01-01", "U1", 1, []string{"U2"}}),
+			want: map[string]string{},
+		},
+		{
+			name: "more",
+			a:    byChannel(row{"general", "2023-01-01", "U1", 1, []string{"U2"}}),
+			b:    byChannel(row{"general", "2023-01-01", "U1", 3, []string{"U2", "U3"}}),
+			want: map[string]string{
+				"general 2023-01-01 U1": "2 1 1 U3",
+			},
+		},
+		{
+			name: "new and gone",
+			a:    byChannel(row{"general", "2023-01-01", "U1", 1, nil}),
+			b:    byChannel(row{"general", "2023-01-02", "U2", 2, nil}),
+			want: map[string]string{
+				"general 2023-01-01 U1": "-1 0 0 ",
+				"general 2023-01-02 U2": "2 0 0 ",
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := format(Diff(tt.a, tt.b))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Diff = %q, want %q", tt.name, got, tt.want)
+		}
+
+		// Merging the diff back gives the counters of b
+		merged, err := Merge(tt.a, Diff(tt.a, tt.b))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for channelName, ud := range tt.b {
+			for day, us := range ud {
+				for userID, s := range us {
+					if got := merged[channelName][day][userID]; got.Posts != s.Posts || got.GivenReactions != s.GivenReactions {
+						t.Errorf("%s: Merge(a, Diff(a, b)) counts %+v, want %+v", tt.name, got, s)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestMergePrecision checks that sets sketched with different
+// precisions are refused instead of merged.
+func TestMergePrecision(t *testing.T) {
+	defer func(precision uint8) { SketchPrecision = precision }(SketchPrecision)
+	reactors := []string{"U1", "U2", "U3", "U4", "U5", "U6", "U7", "U8", "U9"}
+
+	SketchPrecision = 4
+	a := byChannel(row{"general", "2023-01-01", "U1", 1, reactors})
+	SketchPrecision = 5
+	b := byChannel(row{"general", "2023-01-01", "U1", 1, reactors})
+	if _, ok := a["general"]["2023-01-01"]["U1"].GivenReactionUser.Members(); ok {
+		t.Fatal("set not turned into a sketch")
+	}
+
+	_, err := Merge(a, b)
+	if err == nil {
+		t.Error("sketches of different precisions merged")
+	}
+	_, err = Merge(a, a)
+	if err != nil {
+		t.Errorf("sketches of the same precision: %v", err)
+	}
+	_, err = Merge(a, byChannel(row{"general", "2023-01-01", "U2", 1, []string{"U1"}}))
+	if err != nil {
+		t.Errorf("sketch and exact set: %v", err)
+	}
+}
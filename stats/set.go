@@ -0,0 +1,156 @@
+package stats
+
+import (
+	"sort"
+	"sync"
+)
+
+// Symbol is a string interned in a SymbolTable.
+type Symbol int32
+
+// SymbolTable maps strings repeated across the aggregation, such as
+// user IDs, to small ints. Sets of symbols take a fraction of the
+// memory of the strings themselves. Only the distinct user sets use
+// them: ByUser stays keyed by user ID, so that programs can look users
+// up.
+type SymbolTable struct {
+	mu    sync.RWMutex
+	ids   map[string]Symbol
+	names []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{ids: make(map[string]Symbol)}
+}
+
+// UserSymbols interns the user IDs of the members of UserSets. Sets
+// of the same program share it, so they can be merged and compared.
+var UserSymbols = NewSymbolTable()
+
+// Intern returns the symbol of name, adding it on first use.
+func (t *SymbolTable) Intern(name string) Symbol {
+	t.mu.RLock()
+	id, ok := t.ids[name]
+	t.mu.RUnlock()
+	if ok {
+		return id
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id, ok = t.ids[name]
+	if !ok {
+		id = Symbol(len(t.names))
+		t.ids[name] = id
+		t.names = append(t.names, name)
+	}
+	return id
+}
+
+// Name returns the string interned as id.
+func (t *SymbolTable) Name(id Symbol) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.names[id]
+}
+
+// UserSet is a set of interned user IDs kept sorted. The sets of the
+// reactors of a user on a day are small, so a slice is both smaller
+// and faster than a map. With SketchPrecision set, a set outgrowing
+// the size of a sketch turns into one, counting its members
+// approximately.
+type UserSet struct {
+	ids    []Symbol
+	sketch *hyperLogLog
+}
+
+// SketchPrecision is the precision of the sketches of sets, between 4
+// and 16, or 0 to keep every set exact.
+var SketchPrecision uint8
+
+// Add adds id to the set.
+func (s *UserSet) Add(id Symbol) {
+	if s.sketch != nil {
+		s.sketch.add(id)
+		return
+	}
+	i := sort.Search(len(s.ids), func(i int) bool { return s.ids[i] >= id })
+	if i < len(s.ids) && s.ids[i] == id {
+		return
+	}
+	s.ids = append(s.ids, 0)
+	copy(s.ids[i+1:], s.ids[i:])
+	s.ids[i] = id
+	if SketchPrecision > 0 && 4*len(s.ids) > 1<<SketchPrecision {
+		s.toSketch(SketchPrecision)
+	}
+}
+
+// toSketch turns the set into a sketch of precision.
+func (s *UserSet) toSketch(precision uint8) {
+	s.sketch = newHyperLogLog(precision)
+	for _, id := range s.ids {
+		s.sketch.add(id)
+	}
+	s.ids = nil
+}
+
+// Remove removes id from the set. Sketches cannot forget a member, so
+// a set turned into a sketch is left unchanged.
+func (s *UserSet) Remove(id Symbol) {
+	i := sort.Search(len(s.ids), func(i int) bool { return s.ids[i] >= id })
+	if i < len(s.ids) && s.ids[i] == id {
+		s.ids = append(s.ids[:i], s.ids[i+1:]...)
+	}
+}
+
+// AddAll adds the members of other to the set. Sketches can only be
+// added to sets of the same precision, which Merge checks.
+func (s *UserSet) AddAll(other UserSet) {
+	if other.sketch != nil {
+		if s.sketch == nil {
+			s.toSketch(other.sketch.precision)
+		}
+		s.sketch.merge(other.sketch)
+		return
+	}
+	for _, id := range other.ids {
+		s.Add(id)
+	}
+}
+
+// RemoveAll removes the members of other from the set.
+func (s *UserSet) RemoveAll(other UserSet) {
+	for _, id := range other.ids {
+		s.Remove(id)
+	}
+}
+
+// Members returns the members of the set in order, which
+// UserSymbols.Name turns back into user IDs. ok is false once the set
+// is a sketch, which does not keep its members.
+func (s UserSet) Members() (members []Symbol, ok bool) {
+	if s.sketch != nil {
+		return nil, false
+	}
+	return append([]Symbol(nil), s.ids...), true
+}
+
+// Len returns the number of members of the set, estimated once it is
+// a sketch.
+func (s UserSet) Len() int {
+	if s.sketch != nil {
+		return s.sketch.count()
+	}
+	return len(s.ids)
+}
+
+// Clone returns a copy of the set not sharing its members.
+func (s UserSet) Clone() UserSet {
+	c := UserSet{ids: append([]Symbol(nil), s.ids...)}
+	if s.sketch != nil {
+		c.sketch = newHyperLogLog(s.sketch.precision)
+		c.sketch.merge(s.sketch)
+	}
+	return c
+}
@@ -0,0 +1,81 @@
+// Package stats holds the activity counted from a Slack export, per
+// channel, day and user, and the operations combining the results of
+// several runs.
+package stats
+
+import "time"
+
+// Stats is the activity of a user in a channel on a day. Reactions
+// are counted from the side of the post: GivenReactions are the
+// reactions the posts of the user received and ReceivedReactions
+// those the user gave. MentionedUsers are the users the user
+// mentioned and ReceivedMentionUsers those who mentioned the user.
+type Stats struct {
+	UserID                string
+	Name                  string
+	DisplayName           string
+	Email                 string
+	Title                 string
+	StatusText            string
+	StatusEmoji           string
+	Posts                 int
+	Words                 int
+	SharedPosts           int
+	ReceivedShares        int
+	SelfReactions         int
+	GivenReactions        int
+	GivenReactionUser     UserSet
+	ReceivedReactions     int
+	ReceivedReactionUsers UserSet
+	ReceivedReplies       int
+	Mentions              int
+	MentionedUsers        UserSet
+	ReceivedMentions      int
+	ReceivedMentionUsers  UserSet
+	FirstSeen             time.Time
+	LastSeen              time.Time
+	Annotations           map[string]float64
+	IsRestricted          bool
+	Deleted               bool
+	Attrs                 *Attrs
+}
+
+// Attrs are HR attributes joined onto the rows of a user.
+type Attrs struct {
+	Department string
+	Location   string
+	Manager    string
+	StartDate  string
+}
+
+type ByUser map[string]*Stats
+type ByDay map[string]ByUser
+type ByChannel map[string]ByDay
+
+// Seen widens the first and last post times of s to include t.
+func (s *Stats) Seen(t time.Time) {
+	if s.FirstSeen.IsZero() || t.Before(s.FirstSeen) {
+		s.FirstSeen = t
+	}
+	if s.LastSeen.IsZero() || t.After(s.LastSeen) {
+		s.LastSeen = t
+	}
+}
+
+// CountReaction counts a reaction of reactor to a post of author.
+func CountReaction(author, reactor *Stats) {
+	reactor.ReceivedReactions++
+	reactor.ReceivedReactionUsers.Add(UserSymbols.Intern(author.UserID))
+
+	author.GivenReactions++
+	author.GivenReactionUser.Add(UserSymbols.Intern(reactor.UserID))
+}
+
+// CountMention counts a mention of mentioned by author.
+func CountMention(author, mentioned *Stats) {
+	author.Mentions++
+	author.MentionedUsers.Add(UserSymbols.Intern(mentioned.UserID))
+
+	mentioned.ReceivedMentions++
+	mentioned.ReceivedMentionUsers.Add(UserSymbols.Intern(author.UserID))
+}
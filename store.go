@@ -0,0 +1,62 @@
+package main
+
+import "sync"
+
+// processParallel processes files with workers goroutines. All the
+// files of a channel go to the same worker, in order, and each worker
+// fills its own partial stats and messages. The partial results are
+// merged once every worker is done, so the stats are never shared
+// between goroutines and need no locking.
+func processParallel(files []channelFile, parent *span, users map[string]*User, statsByChannel StatsByChannel, messagesByChannel map[string][]Message, workers int) error {
+	var channels [][]channelFile
+	for i, f := range files {
+		if i == 0 || f.channelName != files[i-1].channelName {
+			channels = append(channels, nil)
+		}
+		channels[len(channels)-1] = append(channels[len(channels)-1], f)
+	}
+
+	type partial struct {
+		stats    StatsByChannel
+		messages map[string][]Message
+		err      error
+	}
+	partials := make([]partial, workers)
+	jobs := make(chan []channelFile)
+	var wg sync.WaitGroup
+	for i := range partials {
+		p := &partials[i]
+		p.stats = make(StatsByChannel)
+		p.messages = make(map[string][]Message)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for channel := range jobs {
+				for _, f := range channel {
+					if p.err != nil {
+						break
+					}
+					p.err = processFile(f, parent, users, p.stats, p.messages)
+				}
+			}
+		}()
+	}
+	for _, channel := range channels {
+		jobs <- channel
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, p := range partials {
+		if p.err != nil {
+			return p.err
+		}
+	}
+	for _, p := range partials {
+		statsByChannel.AddAll(p.stats)
+		for channelName, messages := range p.messages {
+			messagesByChannel[channelName] = append(messagesByChannel[channelName], messages...)
+		}
+	}
+	return nil
+}
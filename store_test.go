@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeTestExport writes an export of channels with a few days of
+// messages, replies, mentions and reactions among users, and returns
+// its path with its users.
+func writeTestExport(t testing.TB, channels, days, users int) (string, map[string]*User) {
+	dir := t.TempDir()
+	rnd := rand.New(rand.NewSource(1))
+
+	userMap := make(map[string]*User)
+	var userList []User
+	for i := 0; i < users; i++ {
+		u := User{ID: fmt.Sprintf("U%d", i), Name: fmt.Sprintf("user%d", i)}
+		u.Profile.DisplayName = fmt.Sprintf("User %d", i)
+		userList = append(userList, u)
+		userMap[u.ID] = &userList[len(userList)-1]
+	}
+	writeJSON(t, filepath.Join(dir, "users.json"), userList)
+
+	start := time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC)
+	for c := 0; c < channels; c++ {
+		channelDir := filepath.Join(dir, fmt.Sprintf("channel-%d", c))
+		err := os.Mkdir(channelDir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for d := 0; d < days; d++ {
+			day := start.AddDate(0, 0, d)
+			var messages []Message
+			for m := 0; m < 20; m++ {
+				ts := fmt.Sprintf("%d.%06d", day.Add(time.Duration(m)*time.Minute).Unix(), m)
+				message := Message{
+					User:      fmt.Sprintf("U%d", rnd.Intn(users)),
+					Text:      fmt.Sprintf("message %d for <@U%d>", m, rnd.Intn(users)),
+					Timestamp: ts,
+				}
+				if m > 0 && rnd.Intn(3) == 0 {
+					root := messages[rnd.Intn(len(messages))]
+					message.ThreadTimestamp = root.Timestamp
+					message.ParentUserID = root.User
+				}
+				for r := rnd.Intn(4); r > 0; r-- {
+					message.GivenReactions = append(message.GivenReactions, Reaction{
+						Name:  fmt.Sprintf("emoji%d", rnd.Intn(5)),
+						Users: []string{fmt.Sprintf("U%d", rnd.Intn(users)), fmt.Sprintf("U%d", rnd.Intn(users))},
+						Count: 2,
+					})
+				}
+				messages = append(messages, message)
+			}
+			writeJSON(t, filepath.Join(channelDir, day.Format("2006-01-02")+".json"), messages)
+		}
+	}
+	return dir, userMap
+}
+
+func writeJSON(t testing.TB, fileName string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(fileName, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestProcessParallel checks that -parallel counts the same stats and
+// keeps the same messages as a serial run, whatever the number of
+// workers. Run it with -race to check the workers share nothing.
+func TestProcessParallel(t *testing.T) {
+	defer func(n int) { *threadsTop = n }(*threadsTop)
+	// Keep the messages, so that they are merged too
+	*threadsTop = 1
+
+	dir, users := writeTestExport(t, 12, 5, 30)
+	files, err := channelFiles(Workspace{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial := make(StatsByChannel)
+	serialMessages := make(map[string][]Message)
+	for _, f := range files {
+		err := processFile(f, nil, users, serial, serialMessages)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(serial) != 12 {
+		t.Fatalf("serial run counted %d channels, want 12", len(serial))
+	}
+
+	for _, workers := range []int{2, 3, 8, 20} {
+		parallel := make(StatsByChannel)
+		parallelMessages := make(map[string][]Message)
+		err := processParallel(files, nil, users, parallel, parallelMessages, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(parallel, serial) {
+			t.Errorf("-parallel %d: stats differ from the serial run", workers)
+		}
+		if !reflect.DeepEqual(parallelMessages, serialMessages) {
+			t.Errorf("-parallel %d: messages differ from the serial run", workers)
+		}
+	}
+}
+
+// TestProcessParallelError checks that an error of any worker is
+// returned.
+func TestProcessParallelError(t *testing.T) {
+	dir, users := writeTestExport(t, 4, 2, 5)
+	broken := filepath.Join(dir, "channel-2", "2023-01-02.json")
+	err := ioutil.WriteFile(broken, []byte("[{"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := channelFiles(Workspace{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = processParallel(files, nil, users, make(StatsByChannel), make(map[string][]Message), 3)
+	if err == nil {
+		t.Fatal("processParallel returned no error for an invalid file")
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Summary is a user's Stats in one channel totalled over all days.
+type Summary struct {
+	Stats
+	DaysActive        int
+	HolidayDaysActive int
+	HolidayPosts      int
+}
+
+type SummaryByUser map[string]*Summary
+type SummaryByChannel map[string]SummaryByUser
+
+func summarize(statsByChannel StatsByChannel) SummaryByChannel {
+	summaryByChannel := make(SummaryByChannel)
+	for channelName, ud := range statsByChannel {
+		su, ok := summaryByChannel[channelName]
+		if !ok {
+			su = make(SummaryByUser)
+			summaryByChannel[channelName] = su
+		}
+
+		for day, us := range ud {
+			for userID, s := range us {
+				summary, ok := su[userID]
+				if !ok {
+					summary = newSummary(s)
+					su[userID] = summary
+				}
+				summary.Stats.Add(s)
+				summary.DaysActive++
+				if isHoliday(day) {
+					summary.HolidayDaysActive++
+					summary.HolidayPosts += s.Posts
+				}
+			}
+		}
+	}
+
+	return summaryByChannel
+}
+
+// newSummary returns an empty Summary of the user of s.
+func newSummary(s *Stats) *Summary {
+	return &Summary{Stats: Stats{
+		UserID:       s.UserID,
+		Name:         s.Name,
+		DisplayName:  s.DisplayName,
+		Email:        s.Email,
+		Title:        s.Title,
+		StatusText:   s.StatusText,
+		StatusEmoji:  s.StatusEmoji,
+		IsRestricted: s.IsRestricted,
+		Deleted:      s.Deleted,
+		Attrs:        s.Attrs,
+	}}
+}
+
+func exportSummaryCSV(fileName string, summaryByChannel SummaryByChannel) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return writeSummaryCSV(file, summaryByChannel)
+}
+
+// writeSummaryCSV writes the summary rows of summaryByChannel to w.
+func writeSummaryCSV(w io.Writer, summaryByChannel SummaryByChannel) error {
+	writer := newSchemaWriter(w)
+	defer writer.Flush()
+
+	header := []string{
+		"display_name",
+		"name",
+		"is_restricted",
+		"deleted",
+		"channel_name",
+		"days_active",
+		"posts",
+		"received_reactions",
+		"received_reaction_users",
+		"given_reactions",
+		"given_reaction_users",
+		"received_replies",
+		"received_reactions_per_post",
+		"replies_per_post",
+		"distinct_reactors_per_post",
+		"mentions",
+		"received_mentions",
+		"posts_percentile_workspace",
+		"posts_percentile_channel",
+		"first_seen",
+		"last_seen",
+		"first_seen_overall",
+		"last_seen_overall",
+		"received_reaction_users_overall",
+		"given_reaction_users_overall",
+		"mentioned_users",
+		"received_mention_users",
+		"mentioned_users_overall",
+		"received_mention_users_overall",
+		"row_type",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	if channelCategories != nil {
+		header = append(header, "channel_category")
+	}
+	if *includeEmail {
+		header = append(header, "email")
+	}
+	if *withProfile {
+		header = append(header, "title", "status_text", "status_emoji")
+	}
+	if *recoverName {
+		header = append(header, "recovered_name")
+	}
+	if *wordCounts {
+		header = append(header, "words", "words_per_post")
+	}
+	if *shares {
+		header = append(header, "shared_posts", "original_posts", "received_shares")
+	}
+	if *excludeSelf {
+		header = append(header, "self_reactions")
+	}
+	if *channelShare {
+		header = append(header, "share_of_channel_posts", "share_of_channel_reactions")
+	}
+	if *zScores {
+		header = append(header, "posts_zscore", "received_reactions_zscore", "given_reactions_zscore")
+	}
+	if *centrality {
+		header = append(header, "degree_centrality", "betweenness_centrality")
+	}
+	if *userAttrsFile != "" {
+		header = append(header, userAttrsHeader...)
+	}
+	annotations := sortedAnnotationKeys()
+	header = append(header, annotations...)
+	if scoreFormula != nil {
+		header = append(header, "score")
+	}
+	if *holidaysFile != "" {
+		header = append(header,
+			"holiday_days_active",
+			"holiday_posts",
+			"posts_per_non_holiday_day",
+		)
+	}
+	keep := enabledColumns(header)
+	err := writer.Write(selectColumns(header, keep))
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(summaryByChannel))
+	workspacePosts := make(map[string]map[string]int)
+	overall := make(map[string]*Stats)
+	for key, su := range summaryByChannel {
+		keys = append(keys, key)
+		workspace, _ := splitChannelKey(key)
+		if workspacePosts[workspace] == nil {
+			workspacePosts[workspace] = make(map[string]int)
+		}
+		for userID, s := range su {
+			if s.Posts > 0 {
+				workspacePosts[workspace][userID] += s.Posts
+			}
+			if overall[userID] == nil {
+				overall[userID] = &Stats{}
+			}
+			if !s.FirstSeen.IsZero() {
+				overall[userID].Seen(s.FirstSeen)
+				overall[userID].Seen(s.LastSeen)
+			}
+			overall[userID].GivenReactionUser.AddAll(s.GivenReactionUser)
+			overall[userID].ReceivedReactionUsers.AddAll(s.ReceivedReactionUsers)
+			overall[userID].MentionedUsers.AddAll(s.MentionedUsers)
+			overall[userID].ReceivedMentionUsers.AddAll(s.ReceivedMentionUsers)
+		}
+	}
+	sort.Strings(keys)
+	workspacePercentiles := make(map[string]map[string]float64)
+	for workspace, posts := range workspacePosts {
+		workspacePercentiles[workspace] = percentileRanks(posts)
+	}
+
+	for _, key := range keys {
+		su := summaryByChannel[key]
+		workspace, channelName := splitChannelKey(key)
+		userIDs := make([]string, 0, len(su))
+		channelPosts := make(map[string]int)
+		var total Stats
+		var posts, received, given map[string]int
+		if *zScores {
+			posts, received, given = make(map[string]int), make(map[string]int), make(map[string]int)
+		}
+		for userID, s := range su {
+			total.Posts += s.Posts
+			total.GivenReactions += s.GivenReactions
+			if *zScores {
+				posts[userID] = s.Posts
+				received[userID] = s.GivenReactions
+				given[userID] = s.ReceivedReactions
+			}
+			if !includeRow(&s.Stats) {
+				continue
+			}
+			userIDs = append(userIDs, userID)
+			if s.Posts > 0 {
+				channelPosts[userID] = s.Posts
+			}
+		}
+		sort.Strings(userIDs)
+		channelPercentiles := percentileRanks(channelPosts)
+		var postsZ, receivedZ, givenZ map[string]float64
+		if *zScores {
+			postsZ, receivedZ, givenZ = zScoresOf(posts), zScoresOf(received), zScoresOf(given)
+		}
+
+		for _, userID := range userIDs {
+			s := su[userID]
+			row := []string{
+				s.DisplayName,
+				s.Name,
+				strconv.FormatBool(s.IsRestricted),
+				strconv.FormatBool(s.Deleted),
+				channelName,
+				strconv.Itoa(s.DaysActive),
+				strconv.Itoa(s.Posts),
+				strconv.Itoa(s.GivenReactions),
+				strconv.Itoa(s.GivenReactionUser.Len()),
+				strconv.Itoa(s.ReceivedReactions),
+				strconv.Itoa(s.ReceivedReactionUsers.Len()),
+				strconv.Itoa(s.ReceivedReplies),
+				formatRate(s.GivenReactions, s.Posts),
+				formatRate(s.ReceivedReplies, s.Posts),
+				formatRate(s.GivenReactionUser.Len(), s.Posts),
+				strconv.Itoa(s.Mentions),
+				strconv.Itoa(s.ReceivedMentions),
+				formatPercentile(workspacePercentiles[workspace], userID),
+				formatPercentile(channelPercentiles, userID),
+				formatTime(s.FirstSeen),
+				formatTime(s.LastSeen),
+				formatTime(overall[userID].FirstSeen),
+				formatTime(overall[userID].LastSeen),
+				strconv.Itoa(overall[userID].GivenReactionUser.Len()),
+				strconv.Itoa(overall[userID].ReceivedReactionUsers.Len()),
+				strconv.Itoa(s.MentionedUsers.Len()),
+				strconv.Itoa(s.ReceivedMentionUsers.Len()),
+				strconv.Itoa(overall[userID].MentionedUsers.Len()),
+				strconv.Itoa(overall[userID].ReceivedMentionUsers.Len()),
+				rowType(&s.Stats),
+			}
+			if multiWorkspace {
+				row = append(row, workspace)
+			}
+			if channelCategories != nil {
+				row = append(row, channelCategories[key])
+			}
+			if *includeEmail {
+				row = append(row, s.Email)
+			}
+			if *withProfile {
+				row = append(row, s.Title, s.StatusText, s.StatusEmoji)
+			}
+			if *recoverName {
+				row = append(row, recoveredNames[s.UserID])
+			}
+			if *wordCounts {
+				row = append(row, strconv.Itoa(s.Words), formatRate(s.Words, s.Posts))
+			}
+			if *shares {
+				row = append(row, strconv.Itoa(s.SharedPosts), strconv.Itoa(s.Posts-s.SharedPosts), strconv.Itoa(s.ReceivedShares))
+			}
+			if *excludeSelf {
+				row = append(row, strconv.Itoa(s.SelfReactions))
+			}
+			if *channelShare {
+				row = append(row,
+					formatShare(s.Posts, total.Posts),
+					formatShare(s.GivenReactions, total.GivenReactions),
+				)
+			}
+			if *zScores {
+				row = append(row,
+					formatFloat(postsZ[userID]),
+					formatFloat(receivedZ[userID]),
+					formatFloat(givenZ[userID]),
+				)
+			}
+			if *centrality {
+				c := userCentrality[userID]
+				if c == nil {
+					c = &Centrality{}
+				}
+				row = append(row, formatCentrality(c.Degree), formatCentrality(c.Betweenness))
+			}
+			if *userAttrsFile != "" {
+				row = append(row, attrsColumns(s.Attrs)...)
+			}
+			for _, key := range annotations {
+				row = append(row, formatFloat(s.Annotations[key]))
+			}
+			if scoreFormula != nil {
+				row = append(row, formatFloat(scoreFormula.eval(scoreVars(&s.Stats, s.DaysActive))))
+			}
+			if *holidaysFile != "" {
+				row = append(row,
+					strconv.Itoa(s.HolidayDaysActive),
+					strconv.Itoa(s.HolidayPosts),
+					formatRate(s.Posts-s.HolidayPosts, s.DaysActive-s.HolidayDaysActive),
+				)
+			}
+			err := writer.Write(selectColumns(row, keep))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatPercentile formats the rank of key, or empty if it has none.
+func formatPercentile(ranks map[string]float64, key string) string {
+	rank, ok := ranks[key]
+	if !ok {
+		return ""
+	}
+	return formatFloat(rank)
+}
+
+// percentileRanks returns the percentile rank (0-100) of each value:
+// the share of values below it, counting ties as half.
+func percentileRanks(values map[string]int) map[string]float64 {
+	sorted := make([]int, 0, len(values))
+	for _, v := range values {
+		sorted = append(sorted, v)
+	}
+	sort.Ints(sorted)
+
+	ranks := make(map[string]float64)
+	for k, v := range values {
+		below := sort.SearchInts(sorted, v)
+		equal := sort.SearchInts(sorted, v+1) - below
+		ranks[k] = 100 * (float64(below) + 0.5*float64(equal)) / float64(len(sorted))
+	}
+	return ranks
+}
+
+// zScoresOf returns how many standard deviations each value is from
+// the mean of all values, or 0 when they are all equal.
+func zScoresOf(values map[string]int) map[string]float64 {
+	n := float64(len(values))
+	var sum, squares float64
+	for _, v := range values {
+		sum += float64(v)
+	}
+	mean := sum / n
+	for _, v := range values {
+		squares += (float64(v) - mean) * (float64(v) - mean)
+	}
+	std := math.Sqrt(squares / n)
+
+	scores := make(map[string]float64)
+	for k, v := range values {
+		if std > 0 {
+			scores[k] = (float64(v) - mean) / std
+		}
+	}
+	return scores
+}
+
+// formatRate formats n/d with two decimals, or 0 when d is zero.
+func formatRate(n, d int) string {
+	if d == 0 {
+		return "0"
+	}
+	return formatFloat(float64(n) / float64(d))
+}
+
+// formatShare formats n as a percentage of total, or 0 when total is
+// zero.
+func formatShare(n, total int) string {
+	if total == 0 {
+		return "0"
+	}
+	return formatFloat(100 * float64(n) / float64(total))
+}
+
+// formatTime formats t as RFC 3339, or empty for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 2, 64)
+	if s == "-0.00" {
+		// Averages of values summing to zero are not exactly zero
+		return "0.00"
+	}
+	return s
+}
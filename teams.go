@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// teamsAdapter reads Microsoft Teams channel messages saved from the
+// Graph API: a directory of TEAM/CHANNEL folders holding the responses
+// of /teams/{id}/channels/{id}/messages (and of their replies), or
+// arrays of their values. Messages of apps are skipped like Slack bot
+// messages, and deleted and system messages are left out.
+type teamsAdapter struct{}
+
+type teamsIdentity struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type teamsMessage struct {
+	ID              string     `json:"id"`
+	ReplyToID       string     `json:"replyToId"`
+	MessageType     string     `json:"messageType"`
+	CreatedDateTime time.Time  `json:"createdDateTime"`
+	DeletedDateTime *time.Time `json:"deletedDateTime"`
+	From            *struct {
+		User        *teamsIdentity `json:"user"`
+		Application *teamsIdentity `json:"application"`
+	} `json:"from"`
+	Body struct {
+		ContentType string `json:"contentType"`
+		Content     string `json:"content"`
+	} `json:"body"`
+	Reactions []struct {
+		ReactionType string `json:"reactionType"`
+		User         struct {
+			User *teamsIdentity `json:"user"`
+		} `json:"user"`
+	} `json:"reactions"`
+	Replies []teamsMessage `json:"replies"`
+}
+
+func (teamsAdapter) load(path string) (*importedExport, error) {
+	export := &importedExport{
+		path:     path,
+		users:    make(map[string]*User),
+		messages: make(map[string][]Message),
+	}
+	byChannel := make(map[string][]teamsMessage)
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(file) != ".json" {
+			return err
+		}
+		dir := filepath.Dir(file)
+		key := channelKey(filepath.Base(filepath.Dir(dir)), filepath.Base(dir))
+		messages, err := readTeamsMessages(file)
+		if err != nil {
+			return err
+		}
+		byChannel[key] = append(byChannel[key], messages...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for key, messages := range byChannel {
+		export.messages[key] = teamsToMessages(messages, export.users)
+	}
+	return export, nil
+}
+
+// readTeamsMessages reads a Graph API response, or an array of
+// messages, flattening the replies expanded in their root.
+func readTeamsMessages(file string) ([]teamsMessage, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var page struct {
+		Value []teamsMessage `json:"value"`
+	}
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &page.Value)
+	} else {
+		err = json.Unmarshal(data, &page)
+	}
+	if err != nil {
+		return nil, jsonError(file, data, err)
+	}
+
+	var messages []teamsMessage
+	for _, m := range page.Value {
+		messages = append(messages, m)
+		for _, reply := range m.Replies {
+			if reply.ReplyToID == "" {
+				reply.ReplyToID = m.ID
+			}
+			messages = append(messages, reply)
+		}
+	}
+	return messages, nil
+}
+
+// teamsToMessages converts the messages of a channel and adds their
+// authors and reacting users to users.
+func teamsToMessages(messages []teamsMessage, users map[string]*User) []Message {
+	addUser := func(u *teamsIdentity) string {
+		if u == nil || u.ID == "" {
+			return ""
+		}
+		if _, ok := users[u.ID]; !ok {
+			users[u.ID] = &User{ID: u.ID, Name: u.DisplayName, Profile: Profile{DisplayName: u.DisplayName}}
+		}
+		return u.ID
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].CreatedDateTime.Before(messages[j].CreatedDateTime)
+	})
+	seen := make(map[string]bool)
+	roots := make(map[string]int)
+	result := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		if seen[m.ID] || m.DeletedDateTime != nil || (m.MessageType != "" && m.MessageType != "message") {
+			continue
+		}
+		seen[m.ID] = true
+
+		message := Message{Timestamp: slackTimestamp(m.CreatedDateTime), Text: teamsText(m.Body.Content, m.Body.ContentType)}
+		if m.From != nil {
+			if m.From.User != nil {
+				message.User = addUser(m.From.User)
+			} else if m.From.Application != nil {
+				message.BotID = m.From.Application.ID
+			}
+		}
+		if m.ReplyToID != "" {
+			if i, ok := roots[m.ReplyToID]; ok {
+				root := &result[i]
+				root.ThreadTimestamp = root.Timestamp
+				message.ThreadTimestamp = root.Timestamp
+				message.ParentUserID = root.User
+			}
+		}
+
+		byType := make(map[string]int)
+		for _, r := range m.Reactions {
+			userID := addUser(r.User.User)
+			if userID == "" {
+				continue
+			}
+			i, ok := byType[r.ReactionType]
+			if !ok {
+				i = len(message.GivenReactions)
+				byType[r.ReactionType] = i
+				message.GivenReactions = append(message.GivenReactions, Reaction{Name: r.ReactionType})
+			}
+			message.GivenReactions[i].Users = append(message.GivenReactions[i].Users, userID)
+			message.GivenReactions[i].Count++
+		}
+		result = append(result, message)
+		if m.ReplyToID == "" {
+			roots[m.ID] = len(result) - 1
+		}
+	}
+	return result
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// teamsText returns the text of a message body, without the markup of
+// HTML bodies.
+func teamsText(content, contentType string) string {
+	if contentType != "html" {
+		return content
+	}
+	return html.UnescapeString(htmlTag.ReplaceAllString(content, ""))
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// telemetry collects spans and counters of a run and sends them to an
+// OTLP/HTTP collector using the JSON encoding. It is nil when
+// -otlp-endpoint is not set, and all its methods accept a nil receiver.
+var telemetry *telemetryExporter
+
+// rootSpan covers the whole run.
+var rootSpan *span
+
+// telemetryTimeout bounds each request to the collector, so that an
+// unreachable collector delays the end of the run by that much at most.
+const telemetryTimeout = 10 * time.Second
+
+type telemetryExporter struct {
+	endpoint string
+	traceID  string
+	start    time.Time
+	client   *http.Client
+
+	mu       sync.Mutex
+	spans    []*span
+	counters map[string]int64
+}
+
+type span struct {
+	name       string
+	spanID     string
+	parentID   string
+	start      time.Time
+	finish     time.Time
+	attributes map[string]string
+	err        error
+}
+
+func newTelemetry(endpoint string) *telemetryExporter {
+	return &telemetryExporter{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		traceID:  randomID(16),
+		start:    time.Now(),
+		client:   &http.Client{Timeout: telemetryTimeout},
+		counters: make(map[string]int64),
+	}
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startSpan starts a span below parent, or a root span if parent is nil.
+func startSpan(name string, parent *span) *span {
+	if telemetry == nil {
+		return nil
+	}
+	s := &span{
+		name:       name,
+		spanID:     randomID(8),
+		start:      time.Now(),
+		attributes: make(map[string]string),
+	}
+	if parent != nil {
+		s.parentID = parent.spanID
+	}
+	return s
+}
+
+func (s *span) setAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.attributes[key] = value
+}
+
+// end finishes the span, marking it failed if err is not nil.
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+	s.finish = time.Now()
+	s.err = err
+
+	telemetry.mu.Lock()
+	telemetry.spans = append(telemetry.spans, s)
+	telemetry.mu.Unlock()
+}
+
+// add increments the counter name by n.
+func (t *telemetryExporter) add(name string, n int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.counters[name] += n
+	t.mu.Unlock()
+}
+
+// flush sends the collected spans to /v1/traces and the counters to
+// /v1/metrics. OTEL_EXPORTER_OTLP_HEADERS is honoured.
+func (t *telemetryExporter) flush() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	resource := map[string]interface{}{
+		"attributes": []map[string]interface{}{
+			otlpAttribute("service.name", "slack-analytics"),
+			otlpAttribute("service.version", buildVersion()),
+		},
+	}
+	scope := map[string]interface{}{"name": "slack-analytics"}
+
+	var spans []map[string]interface{}
+	for _, s := range t.spans {
+		attributes := []map[string]interface{}{}
+		for key, value := range s.attributes {
+			attributes = append(attributes, otlpAttribute(key, value))
+		}
+		status := map[string]interface{}{"code": 1}
+		if s.err != nil {
+			status = map[string]interface{}{"code": 2, "message": s.err.Error()}
+		}
+		spans = append(spans, map[string]interface{}{
+			"traceId":           t.traceID,
+			"spanId":            s.spanID,
+			"parentSpanId":      s.parentID,
+			"name":              s.name,
+			"kind":              1,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.finish.UnixNano(), 10),
+			"attributes":        attributes,
+			"status":            status,
+		})
+	}
+	err := t.post("/v1/traces", map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource":   resource,
+			"scopeSpans": []map[string]interface{}{{"scope": scope, "spans": spans}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(t.counters))
+	for name := range t.counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	var metrics []map[string]interface{}
+	for _, name := range names {
+		metrics = append(metrics, map[string]interface{}{
+			"name": name,
+			"unit": "1",
+			"sum": map[string]interface{}{
+				"aggregationTemporality": 2,
+				"isMonotonic":            true,
+				"dataPoints": []map[string]interface{}{{
+					"asInt":             strconv.FormatInt(t.counters[name], 10),
+					"startTimeUnixNano": strconv.FormatInt(t.start.UnixNano(), 10),
+					"timeUnixNano":      now,
+				}},
+			},
+		})
+	}
+	return t.post("/v1/metrics", map[string]interface{}{
+		"resourceMetrics": []map[string]interface{}{{
+			"resource":     resource,
+			"scopeMetrics": []map[string]interface{}{{"scope": scope, "metrics": metrics}},
+		}},
+	})
+}
+
+func (t *telemetryExporter) post(path string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		kv := strings.SplitN(header, "=", 2)
+		if len(kv) == 2 {
+			req.Header.Set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", t.endpoint+path, resp.Status)
+	}
+	return nil
+}
+
+func otlpAttribute(key, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   key,
+		"value": map[string]string{"stringValue": value},
+	}
+}
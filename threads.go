@@ -0,0 +1,239 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// threadRoot is a thread root message whose reply_count is checked
+// against the replies found in the export.
+type threadRoot struct {
+	channelName string
+	day         string
+	userID      string
+	replyCount  int
+}
+
+// threadTracker holds the thread roots with replies and the replies
+// found, so that replies missing from partial exports can be credited
+// once all messages are counted.
+type threadTracker struct {
+	// mu guards the maps with -parallel.
+	mu sync.Mutex
+	// roots holds the roots with replies by channel and ts.
+	roots map[string]*threadRoot
+	// replies counts the replies found by channel and thread ts.
+	replies map[string]int
+}
+
+func newThreadTracker() *threadTracker {
+	return &threadTracker{
+		roots:   make(map[string]*threadRoot),
+		replies: make(map[string]int),
+	}
+}
+
+// exportThreads tracks the threads of the export of a run.
+var exportThreads = newThreadTracker()
+
+// track records a root message with replies or a reply.
+func (t *threadTracker) track(channelName, day string, message Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if isReply(message) {
+		t.replies[channelName+"/"+message.ThreadTimestamp]++
+		return
+	}
+	if message.ReplyCount > 0 {
+		t.roots[channelName+"/"+message.Timestamp] = &threadRoot{
+			channelName: channelName,
+			day:         day,
+			userID:      message.User,
+			replyCount:  message.ReplyCount,
+		}
+	}
+}
+
+// creditMissingReplies credits the replies counted by reply_count on a
+// root message but missing from the export to the root author, on the
+// day of the root. It returns the number of replies credited.
+func (t *threadTracker) creditMissingReplies(statsByChannel StatsByChannel, users map[string]*User) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	credited := 0
+	for key, root := range t.roots {
+		missing := root.replyCount - t.replies[key]
+		if missing <= 0 {
+			continue
+		}
+		statsByUser := statsByChannel[root.channelName][root.day]
+		if statsByUser == nil {
+			continue
+		}
+		if stats := statsFor(statsByUser, users, root.userID); stats != nil {
+			stats.ReceivedReplies += missing
+			credited += missing
+		}
+	}
+	return credited
+}
+
+// Thread is a thread of a channel with the engagement with its root.
+type Thread struct {
+	ChannelName  string
+	Timestamp    string
+	PostedAt     time.Time
+	UserID       string
+	Text         string
+	Replies      int
+	Participants map[string]bool
+	Reactions    int
+	LastReply    time.Time
+}
+
+// topThreads returns the threads of each channel with the most
+// replies, keeping at most top per channel. The reply_count and
+// reply_users of a root are used when replies are missing from the
+// export.
+func topThreads(messagesByChannel map[string][]Message, top int) []*Thread {
+	var result []*Thread
+	for channelName, messages := range messagesByChannel {
+		byTs := make(map[string]*Thread)
+		for _, message := range messages {
+			if isReply(message) || message.ReplyCount == 0 {
+				continue
+			}
+			postedAt, err := parseTimestamp(message.Timestamp)
+			if err != nil {
+				continue
+			}
+			t := &Thread{
+				ChannelName:  channelName,
+				Timestamp:    message.Timestamp,
+				PostedAt:     postedAt,
+				UserID:       message.User,
+				Text:         message.Text,
+				Replies:      message.ReplyCount,
+				Participants: make(map[string]bool),
+			}
+			for _, u := range message.ReplyUsers {
+				t.Participants[u] = true
+			}
+			for _, reaction := range message.GivenReactions {
+				t.Reactions += len(reaction.Users)
+			}
+			byTs[message.Timestamp] = t
+		}
+
+		found := make(map[string]int)
+		for _, message := range messages {
+			t := byTs[message.ThreadTimestamp]
+			if t == nil || !isReply(message) {
+				continue
+			}
+			found[message.ThreadTimestamp]++
+			t.Participants[message.User] = true
+			if postedAt, err := parseTimestamp(message.Timestamp); err == nil && postedAt.After(t.LastReply) {
+				t.LastReply = postedAt
+			}
+		}
+
+		threads := make([]*Thread, 0, len(byTs))
+		for ts, t := range byTs {
+			if found[ts] > t.Replies {
+				t.Replies = found[ts]
+			}
+			threads = append(threads, t)
+		}
+		sort.Slice(threads, func(i, j int) bool {
+			if threads[i].Replies != threads[j].Replies {
+				return threads[i].Replies > threads[j].Replies
+			}
+			return threads[i].Timestamp < threads[j].Timestamp
+		})
+		if len(threads) > top {
+			threads = threads[:top]
+		}
+		result = append(result, threads...)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ChannelName < result[j].ChannelName
+	})
+	return result
+}
+
+func exportTopThreadsCSV(fileName string, threads []*Thread, users map[string]*User) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := newSchemaWriter(file)
+	defer writer.Flush()
+
+	header := []string{
+		"channel_name",
+		"rank",
+		"ts",
+		"posted_at",
+		"display_name",
+		"name",
+		"replies",
+		"participants",
+		"reactions",
+		"last_reply_at",
+		"text",
+	}
+	if multiWorkspace {
+		header = append(header, "workspace")
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	rank := 0
+	for i, t := range threads {
+		if i == 0 || t.ChannelName != threads[i-1].ChannelName {
+			rank = 0
+		}
+		rank++
+		var displayName, name string
+		if u := lookupUser(users, t.UserID); u != nil {
+			displayName = strings.ReplaceAll(u.Profile.DisplayName, ",", " ")
+			name = u.Name
+		}
+		var lastReply string
+		if !t.LastReply.IsZero() {
+			lastReply = t.LastReply.Format(time.RFC3339)
+		}
+		workspace, channelName := splitChannelKey(t.ChannelName)
+		row := []string{
+			channelName,
+			strconv.Itoa(rank),
+			t.Timestamp,
+			t.PostedAt.Format(time.RFC3339),
+			displayName,
+			name,
+			strconv.Itoa(t.Replies),
+			strconv.Itoa(len(t.Participants)),
+			strconv.Itoa(t.Reactions),
+			lastReply,
+			preview(t.Text, users),
+		}
+		if multiWorkspace {
+			row = append(row, workspace)
+		}
+		err := writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
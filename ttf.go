@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// ttfFont is a TrueType font read for embedding in PDF reports. Only
+// the tables needed to map characters to glyphs and lay them out are
+// parsed; the file is embedded as it is.
+type ttfFont struct {
+	name       string
+	data       []byte
+	unitsPerEm int
+	bbox       [4]int
+	ascent     int
+	descent    int
+	capHeight  int
+	advances   []int
+	glyphs     map[rune]uint16
+}
+
+var errMalformedFont = errors.New("malformed TrueType font")
+
+func ttfU16(b []byte, i int) int {
+	return int(b[i])<<8 | int(b[i+1])
+}
+
+func ttfI16(b []byte, i int) int {
+	return int(int16(ttfU16(b, i)))
+}
+
+func ttfU32(b []byte, i int) int {
+	return int(uint32(ttfU16(b, i))<<16 | uint32(ttfU16(b, i+2)))
+}
+
+// loadTTF reads the TrueType font fileName.
+func loadTTF(fileName string) (*ttfFont, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	return parseTTF(name, data)
+}
+
+func parseTTF(name string, data []byte) (*ttfFont, error) {
+	if len(data) < 12 {
+		return nil, errMalformedFont
+	}
+	switch string(data[:4]) {
+	case "\x00\x01\x00\x00", "true":
+	case "OTTO":
+		return nil, errors.New("OpenType fonts with CFF outlines are not supported, use a TrueType (.ttf) font")
+	case "ttcf":
+		return nil, errors.New("font collections (.ttc) are not supported, use a single TrueType (.ttf) font")
+	default:
+		return nil, errMalformedFont
+	}
+
+	tables := make(map[string][]byte)
+	numTables := ttfU16(data, 4)
+	if len(data) < 12+16*numTables {
+		return nil, errMalformedFont
+	}
+	for i := 0; i < numTables; i++ {
+		record := data[12+16*i:]
+		offset, length := ttfU32(record, 8), ttfU32(record, 12)
+		if offset+length > len(data) {
+			return nil, errMalformedFont
+		}
+		tables[string(record[:4])] = data[offset : offset+length]
+	}
+	head, hhea, maxp, hmtx, cmap := tables["head"], tables["hhea"], tables["maxp"], tables["hmtx"], tables["cmap"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 || cmap == nil {
+		return nil, errMalformedFont
+	}
+
+	f := &ttfFont{
+		name:       pdfName(name),
+		data:       data,
+		unitsPerEm: ttfU16(head, 18),
+		bbox:       [4]int{ttfI16(head, 36), ttfI16(head, 38), ttfI16(head, 40), ttfI16(head, 42)},
+		ascent:     ttfI16(hhea, 4),
+		descent:    ttfI16(hhea, 6),
+	}
+	if f.unitsPerEm == 0 {
+		return nil, errMalformedFont
+	}
+	f.capHeight = f.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 && ttfU16(os2, 0) >= 2 {
+		f.capHeight = ttfI16(os2, 88)
+	}
+
+	numGlyphs, numMetrics := ttfU16(maxp, 4), ttfU16(hhea, 34)
+	if numMetrics == 0 || len(hmtx) < 4*numMetrics {
+		return nil, errMalformedFont
+	}
+	f.advances = make([]int, numGlyphs)
+	for i := range f.advances {
+		if i < numMetrics {
+			f.advances[i] = ttfU16(hmtx, 4*i)
+		} else {
+			f.advances[i] = f.advances[numMetrics-1]
+		}
+	}
+
+	var err error
+	f.glyphs, err = parseCmap(cmap)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseCmap maps the characters of the Unicode subtable of cmap, of
+// format 12 (all planes) if any, or else of format 4 (the BMP).
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, errMalformedFont
+	}
+	var bmp, full []byte
+	n := ttfU16(cmap, 2)
+	if len(cmap) < 4+8*n {
+		return nil, errMalformedFont
+	}
+	for i := 0; i < n; i++ {
+		platform, encoding, offset := ttfU16(cmap, 4+8*i), ttfU16(cmap, 6+8*i), ttfU32(cmap, 8+8*i)
+		if offset+4 > len(cmap) || !(platform == 0 || platform == 3 && (encoding == 1 || encoding == 10)) {
+			continue
+		}
+		sub := cmap[offset:]
+		switch ttfU16(sub, 0) {
+		case 4:
+			bmp = sub
+		case 12:
+			full = sub
+		}
+	}
+
+	glyphs := make(map[rune]uint16)
+	switch {
+	case full != nil:
+		if len(full) < 16 {
+			return nil, errMalformedFont
+		}
+		groups := ttfU32(full, 12)
+		if len(full) < 16+12*groups {
+			return nil, errMalformedFont
+		}
+		for i := 0; i < groups; i++ {
+			start, end, glyph := ttfU32(full, 16+12*i), ttfU32(full, 20+12*i), ttfU32(full, 24+12*i)
+			for c := start; c <= end && c <= 0x10ffff; c++ {
+				glyphs[rune(c)] = uint16(glyph + c - start)
+			}
+		}
+	case bmp != nil:
+		if len(bmp) < 14 {
+			return nil, errMalformedFont
+		}
+		segCountX2 := ttfU16(bmp, 6)
+		ends, starts, deltas, ranges := 14, 16+segCountX2, 16+2*segCountX2, 16+3*segCountX2
+		if len(bmp) < ranges+segCountX2 {
+			return nil, errMalformedFont
+		}
+		for i := 0; i < segCountX2; i += 2 {
+			end, start := ttfU16(bmp, ends+i), ttfU16(bmp, starts+i)
+			delta, rangeOffset := ttfU16(bmp, deltas+i), ttfU16(bmp, ranges+i)
+			for c := start; c <= end && c != 0xffff; c++ {
+				glyph := c + delta
+				if rangeOffset != 0 {
+					at := ranges + i + rangeOffset + 2*(c-start)
+					if at+2 > len(bmp) {
+						return nil, errMalformedFont
+					}
+					glyph = ttfU16(bmp, at)
+					if glyph != 0 {
+						glyph += delta
+					}
+				}
+				if glyph&0xffff != 0 {
+					glyphs[rune(c)] = uint16(glyph)
+				}
+			}
+		}
+	default:
+		return nil, errors.New("TrueType font has no Unicode character map")
+	}
+	return glyphs, nil
+}
+
+// pdfName returns s with the characters not allowed in a PDF name
+// left out.
+func pdfName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0x20 && r < 0x7f && !strings.ContainsRune("()<>[]{}/%#", r) {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "EmbeddedFont"
+	}
+	return b.String()
+}
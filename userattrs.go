@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"strings"
+
+	"ssossan/slack_analytics/stats"
+)
+
+// UserAttrs are HR attributes joined onto the rows of a user.
+type UserAttrs = stats.Attrs
+
+var userAttrsHeader = []string{"department", "location", "manager", "start_date"}
+
+// loadUserAttrs reads a CSV file with a header row naming its columns:
+// user_id or email, and any of department, location, manager and
+// start_date. Other columns are ignored. Rows are returned keyed by
+// user ID and by lowercased email.
+func loadUserAttrs(fileName string) (map[string]*UserAttrs, map[string]*UserAttrs, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, nil, csvError(fileName, err)
+	}
+	if len(records) == 0 {
+		return nil, nil, &ParseError{File: fileName, Cause: errors.New("empty file")}
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasID := columns["user_id"]
+	_, hasEmail := columns["email"]
+	if !hasID && !hasEmail {
+		return nil, nil, errors.New(fileName + ": missing user_id or email column")
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	byID := make(map[string]*UserAttrs)
+	byEmail := make(map[string]*UserAttrs)
+	for _, record := range records[1:] {
+		attrs := &UserAttrs{
+			Department: field(record, "department"),
+			Location:   field(record, "location"),
+			Manager:    field(record, "manager"),
+			StartDate:  field(record, "start_date"),
+		}
+		if id := field(record, "user_id"); id != "" {
+			byID[id] = attrs
+		}
+		if email := field(record, "email"); email != "" {
+			byEmail[strings.ToLower(email)] = attrs
+		}
+	}
+	return byID, byEmail, nil
+}
+
+// attrsColumns returns the attributes of a in userAttrsHeader order.
+// A nil UserAttrs gives empty columns.
+func attrsColumns(a *UserAttrs) []string {
+	if a == nil {
+		return make([]string, len(userAttrsHeader))
+	}
+	return []string{a.Department, a.Location, a.Manager, a.StartDate}
+}
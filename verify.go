@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+)
+
+// reconciliation follows every message from the export files to the
+// stats for -verify: each message in a file must be read, then either
+// attributed to a user or skipped.
+type reconciliation struct {
+	mu            sync.Mutex
+	files         int
+	inFiles       int
+	read          int
+	attributed    int
+	skipped       int
+	discrepancies []string
+}
+
+// verification is the reconciliation of the run, or nil without
+// -verify.
+var verification *reconciliation
+
+// countJSONMessages counts the messages of an export file without
+// decoding them.
+func countJSONMessages(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var raw []json.RawMessage
+	err = json.Unmarshal(data, &raw)
+	return len(raw), jsonError(path, data, err)
+}
+
+// check records the counts of the messages of one file: in the file,
+// read, and then attributed to a user or skipped.
+func (r *reconciliation) check(path string, inFile, read, attributed, skipped int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files++
+	r.inFiles += inFile
+	r.read += read
+	r.attributed += attributed
+	r.skipped += skipped
+	if inFile != read || read != attributed+skipped {
+		r.discrepancies = append(r.discrepancies, fmt.Sprintf("%s: %d in file, %d read, %d attributed, %d skipped",
+			path, inFile, read, attributed, skipped))
+	}
+}
+
+// print prints the totals and any discrepancy found.
+func (r *reconciliation) print() {
+	if r == nil {
+		return
+	}
+	fmt.Println(tr("Verification:"))
+	fmt.Printf(tr("  files: %d\n"), r.files)
+	fmt.Printf(tr("  messages in files: %d\n"), r.inFiles)
+	fmt.Printf(tr("  messages read: %d\n"), r.read)
+	fmt.Printf(tr("  messages attributed: %d\n"), r.attributed)
+	fmt.Printf(tr("  messages skipped: %d\n"), r.skipped)
+	sort.Strings(r.discrepancies)
+	for _, d := range r.discrepancies {
+		fmt.Println(tr("  discrepancy in %s", d))
+	}
+	if len(r.discrepancies) > 0 {
+		fmt.Printf(tr("Error: Verification found %d files with unaccounted messages.\n"), len(r.discrepancies))
+	}
+}
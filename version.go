@@ -0,0 +1,21 @@
+package main
+
+import (
+	"runtime/debug"
+)
+
+// version is set when building releases with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// buildVersion returns version, or the module version for binaries
+// installed with go install.
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// runManifest describes a converter run to -on-success-url and
+// -on-failure-url.
+type runManifest struct {
+	Status          string      `json:"status"`
+	Version         string      `json:"version"`
+	Path            string      `json:"path"`
+	Args            []string    `json:"args"`
+	StartedAt       string      `json:"started_at"`
+	FinishedAt      string      `json:"finished_at"`
+	DurationSeconds float64     `json:"duration_seconds"`
+	Outputs         []runOutput `json:"outputs"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// runOutput is a file written by the run.
+type runOutput struct {
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// notifyRun posts the manifest of the run started at start to the
+// success or failure URL. A run that did not complete, or failed with
+// fail, has failed; panicked is the value it panicked with, if any.
+func notifyRun(start time.Time, completed bool, panicked interface{}) {
+	run := &runManifest{
+		Status:          "succeeded",
+		Version:         buildVersion(),
+		Path:            flag.Arg(0),
+		Args:            os.Args[1:],
+		StartedAt:       start.Format(time.RFC3339),
+		FinishedAt:      time.Now().Format(time.RFC3339),
+		DurationSeconds: time.Since(start).Seconds(),
+		Outputs:         []runOutput{},
+	}
+	url := *successURL
+	if !completed || runError != "" {
+		run.Status = "failed"
+		url = *failureURL
+	}
+	run.Error = runError
+	if panicked != nil {
+		run.Error = fmt.Sprint(panicked)
+	}
+	if url == "" {
+		return
+	}
+
+	for _, fileName := range writtenFiles {
+		output := runOutput{Path: fileName, Kind: outputKind(fileName)}
+		if info, err := os.Stat(fileName); err == nil {
+			output.Bytes = info.Size()
+		}
+		output.SHA256, _ = hashFile(fileName)
+		run.Outputs = append(run.Outputs, output)
+	}
+
+	err := postJSON(url, run)
+	if err != nil {
+		fmt.Println(tr("Error notifying %s webhook:", run.Status), err)
+	}
+}
+
+// postJSON posts payload as JSON to url, failing on non-2xx replies.
+func postJSON(url string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// isCJK reports whether r belongs to a script written without spaces
+// between words: Chinese characters and Japanese kana. Korean, written
+// with spaces, is split like other languages.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) || r == 'ー'
+}
+
+// isCJKPunct reports whether r is CJK punctuation such as 、 and 。,
+// which separates words like spaces do.
+func isCJKPunct(r rune) bool {
+	return r >= 0x3000 && r <= 0x303f || r >= 0xff00 && r <= 0xffef && unicode.IsPunct(r)
+}
+
+// splitWords splits text into words at spaces, like strings.Fields, and
+// around every CJK character, which counts as a word of its own as in
+// the word counts of word processors.
+func splitWords(text string) []string {
+	var result []string
+	start := -1
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r) || isCJKPunct(r):
+			if start >= 0 {
+				result = append(result, text[start:i])
+				start = -1
+			}
+		case isCJK(r):
+			if start >= 0 {
+				result = append(result, text[start:i])
+				start = -1
+			}
+			result = append(result, text[i:i+utf8.RuneLen(r)])
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		result = append(result, text[start:])
+	}
+	return result
+}
+
+// countWords returns len(splitWords(text)) without building the words.
+func countWords(text string) int {
+	n := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r) || isCJKPunct(r):
+			inWord = false
+		case isCJK(r):
+			n++
+			inWord = false
+		default:
+			if !inWord {
+				n++
+				inWord = true
+			}
+		}
+	}
+	return n
+}
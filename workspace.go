@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Workspace is the export folder of one Slack workspace.
+type Workspace struct {
+	Name      string
+	Path      string
+	UsersFile string
+	// Skip is a subfolder of Path holding other workspaces.
+	Skip string
+}
+
+// multiWorkspace is set when the input holds several workspace
+// exports. Channels are then keyed by channelKey and the outputs gain
+// a workspace column.
+var multiWorkspace bool
+
+// findWorkspaces returns basePath itself if it is a workspace export,
+// the workspaces of an Enterprise Grid export, or else each subfolder
+// of basePath containing a users.json.
+func findWorkspaces(basePath string) ([]Workspace, error) {
+	usersFile := filepath.Join(basePath, "users.json")
+	if fileExists(usersFile) {
+		teamsPath := filepath.Join(basePath, "teams")
+		if info, err := os.Stat(teamsPath); err == nil && info.IsDir() {
+			return findGridWorkspaces(basePath, teamsPath)
+		}
+		return []Workspace{{Path: basePath, UsersFile: usersFile}}, nil
+	}
+
+	entries, err := ioutil.ReadDir(basePath)
+	if err != nil {
+		return nil, err
+	}
+	var workspaces []Workspace
+	for _, entry := range entries {
+		path := filepath.Join(basePath, entry.Name())
+		if entry.IsDir() && fileExists(filepath.Join(path, "users.json")) {
+			workspaces = append(workspaces, Workspace{
+				Name:      entry.Name(),
+				Path:      path,
+				UsersFile: filepath.Join(path, "users.json"),
+			})
+		}
+	}
+	if len(workspaces) == 0 {
+		// Let loading users.json report the error
+		return []Workspace{{Path: basePath, UsersFile: usersFile}}, nil
+	}
+
+	multiWorkspace = true
+	return workspaces, nil
+}
+
+// findGridWorkspaces handles the Enterprise Grid layout: org-level
+// users.json and org-wide channels at the top, and the channels of
+// each workspace under teams/NAME. Org-wide channels get an empty
+// workspace.
+func findGridWorkspaces(basePath, teamsPath string) ([]Workspace, error) {
+	usersFile := filepath.Join(basePath, "users.json")
+	workspaces := []Workspace{{Path: basePath, UsersFile: usersFile, Skip: teamsPath}}
+
+	entries, err := ioutil.ReadDir(teamsPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			workspaces = append(workspaces, Workspace{
+				Name:      entry.Name(),
+				Path:      filepath.Join(teamsPath, entry.Name()),
+				UsersFile: usersFile,
+			})
+		}
+	}
+
+	multiWorkspace = true
+	return workspaces, nil
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// channelKey identifies a channel across workspaces. Slack channel
+// names cannot contain a slash.
+func channelKey(workspace, channelName string) string {
+	if workspace == "" {
+		return channelName
+	}
+	return workspace + "/" + channelName
+}
+
+func splitChannelKey(key string) (string, string) {
+	i := strings.LastIndex(key, "/")
+	if i < 0 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
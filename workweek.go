@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// workWeek tells the working days of a week, indexed by weekday.
+type workWeek [7]bool
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// defaultWorkWeek is the -work-week of users without a location of
+// -location-work-weeks.
+var defaultWorkWeek = workWeek{false, true, true, true, true, true, false}
+
+// locationWorkWeeks holds the work weeks of -location-work-weeks by
+// location of -user-attrs.
+var locationWorkWeeks = make(map[string]workWeek)
+
+// parseWorkWeek parses days such as "mon-fri" or "sun-thu", from the
+// first working day to the last.
+func parseWorkWeek(value string) (workWeek, error) {
+	var week workWeek
+	parts := strings.SplitN(strings.ToLower(strings.TrimSpace(value)), "-", 2)
+	if len(parts) == 2 {
+		first, ok1 := weekdayNames[parts[0]]
+		last, ok2 := weekdayNames[parts[1]]
+		if ok1 && ok2 {
+			for d := first; ; d = (d + 1) % 7 {
+				week[d] = true
+				if d == last {
+					return week, nil
+				}
+			}
+		}
+	}
+	return week, errors.New("work week must be FIRST-LAST such as mon-fri or sun-thu, got " + value)
+}
+
+// parseLocationWorkWeeks parses a comma-separated list of
+// location=days pairs such as "Dubai=sun-thu,Riyadh=sun-thu".
+func parseLocationWorkWeeks(list string) (map[string]workWeek, error) {
+	weeks := make(map[string]workWeek)
+	if list == "" {
+		return weeks, nil
+	}
+	for _, pair := range strings.Split(list, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("invalid location work week " + pair)
+		}
+		week, err := parseWorkWeek(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		weeks[strings.TrimSpace(parts[0])] = week
+	}
+	return weeks, nil
+}
+
+// userWorkWeek returns the work week of the location of u, or the
+// default one.
+func userWorkWeek(u *User) workWeek {
+	if u != nil && u.Attrs != nil {
+		if week, ok := locationWorkWeeks[u.Attrs.Location]; ok {
+			return week
+		}
+	}
+	return defaultWorkWeek
+}